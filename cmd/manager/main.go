@@ -13,6 +13,16 @@ import (
 
 	ingressmetasyncv1alpha1 "github.com/galbakal/k8s-ingress-meta-sync/pkg/apis/ingressmetasync/v1alpha1"
 	"github.com/galbakal/k8s-ingress-meta-sync/pkg/controller"
+
+	// Register providers
+	_ "github.com/galbakal/k8s-ingress-meta-sync/pkg/providers/aws"
+	_ "github.com/galbakal/k8s-ingress-meta-sync/pkg/providers/azure"
+	_ "github.com/galbakal/k8s-ingress-meta-sync/pkg/providers/dns"
+	_ "github.com/galbakal/k8s-ingress-meta-sync/pkg/providers/gcp"
+	_ "github.com/galbakal/k8s-ingress-meta-sync/pkg/providers/github"
+	_ "github.com/galbakal/k8s-ingress-meta-sync/pkg/providers/httpjson"
+	_ "github.com/galbakal/k8s-ingress-meta-sync/pkg/providers/static"
+	_ "github.com/galbakal/k8s-ingress-meta-sync/pkg/providers/text"
 )
 
 var (
@@ -17,6 +17,26 @@ type IngressConfigSpec struct {
 	// Istio specific configuration
 	// +optional
 	Istio *IstioIngressConfig `json:"istio,omitempty"`
+	
+	// AddressFamily selects which address family this ingress receives, e.g. IPv4
+	// for gateways that cannot handle IPv6
+	// +optional
+	// +kubebuilder:default="DualStack"
+	// +kubebuilder:validation:Enum=IPv4;IPv6;DualStack
+	AddressFamily string `json:"addressFamily,omitempty"`
+	
+	// MaxEntries caps the number of prefixes applied to this ingress. Larger sets are
+	// compressed by merging neighbouring ranges into covering supernets.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxEntries int32 `json:"maxEntries,omitempty"`
+	
+	// MaxOverAdmission is the number of addresses outside the collected ranges that
+	// compression may admit, as a decimal string since IPv6 counts exceed 64 bits.
+	// Defaults to zero, so only lossless compression is applied.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+$`
+	MaxOverAdmission string `json:"maxOverAdmission,omitempty"`
 }
 
 // CloudflareIngressConfig contains Cloudflare specific configuration
@@ -24,26 +44,67 @@ type CloudflareIngressConfig struct {
 	// API configuration
 	API CloudflareAPIConfig `json:"api"`
 	
-	// RuleConfig defines the Cloudflare rule configuration
+	// RuleConfig defines the Cloudflare rule configuration. It may be omitted when
+	// List is set and the rules referencing the list are managed by hand.
+	// +optional
 	RuleConfig CloudflareRuleConfig `json:"ruleConfig"`
 	
+	// List switches to list mode, where the ranges are kept in an account-level IP list
+	// that rules in any number of zones can reference as $name
+	// +optional
+	List *CloudflareListConfig `json:"list,omitempty"`
+	
 	// UpdateStrategy defines how to apply updates
 	// +optional
 	// +kubebuilder:default="direct"
 	// +kubebuilder:validation:Enum=direct;incremental
 	UpdateStrategy string `json:"updateStrategy,omitempty"`
+	
+	// Engine selects the Cloudflare API managing the rule. The rulesets engine adds a
+	// custom rule to the zone's http_request_firewall_custom phase and migrates a rule
+	// previously created with the deprecated firewallRules engine.
+	// +optional
+	// +kubebuilder:default="rulesets"
+	// +kubebuilder:validation:Enum=rulesets;firewallRules
+	Engine string `json:"engine,omitempty"`
+	
+	// Adopt allows taking over an existing filter, rule or list that matches the
+	// configured names but was not created for this IngressConfig. Without it such
+	// objects are left untouched and the sync fails.
+	// +optional
+	Adopt bool `json:"adopt,omitempty"`
 }
 
 // CloudflareAPIConfig contains configuration for Cloudflare API
 type CloudflareAPIConfig struct {
 	// SecretRef points to a Kubernetes Secret containing API credentials
 	SecretRef SecretReference `json:"secretRef"`
+	
+	// BaseURL overrides the Cloudflare API base URL, e.g. to point at a mock server
+	// +optional
+	BaseURL string `json:"baseURL,omitempty"`
 }
 
 // CloudflareRuleConfig contains configuration for Cloudflare rule
 type CloudflareRuleConfig struct {
 	// ZoneID is the Cloudflare zone ID
-	ZoneID string `json:"zoneId"`
+	// +optional
+	ZoneID string `json:"zoneId,omitempty"`
+	
+	// ZoneIDs applies the same rule to several zones, in addition to ZoneID
+	// +optional
+	ZoneIDs []string `json:"zoneIds,omitempty"`
+	
+	// ZoneSelector applies the same rule to the zones whose name matches, using the
+	// name filter of the zones API, e.g. "example.com" or "ends_with:.example.com"
+	// +optional
+	ZoneSelector string `json:"zoneSelector,omitempty"`
+	
+	// ZoneConcurrency is the number of zones updated at once
+	// +optional
+	// +kubebuilder:default=4
+	// +kubebuilder:validation:Minimum=1
+	ZoneConcurrency int32 `json:"zoneConcurrency,omitempty"`
 	
 	// RuleName is the name of the Cloudflare rule
 	RuleName string `json:"ruleName"`
@@ -52,7 +113,9 @@ type CloudflareRuleConfig struct {
 	// +optional
 	Description string `json:"description,omitempty"`
 	
-	// Action for the rule (e.g., "allow", "block", "challenge")
+	// Action for the rule (e.g., "allow", "block", "challenge"). The rulesets engine
+	// supports skip (or its alias allow), block, managed_challenge, challenge,
+	// js_challenge and log.
 	// +optional
 	// +kubebuilder:default="allow"
 	Action string `json:"action,omitempty"`
@@ -60,6 +123,36 @@ type CloudflareRuleConfig struct {
 	// Priority defines the rule's priority
 	// +optional
 	Priority int32 `json:"priority,omitempty"`
+	
+	// AccountID is the Cloudflare account ID owning the IP list, required for
+	// the incremental update strategy
+	// +optional
+	AccountID string `json:"accountId,omitempty"`
+	
+	// ListName is the name of the IP list used by the incremental update strategy.
+	// Defaults to the rule name in lowercase with other characters replaced by underscores.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[a-z0-9_]{1,50}$`
+	ListName string `json:"listName,omitempty"`
+}
+
+// CloudflareListConfig contains configuration for an account-level Cloudflare IP list
+type CloudflareListConfig struct {
+	// AccountID is the Cloudflare account ID owning the list
+	AccountID string `json:"accountId"`
+	
+	// Name is the name of the list, referenced in rule expressions as $name
+	// +kubebuilder:validation:Pattern=`^[a-z0-9_]{1,50}$`
+	Name string `json:"name"`
+	
+	// Description is a description for the list
+	// +optional
+	Description string `json:"description,omitempty"`
+	
+	// CreateRule also creates a rule matching the list in the zone from RuleConfig.
+	// By default the rules referencing the list are left to be created by hand.
+	// +optional
+	CreateRule bool `json:"createRule,omitempty"`
 }
 
 // IstioIngressConfig contains Istio specific configuration
@@ -122,11 +215,56 @@ type IngressConfigStatus struct {
 	// +optional
 	LastSyncError string `json:"lastSyncError,omitempty"`
 	
+	// Zones reports the outcome of the last sync for each zone when the rule is
+	// applied to several Cloudflare zones
+	// +optional
+	Zones []ZoneSyncStatus `json:"zones,omitempty"`
+	
+	// ManagedObjects lists the objects created or adopted in the target system, so
+	// that they are found again even if they are renamed
+	// +optional
+	ManagedObjects []ManagedObjectStatus `json:"managedObjects,omitempty"`
+	
 	// Conditions represent the latest available observations of the Ingress's state
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// ZoneSyncStatus defines the sync status of a single Cloudflare zone
+type ZoneSyncStatus struct {
+	// ZoneID is the Cloudflare zone ID
+	ZoneID string `json:"zoneId"`
+	
+	// ZoneName is the name of the zone, if it was matched by name
+	// +optional
+	ZoneName string `json:"zoneName,omitempty"`
+	
+	// LastSyncTime is the last time this zone was synced
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	
+	// Status of the sync
+	// +optional
+	Status string `json:"status,omitempty"`
+	
+	// Error is the last error encountered with this zone
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// ManagedObjectStatus identifies an object managed in the target system
+type ManagedObjectStatus struct {
+	// Kind is the type of object: list, filter, firewallRule or rulesetRule
+	Kind string `json:"kind"`
+	
+	// ID is the identifier of the object
+	ID string `json:"id"`
+	
+	// Scope is the zone or account the object belongs to
+	// +optional
+	Scope string `json:"scope,omitempty"`
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.type"
@@ -7,7 +7,7 @@ import (
 // ProviderConfigSpec defines the desired state of ProviderConfig
 type ProviderConfigSpec struct {
 	// Type specifies the provider type (github, aws, etc.)
-	// +kubebuilder:validation:Enum=github;aws
+	// +kubebuilder:validation:Enum=github;aws;gcp;azure;http;text;static;dns
 	Type string `json:"type"`
 	
 	// GitHub specific configuration
@@ -17,15 +17,57 @@ type ProviderConfigSpec struct {
 	// AWS specific configuration
 	// +optional
 	AWS *AWSProviderConfig `json:"aws,omitempty"`
+	
+	// GCP specific configuration
+	// +optional
+	GCP *GCPProviderConfig `json:"gcp,omitempty"`
+	
+	// Azure specific configuration
+	// +optional
+	Azure *AzureProviderConfig `json:"azure,omitempty"`
+	
+	// HTTP specific configuration for generic JSON endpoints
+	// +optional
+	HTTP *HTTPProviderConfig `json:"http,omitempty"`
+	
+	// Text specific configuration for plain-text and CSV lists
+	// +optional
+	Text *TextProviderConfig `json:"text,omitempty"`
+	
+	// Static specific configuration for self-managed ranges
+	// +optional
+	Static *StaticProviderConfig `json:"static,omitempty"`
+	
+	// DNS specific configuration for ranges published through SPF and A/AAAA records
+	// +optional
+	DNS *DNSProviderConfig `json:"dns,omitempty"`
+	
+	// HostBits selects how CIDRs with bits set after the prefix length are handled:
+	// Mask stores them with the host bits cleared, Reject drops and logs them
+	// +optional
+	// +kubebuilder:default="Mask"
+	// +kubebuilder:validation:Enum=Mask;Reject
+	HostBits string `json:"hostBits,omitempty"`
 }
 
 // GitHubProviderConfig contains GitHub specific configuration
 type GitHubProviderConfig struct {
 	// Enterprise toggles between Enterprise and public GitHub
+	// Deprecated: the deployment type is derived from BaseURL
 	// +optional
 	// +kubebuilder:default=true
 	Enterprise bool `json:"enterprise,omitempty"`
 	
+	// BaseURL of the GitHub deployment: https://github.com (default), a GHE.com
+	// data-residency tenant (https://<tenant>.ghe.com) or a GHES instance
+	// +optional
+	BaseURL string `json:"baseURL,omitempty"`
+	
+	// APIVersion is sent in the X-GitHub-Api-Version header
+	// +optional
+	// +kubebuilder:default="2022-11-28"
+	APIVersion string `json:"apiVersion,omitempty"`
+	
 	// API configuration
 	API GitHubAPIConfig `json:"api"`
 	
@@ -38,7 +80,28 @@ type GitHubProviderConfig struct {
 // GitHubAPIConfig contains configuration for GitHub API
 type GitHubAPIConfig struct {
 	// SecretRef points to a Kubernetes Secret containing API credentials
-	SecretRef SecretReference `json:"secretRef"`
+	// +optional
+	SecretRef SecretReference `json:"secretRef,omitempty"`
+	
+	// App configures GitHub App authentication instead of a personal access token
+	// +optional
+	App *GitHubAppConfig `json:"app,omitempty"`
+}
+
+// GitHubAppConfig contains configuration for GitHub App authentication
+type GitHubAppConfig struct {
+	// AppID is the GitHub App ID (or client ID)
+	AppID string `json:"appId"`
+	
+	// InstallationID is the ID of the app installation to mint tokens for
+	InstallationID int64 `json:"installationId"`
+	
+	// PrivateKeySecretRef points to a Kubernetes Secret containing the app's PEM private key
+	PrivateKeySecretRef SecretReference `json:"privateKeySecretRef"`
+	
+	// TokenURL overrides the installation access token endpoint
+	// +optional
+	TokenURL string `json:"tokenURL,omitempty"`
 }
 
 // AWSProviderConfig contains AWS specific configuration
@@ -59,6 +122,10 @@ type AWSProviderConfig struct {
 	// +optional
 	// +kubebuilder:default="1m"
 	PollingInterval string `json:"pollingInterval,omitempty"`
+	
+	// URL overrides the location of the ip-ranges.json document
+	// +optional
+	URL string `json:"url,omitempty"`
 }
 
 // AWSAPIConfig contains configuration for AWS API
@@ -67,6 +134,213 @@ type AWSAPIConfig struct {
 	SecretRef SecretReference `json:"secretRef"`
 }
 
+// GCPProviderConfig contains Google Cloud and Googlebot specific configuration
+type GCPProviderConfig struct {
+	// URLs lists the Google IP range documents to consume (cloud.json, goog.json, googlebot.json)
+	// +optional
+	// +kubebuilder:default={"https://www.gstatic.com/ipranges/cloud.json"}
+	URLs []string `json:"urls,omitempty"`
+	
+	// Scopes defines which scopes (regions) to include, e.g. "us-central1"
+	// +optional
+	Scopes []string `json:"scopes,omitempty"`
+	
+	// Services defines which services to include, e.g. "Google Cloud"
+	// +optional
+	Services []string `json:"services,omitempty"`
+	
+	// PollingInterval defines how often to check for IP range updates
+	// +optional
+	// +kubebuilder:default="1m"
+	PollingInterval string `json:"pollingInterval,omitempty"`
+}
+
+// AzureProviderConfig contains Azure Service Tags specific configuration
+type AzureProviderConfig struct {
+	// URL of the weekly Service Tags JSON file
+	// +optional
+	URL string `json:"url,omitempty"`
+	
+	// File is the path of a mounted Service Tags JSON file, used instead of URL when set
+	// +optional
+	File string `json:"file,omitempty"`
+	
+	// ServiceTags defines which service tags to include, e.g. "AzureDevOps" or "AzureFrontDoor.Backend"
+	// +optional
+	ServiceTags []string `json:"serviceTags,omitempty"`
+	
+	// Regions defines which regions to include
+	// +optional
+	Regions []string `json:"regions,omitempty"`
+	
+	// PollingInterval defines how often to check for IP range updates
+	// +optional
+	// +kubebuilder:default="1h"
+	PollingInterval string `json:"pollingInterval,omitempty"`
+}
+
+// HTTPProviderConfig contains configuration for a generic HTTP/JSON endpoint
+type HTTPProviderConfig struct {
+	// URL of the JSON document
+	URL string `json:"url"`
+	
+	// Headers are static headers sent with every request
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+	
+	// API configures authentication from a Kubernetes Secret
+	// +optional
+	API *HTTPAPIConfig `json:"api,omitempty"`
+	
+	// Mappings extract IP ranges from the document with JSONPath expressions
+	// +kubebuilder:validation:MinItems=1
+	Mappings []HTTPRangeMapping `json:"mappings"`
+	
+	// PollingInterval defines how often to check for IP range updates
+	// +optional
+	// +kubebuilder:default="1m"
+	PollingInterval string `json:"pollingInterval,omitempty"`
+}
+
+// HTTPAPIConfig contains authentication configuration for a generic HTTP endpoint
+type HTTPAPIConfig struct {
+	// SecretRef points to a Kubernetes Secret containing API credentials
+	SecretRef SecretReference `json:"secretRef"`
+	
+	// Header is the request header carrying the credential
+	// +optional
+	// +kubebuilder:default="Authorization"
+	Header string `json:"header,omitempty"`
+	
+	// Scheme is prepended to the credential when using the Authorization header
+	// +optional
+	// +kubebuilder:default="Bearer"
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// HTTPRangeMapping maps the results of a JSONPath expression to a label
+type HTTPRangeMapping struct {
+	// Path is a JSONPath expression selecting CIDR strings, e.g. "$.hooks[*]"
+	Path string `json:"path"`
+	
+	// Label is attached to every range selected by Path
+	Label string `json:"label"`
+}
+
+// TextProviderConfig contains configuration for plain-text, CSV and RFC 8805 geofeed lists
+type TextProviderConfig struct {
+	// URL of the list
+	// +optional
+	URL string `json:"url,omitempty"`
+	
+	// ConfigMapRef reads the list from a ConfigMap key instead of a URL. The key is
+	// read on every sync, so PollingInterval only applies to URLs.
+	// +optional
+	ConfigMapRef *ConfigMapReference `json:"configMapRef,omitempty"`
+	
+	// Format of the list: one CIDR per line, CSV, or an RFC 8805 geofeed
+	// +optional
+	// +kubebuilder:default="lines"
+	// +kubebuilder:validation:Enum=lines;csv;geofeed
+	Format string `json:"format,omitempty"`
+	
+	// CommentPrefix starts a comment that is stripped from each line
+	// +optional
+	// +kubebuilder:default="#"
+	CommentPrefix string `json:"commentPrefix,omitempty"`
+	
+	// CIDRColumn is the zero-based CSV column holding the CIDR
+	// +optional
+	CIDRColumn int `json:"cidrColumn,omitempty"`
+	
+	// LabelColumns are zero-based CSV columns turned into labels (geofeeds default to country, region and city)
+	// +optional
+	LabelColumns []int `json:"labelColumns,omitempty"`
+	
+	// Labels are attached to every range in the list
+	// +optional
+	Labels []string `json:"labels,omitempty"`
+	
+	// PollingInterval defines how often to check for IP range updates
+	// +optional
+	// +kubebuilder:default="1m"
+	PollingInterval string `json:"pollingInterval,omitempty"`
+}
+
+// StaticProviderConfig contains inline CIDRs and references to ConfigMaps and Secrets holding CIDRs
+type StaticProviderConfig struct {
+	// Ranges lists CIDRs inline
+	// +optional
+	Ranges []StaticIPRange `json:"ranges,omitempty"`
+	
+	// Sources reference ConfigMap or Secret keys with one CIDR per line,
+	// optionally followed by whitespace-separated labels
+	// +optional
+	Sources []StaticSource `json:"sources,omitempty"`
+}
+
+// StaticIPRange is an inline CIDR with its labels
+type StaticIPRange struct {
+	// CIDR notation (e.g., "192.168.1.0/24")
+	CIDR string `json:"cidr"`
+	
+	// Labels associated with this range
+	// +optional
+	Labels []string `json:"labels,omitempty"`
+}
+
+// StaticSource references a ConfigMap or Secret key holding CIDRs
+type StaticSource struct {
+	// ConfigMapRef points to a ConfigMap key
+	// +optional
+	ConfigMapRef *ConfigMapReference `json:"configMapRef,omitempty"`
+	
+	// SecretRef points to a Secret key
+	// +optional
+	SecretRef *SecretReference `json:"secretRef,omitempty"`
+	
+	// Labels are attached to every range in the source
+	// +optional
+	Labels []string `json:"labels,omitempty"`
+}
+
+// DNSProviderConfig contains DNS specific configuration
+type DNSProviderConfig struct {
+	// SPFDomains are domains whose SPF records are expanded, e.g. "_spf.google.com"
+	// +optional
+	SPFDomains []string `json:"spfDomains,omitempty"`
+	
+	// Hostnames are resolved to their A and AAAA records
+	// +optional
+	Hostnames []string `json:"hostnames,omitempty"`
+	
+	// Resolver is the address (host:port) of the DNS server to query instead of the system resolver
+	// +optional
+	Resolver string `json:"resolver,omitempty"`
+	
+	// MaxDepth limits how deep SPF include chains are followed
+	// +optional
+	// +kubebuilder:default=10
+	MaxDepth int `json:"maxDepth,omitempty"`
+	
+	// PollingInterval defines how often to check for IP range updates
+	// +optional
+	// +kubebuilder:default="1m"
+	PollingInterval string `json:"pollingInterval,omitempty"`
+}
+
+// ConfigMapReference points to a key in a Kubernetes ConfigMap
+type ConfigMapReference struct {
+	// Name is the name of the ConfigMap
+	Name string `json:"name"`
+	
+	// Namespace is the namespace of the ConfigMap
+	Namespace string `json:"namespace"`
+	
+	// Key is the key in the ConfigMap that contains the data
+	Key string `json:"key"`
+}
+
 // SecretReference contains information that points to the Kubernetes Secret being used
 type SecretReference struct {
 	// Name is the name of the secret
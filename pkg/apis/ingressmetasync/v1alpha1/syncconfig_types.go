@@ -12,6 +12,11 @@ type SyncConfigSpec struct {
 	// Ingress is a list of ingress configurations to sync to
 	Ingress []IngressReference `json:"ingress"`
 	
+	// Aggregate collapses the collected ranges into the smallest equivalent set of prefixes
+	// before they are applied, removing duplicates and merging adjacent ranges
+	// +optional
+	Aggregate bool `json:"aggregate,omitempty"`
+	
 	// SyncPolicy defines how syncing should be handled
 	// +optional
 	SyncPolicy *SyncPolicy `json:"syncPolicy,omitempty"`
@@ -31,6 +36,24 @@ type ProviderReference struct {
 	// ExcludeRanges specifies which IP ranges to exclude
 	// +optional
 	ExcludeRanges []string `json:"excludeRanges,omitempty"`
+	
+	// Selector filters ranges by structured labels, e.g.
+	// "service=CLOUDFRONT,region=eu-*,region!=eu-south-2". Keys are provider, type,
+	// category (or service), region, the keys of key=value labels, and label for plain labels.
+	// Requirements are ANDed and support =, !=, in, notin, key, !key, globs and =~/!~ regexes.
+	// +optional
+	Selector string `json:"selector,omitempty"`
+	
+	// Subtract removes this provider's ranges from the union of the other providers
+	// instead of adding them, e.g. to apply a denylist
+	// +optional
+	Subtract bool `json:"subtract,omitempty"`
+	
+	// AddressFamily selects which address family of this provider's ranges to use
+	// +optional
+	// +kubebuilder:default="DualStack"
+	// +kubebuilder:validation:Enum=IPv4;IPv6;DualStack
+	AddressFamily string `json:"addressFamily,omitempty"`
 }
 
 // IngressReference references an IngressConfig
@@ -110,9 +133,29 @@ type ProviderSyncStatus struct {
 	// +optional
 	IPRangesCount int32 `json:"ipRangesCount,omitempty"`
 	
+	// IPv4RangesCount is the number of IPv4 ranges in IPRangesCount
+	// +optional
+	IPv4RangesCount int32 `json:"ipv4RangesCount,omitempty"`
+	
+	// IPv6RangesCount is the number of IPv6 ranges in IPRangesCount
+	// +optional
+	IPv6RangesCount int32 `json:"ipv6RangesCount,omitempty"`
+	
+	// DroppedRangesCount is the number of ranges dropped by the address family selector
+	// +optional
+	DroppedRangesCount int32 `json:"droppedRangesCount,omitempty"`
+	
 	// Error is the last error encountered with this provider
 	// +optional
 	Error string `json:"error,omitempty"`
+	
+	// Warnings are non-fatal issues reported by the provider, such as ignored metadata keys
+	// +optional
+	Warnings []string `json:"warnings,omitempty"`
+	
+	// RateLimitedUntil is the time until which the provider's upstream API is rate limiting requests
+	// +optional
+	RateLimitedUntil *metav1.Time `json:"rateLimitedUntil,omitempty"`
 }
 
 // IngressSyncStatus represents the sync status for a specific ingress
@@ -132,6 +175,35 @@ type IngressSyncStatus struct {
 	// +optional
 	IPRangesCount int32 `json:"ipRangesCount,omitempty"`
 	
+	// IPv4RangesCount is the number of IPv4 ranges in IPRangesCount
+	// +optional
+	IPv4RangesCount int32 `json:"ipv4RangesCount,omitempty"`
+	
+	// IPv6RangesCount is the number of IPv6 ranges in IPRangesCount
+	// +optional
+	IPv6RangesCount int32 `json:"ipv6RangesCount,omitempty"`
+	
+	// DroppedRangesCount is the number of ranges dropped by the address family selector
+	// +optional
+	DroppedRangesCount int32 `json:"droppedRangesCount,omitempty"`
+	
+	// OverAdmittedAddresses is the number of addresses admitted beyond the collected ranges
+	// to fit the ingress entry budget
+	// +optional
+	OverAdmittedAddresses string `json:"overAdmittedAddresses,omitempty"`
+	
+	// AddedCount is the number of entries added when the last update was only partially applied
+	// +optional
+	AddedCount int32 `json:"addedCount,omitempty"`
+	
+	// RemovedCount is the number of entries removed when the last update was only partially applied
+	// +optional
+	RemovedCount int32 `json:"removedCount,omitempty"`
+	
+	// Failures lists the operations that failed when the last update was only partially applied
+	// +optional
+	Failures []string `json:"failures,omitempty"`
+	
 	// Error is the last error encountered with this ingress
 	// +optional
 	Error string `json:"error,omitempty"`
@@ -0,0 +1,328 @@
+package cloudflareapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// DefaultBaseURL is the Cloudflare API base URL
+const DefaultBaseURL = "https://api.cloudflare.com/client/v4"
+
+const (
+	// defaultMaxRetries is the number of times a request is retried after a 429 or, if it is idempotent, a 5xx
+	defaultMaxRetries = 4
+
+	// defaultMinBackoff is the delay before the first retry, doubled for every further retry
+	defaultMinBackoff = 500 * time.Millisecond
+
+	// defaultMaxBackoff caps the delay between retries
+	defaultMaxBackoff = 30 * time.Second
+
+	// defaultMaxRetryAfter is the longest Retry-After the client waits out; longer waits are returned as errors
+	defaultMaxRetryAfter = 2 * time.Minute
+
+	// defaultPageSize is the number of items requested per page when listing
+	defaultPageSize = 100
+)
+
+var log = ctrl.Log.WithName("cloudflareapi")
+
+// Client is a Cloudflare API client shared by everything talking to Cloudflare
+type Client struct {
+	baseURL       string
+	apiToken      string
+	httpClient    *http.Client
+	maxRetries    int
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	maxRetryAfter time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithBaseURL overrides the API base URL, e.g. to point at a mock server
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		if baseURL != "" {
+			c.baseURL = strings.TrimSuffix(baseURL, "/")
+		}
+	}
+}
+
+// WithHTTPClient overrides the HTTP client
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithMaxRetries overrides the number of retries
+func WithMaxRetries(maxRetries int) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// WithBackoff overrides the delays between retries
+func WithBackoff(minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// New creates a client authenticating with an API token
+func New(apiToken string, options ...Option) *Client {
+	c := &Client{
+		baseURL:       DefaultBaseURL,
+		apiToken:      apiToken,
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+		maxRetries:    defaultMaxRetries,
+		minBackoff:    defaultMinBackoff,
+		maxBackoff:    defaultMaxBackoff,
+		maxRetryAfter: defaultMaxRetryAfter,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// BaseURL returns the API base URL
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Response is the envelope of every Cloudflare API response
+type Response struct {
+	Success    bool              `json:"success"`
+	Errors     []CloudflareError `json:"errors"`
+	Messages   []json.RawMessage `json:"messages"`
+	Result     json.RawMessage   `json:"result"`
+	ResultInfo *ResultInfo       `json:"result_info,omitempty"`
+}
+
+// ResultInfo is the pagination information of a list response, by page or by cursor
+type ResultInfo struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Count      int `json:"count"`
+	TotalCount int `json:"total_count"`
+	TotalPages int `json:"total_pages"`
+	Cursors    struct {
+		After string `json:"after"`
+	} `json:"cursors"`
+}
+
+// Do sends a request to path, relative to the base URL, and decodes the result into result, if not nil.
+// Requests rejected with 429 are retried after Retry-After; idempotent requests are also retried on
+// 5xx responses and network errors, with exponential backoff and jitter.
+func (c *Client) Do(ctx context.Context, method, path string, body, result interface{}) (*Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling request: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		response, retryAfter, err := c.do(ctx, method, path, data)
+		if err == nil {
+			if result != nil && len(response.Result) > 0 {
+				if err := json.Unmarshal(response.Result, result); err != nil {
+					return nil, fmt.Errorf("error unmarshaling result: %w", err)
+				}
+			}
+			return response, nil
+		}
+
+		wait, retry := c.retryDelay(method, attempt, retryAfter, err)
+		if !retry {
+			return nil, err
+		}
+
+		log.V(1).Info("Retrying Cloudflare API request", "method", method, "path", path, "attempt", attempt+1, "wait", wait.String(), "error", err.Error())
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (giving up: %v)", err, ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// do sends a single request, returning the Retry-After delay of a 429 response
+func (c *Client) do(ctx context.Context, method, path string, data []byte) (*Response, time.Duration, error) {
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.apiToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, &RequestError{Method: method, Path: path, Err: err}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, &RequestError{Method: method, Path: path, StatusCode: resp.StatusCode, Err: err}
+	}
+
+	var response Response
+	decodeErr := json.Unmarshal(respBody, &response)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || decodeErr != nil || !response.Success {
+		requestErr := &RequestError{
+			Method:     method,
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Errors:     response.Errors,
+		}
+		if len(response.Errors) == 0 {
+			requestErr.Body = string(respBody)
+		}
+		if decodeErr != nil && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			requestErr.Err = fmt.Errorf("error decoding response: %w", decodeErr)
+		}
+
+		var retryAfter time.Duration
+		if resp.StatusCode == http.StatusTooManyRequests {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			requestErr.RetryAt = time.Now().Add(retryAfter)
+		}
+		return nil, retryAfter, requestErr
+	}
+
+	return &response, 0, nil
+}
+
+// retryDelay returns how long to wait before retrying a failed request, if it should be retried
+func (c *Client) retryDelay(method string, attempt int, retryAfter time.Duration, err error) (time.Duration, bool) {
+	if attempt >= c.maxRetries {
+		return 0, false
+	}
+
+	requestErr, ok := err.(*RequestError)
+	if !ok {
+		return 0, false
+	}
+
+	switch {
+	case requestErr.StatusCode == http.StatusTooManyRequests:
+		// The request was rejected before being processed, so it is safe to resend whatever the method
+		if retryAfter > c.maxRetryAfter {
+			return 0, false
+		}
+		if retryAfter > 0 {
+			return retryAfter, true
+		}
+		return c.backoff(attempt), true
+
+	case requestErr.StatusCode >= 500, requestErr.StatusCode == 0 && requestErr.Err != nil:
+		// The request may have been processed, so only resend it if that is harmless
+		if !idempotent(method) {
+			return 0, false
+		}
+		return c.backoff(attempt), true
+	}
+
+	return 0, false
+}
+
+// backoff returns an exponential delay for the attempt with jitter, between half and all of it
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.minBackoff << uint(attempt)
+	if delay <= 0 || delay > c.maxBackoff {
+		delay = c.maxBackoff
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// idempotent reports whether sending a request with the method twice has the same effect as once
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// List requests every page of a list endpoint, following page numbers or cursors, and calls fn with
+// the result of each page
+func (c *Client) List(ctx context.Context, path string, query url.Values, fn func(result json.RawMessage) error) error {
+	query = cloneValues(query)
+	if query.Get("per_page") == "" {
+		query.Set("per_page", strconv.Itoa(defaultPageSize))
+	}
+
+	page := 1
+	for {
+		response, err := c.Do(ctx, http.MethodGet, path+"?"+query.Encode(), nil, nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(response.Result); err != nil {
+			return err
+		}
+
+		info := response.ResultInfo
+		switch {
+		case info == nil:
+			return nil
+		case info.Cursors.After != "":
+			query.Set("cursor", info.Cursors.After)
+		case info.TotalPages > page:
+			page++
+			query.Set("page", strconv.Itoa(page))
+		default:
+			return nil
+		}
+	}
+}
+
+// cloneValues returns a copy of the query values that can be modified
+func cloneValues(values url.Values) url.Values {
+	clone := url.Values{}
+	for key, value := range values {
+		clone[key] = append([]string(nil), value...)
+	}
+	return clone
+}
@@ -0,0 +1,387 @@
+package cloudflareapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for server that retries without noticeable delays
+func newTestClient(server *httptest.Server, options ...Option) *Client {
+	options = append([]Option{WithBaseURL(server.URL + "/"), WithBackoff(time.Millisecond, 2*time.Millisecond)}, options...)
+	return New("token", options...)
+}
+
+// writeResult writes a successful Cloudflare API response with the result
+func writeResult(w http.ResponseWriter, result string) {
+	fmt.Fprintf(w, `{"success": true, "errors": [], "messages": [], "result": %s}`, result)
+}
+
+// writeErrors writes a failed Cloudflare API response with the status and error codes
+func writeErrors(w http.ResponseWriter, status int, codes ...ErrorCode) {
+	errs := make([]CloudflareError, len(codes))
+	for i, code := range codes {
+		errs[i] = CloudflareError{Code: code, Message: fmt.Sprintf("error %d", code)}
+	}
+	data, _ := json.Marshal(errs)
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"success": false, "errors": %s, "messages": [], "result": null}`, data)
+}
+
+func TestDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization header = %q, want %q", got, "Bearer token")
+		}
+		if r.URL.Path != "/zones/zone-id/filters" || r.Method != http.MethodPost {
+			t.Errorf("request = %s %s, want POST /zones/zone-id/filters", r.Method, r.URL.Path)
+		}
+		var body []map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body) != 1 || body[0]["expression"] != "ip.src eq 192.0.2.1" {
+			t.Errorf("request body = %v (%v), want the filter", body, err)
+		}
+		writeResult(w, `[{"id": "filter-id"}]`)
+	}))
+	defer server.Close()
+
+	var result []struct {
+		ID string `json:"id"`
+	}
+	body := []map[string]string{{"expression": "ip.src eq 192.0.2.1"}}
+	if _, err := newTestClient(server).Do(context.Background(), http.MethodPost, "/zones/zone-id/filters", body, &result); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if len(result) != 1 || result[0].ID != "filter-id" {
+		t.Errorf("result = %+v, want filter-id", result)
+	}
+}
+
+func TestDoRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		statuses     []int
+		retryAfter   string
+		maxRetries   int
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{
+			name:         "idempotent request is retried on 5xx",
+			method:       http.MethodGet,
+			statuses:     []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			maxRetries:   4,
+			wantAttempts: 3,
+		},
+		{
+			name:         "non-idempotent request is not retried on 5xx",
+			method:       http.MethodPost,
+			statuses:     []int{http.StatusInternalServerError, http.StatusOK},
+			maxRetries:   4,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "non-idempotent request is retried on 429",
+			method:       http.MethodPost,
+			statuses:     []int{http.StatusTooManyRequests, http.StatusOK},
+			maxRetries:   4,
+			wantAttempts: 2,
+		},
+		{
+			name:         "client errors are not retried",
+			method:       http.MethodGet,
+			statuses:     []int{http.StatusBadRequest, http.StatusOK},
+			maxRetries:   4,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "gives up after the last retry",
+			method:       http.MethodDelete,
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+			maxRetries:   2,
+			wantAttempts: 3,
+			wantErr:      true,
+		},
+		{
+			name:         "Retry-After beyond the limit is not waited out",
+			method:       http.MethodGet,
+			statuses:     []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:   "3600",
+			maxRetries:   4,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := atomic.AddInt32(&attempts, 1)
+				status := tt.statuses[attempt-1]
+				if status == http.StatusOK {
+					writeResult(w, `{"id": "id"}`)
+					return
+				}
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				writeErrors(w, status, 10001)
+			}))
+			defer server.Close()
+
+			_, err := newTestClient(server, WithMaxRetries(tt.maxRetries)).Do(context.Background(), tt.method, "/path", nil, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := atomic.LoadInt32(&attempts); got != tt.wantAttempts {
+				t.Errorf("Do() made %d attempts, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestDoWaitsForRetryAfter(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			writeErrors(w, http.StatusTooManyRequests, 971)
+			return
+		}
+		writeResult(w, `{}`)
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	if _, err := newTestClient(server).Do(context.Background(), http.MethodGet, "/path", nil, nil); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Do() retried after %s, want at least the 1s Retry-After", elapsed)
+	}
+}
+
+func TestDoRateLimitError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "600")
+		writeErrors(w, http.StatusTooManyRequests, 971)
+	}))
+	defer server.Close()
+
+	_, err := newTestClient(server).Do(context.Background(), http.MethodGet, "/path", nil, nil)
+	retryAt, limited := IsRateLimited(err)
+	if !limited {
+		t.Fatalf("IsRateLimited(%v) = false, want true", err)
+	}
+	if until := time.Until(retryAt); until < 9*time.Minute || until > 10*time.Minute {
+		t.Errorf("RetryAt is %s away, want about 10m", until)
+	}
+}
+
+func TestDoCancelledWhileWaiting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeErrors(w, http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client := newTestClient(server, WithBackoff(time.Minute, time.Minute))
+	_, err := client.Do(ctx, http.MethodGet, "/path", nil, nil)
+	var requestErr *RequestError
+	if !errors.As(err, &requestErr) || requestErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Do() error = %v, want the last 503", err)
+	}
+}
+
+func TestDoErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		handler      http.HandlerFunc
+		wantNotFound bool
+		wantCode     ErrorCode
+		wantErr      string
+	}{
+		{
+			name: "not found",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeErrors(w, http.StatusNotFound, CodeInvalidObjectIdentifier)
+			},
+			wantNotFound: true,
+			wantCode:     CodeInvalidObjectIdentifier,
+			wantErr:      "GET /path returned 404: 7003: error 7003",
+		},
+		{
+			name: "invalid identifier reported with 400",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeErrors(w, http.StatusBadRequest, CodeInvalidObjectIdentifier, CodeUnauthorized)
+			},
+			wantNotFound: true,
+			wantCode:     CodeUnauthorized,
+			wantErr:      "GET /path returned 400: 7003: error 7003; 9109: error 9109",
+		},
+		{
+			name: "unsuccessful response with 200",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeErrors(w, http.StatusOK, CodeAuthenticationError)
+			},
+			wantCode: CodeAuthenticationError,
+			wantErr:  "GET /path returned 200: 10000: error 10000",
+		},
+		{
+			name: "body that is not an API response",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte("<html>denied</html>"))
+			},
+			wantErr: "GET /path returned 403: <html>denied</html>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			_, err := newTestClient(server).Do(context.Background(), http.MethodGet, "/path", nil, nil)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("Do() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := IsNotFound(err); got != tt.wantNotFound {
+				t.Errorf("IsNotFound() = %v, want %v", got, tt.wantNotFound)
+			}
+			if tt.wantCode != 0 && !HasCode(err, tt.wantCode) {
+				t.Errorf("HasCode(%d) = false, want true", tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		name      string
+		pages     map[string]string
+		wantIDs   []string
+		wantPages []string
+	}{
+		{
+			name: "by page number",
+			pages: map[string]string{
+				"":  `"result_info": {"page": 1, "per_page": 2, "total_pages": 3}, "result": [{"id": "a"}, {"id": "b"}]`,
+				"2": `"result_info": {"page": 2, "per_page": 2, "total_pages": 3}, "result": [{"id": "c"}, {"id": "d"}]`,
+				"3": `"result_info": {"page": 3, "per_page": 2, "total_pages": 3}, "result": [{"id": "e"}]`,
+			},
+			wantIDs:   []string{"a", "b", "c", "d", "e"},
+			wantPages: []string{"", "2", "3"},
+		},
+		{
+			name: "by cursor",
+			pages: map[string]string{
+				"":   `"result_info": {"cursors": {"after": "c1"}}, "result": [{"id": "a"}]`,
+				"c1": `"result_info": {"cursors": {"after": "c2"}}, "result": [{"id": "b"}]`,
+				"c2": `"result_info": {"cursors": {}}, "result": [{"id": "c"}]`,
+			},
+			wantIDs:   []string{"a", "b", "c"},
+			wantPages: []string{"", "c1", "c2"},
+		},
+		{
+			name: "without result info",
+			pages: map[string]string{
+				"": `"result": [{"id": "a"}]`,
+			},
+			wantIDs:   []string{"a"},
+			wantPages: []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPages []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query := r.URL.Query()
+				if query.Get("per_page") != "100" || query.Get("kind") != "ip" {
+					t.Errorf("query = %s, want per_page=100 and kind=ip", r.URL.RawQuery)
+				}
+				page := query.Get("page") + query.Get("cursor")
+				gotPages = append(gotPages, page)
+				fmt.Fprintf(w, `{"success": true, "errors": [], "messages": [], %s}`, tt.pages[page])
+			}))
+			defer server.Close()
+
+			query := url.Values{"kind": {"ip"}}
+			var gotIDs []string
+			err := newTestClient(server).List(context.Background(), "/accounts/account-id/rules/lists", query, func(result json.RawMessage) error {
+				var items []struct {
+					ID string `json:"id"`
+				}
+				if err := json.Unmarshal(result, &items); err != nil {
+					return err
+				}
+				for _, item := range items {
+					gotIDs = append(gotIDs, item.ID)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+				t.Errorf("List() = %v, want %v", gotIDs, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(gotPages, tt.wantPages) {
+				t.Errorf("List() requested pages %q, want %q", gotPages, tt.wantPages)
+			}
+			if len(query) != 1 {
+				t.Errorf("List() modified the query to %v", query)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"", 0, 0},
+		{"30", 30 * time.Second, 30 * time.Second},
+		{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), 58 * time.Second, time.Minute},
+		{"soon", 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %s, want between %s and %s", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := New("token", WithBackoff(100*time.Millisecond, time.Second))
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			if got := c.backoff(attempt); got < want/2 || got > want {
+				t.Errorf("backoff(%d) = %s, want between %s and %s", attempt, got, want/2, want)
+			}
+		}
+	}
+
+	// Large attempts must not overflow into a negative or unbounded delay
+	if got := c.backoff(80); got < 500*time.Millisecond || got > time.Second {
+		t.Errorf("backoff(80) = %s, want between 500ms and 1s", got)
+	}
+}
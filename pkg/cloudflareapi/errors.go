@@ -0,0 +1,104 @@
+package cloudflareapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrorCode is a Cloudflare API error code
+type ErrorCode int
+
+const (
+	// CodeInvalidObjectIdentifier is returned for paths naming an object that doesn't exist
+	CodeInvalidObjectIdentifier ErrorCode = 7003
+
+	// CodeUnauthorized is returned when the token may not access the requested resource
+	CodeUnauthorized ErrorCode = 9109
+
+	// CodeAuthenticationError is returned when the token is missing or invalid
+	CodeAuthenticationError ErrorCode = 10000
+)
+
+// CloudflareError is an error reported by the Cloudflare API
+type CloudflareError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// Error implements the error interface
+func (e CloudflareError) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
+
+// RequestError is returned when a request fails, with the errors reported by the API, if any
+type RequestError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Errors     []CloudflareError
+	Body       string
+	RetryAt    time.Time
+	Err        error
+}
+
+// Error implements the error interface
+func (e *RequestError) Error() string {
+	var detail string
+	switch {
+	case len(e.Errors) > 0:
+		messages := make([]string, len(e.Errors))
+		for i, apiErr := range e.Errors {
+			messages[i] = apiErr.Error()
+		}
+		detail = strings.Join(messages, "; ")
+	case e.Err != nil:
+		detail = e.Err.Error()
+	default:
+		detail = e.Body
+	}
+
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s %s failed: %s", e.Method, e.Path, detail)
+	}
+	return fmt.Sprintf("%s %s returned %d: %s", e.Method, e.Path, e.StatusCode, detail)
+}
+
+// Unwrap returns the underlying error, if any
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// HasCode reports whether the API reported the given error code
+func (e *RequestError) HasCode(code ErrorCode) bool {
+	for _, apiErr := range e.Errors {
+		if apiErr.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// HasCode reports whether err is a RequestError with the given error code
+func HasCode(err error, code ErrorCode) bool {
+	var requestErr *RequestError
+	return errors.As(err, &requestErr) && requestErr.HasCode(code)
+}
+
+// IsNotFound reports whether err means the requested object doesn't exist
+func IsNotFound(err error) bool {
+	var requestErr *RequestError
+	return errors.As(err, &requestErr) &&
+		(requestErr.StatusCode == http.StatusNotFound || requestErr.HasCode(CodeInvalidObjectIdentifier))
+}
+
+// IsRateLimited returns when requests may resume if err is a rate limit that outlasted the retries
+func IsRateLimited(err error) (time.Time, bool) {
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) && requestErr.StatusCode == http.StatusTooManyRequests {
+		return requestErr.RetryAt, true
+	}
+	return time.Time{}, false
+}
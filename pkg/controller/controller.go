@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -13,10 +14,12 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
@@ -32,9 +35,23 @@ type SyncReconciler struct {
 	Log             logr.Logger
 	Scheme          *runtime.Scheme
 	Recorder        record.EventRecorder
-	ProviderCache   map[string]providers.Provider
-	IngressCache    map[string]ingress.Ingress
+	ProviderCache   map[string]cachedProvider
+	IngressCache    map[string]cachedIngress
 	SecretReader    SecretReader
+	ConfigMapReader ConfigMapReader
+	firstSeen       firstSeenTracker
+}
+
+// cachedProvider is a provider initialized from a given generation of its ProviderConfig
+type cachedProvider struct {
+	generation int64
+	provider   providers.Provider
+}
+
+// cachedIngress is an ingress initialized from a given generation of its IngressConfig
+type cachedIngress struct {
+	generation int64
+	ingress    ingress.Ingress
 }
 
 // SecretReader is an interface for reading secrets
@@ -69,6 +86,34 @@ func (r *DefaultSecretReader) GetSecret(ctx context.Context, namespace, name, ke
 	return "", fmt.Errorf("key %s not found in secret %s/%s", key, namespace, name)
 }
 
+// ConfigMapReader is an interface for reading ConfigMaps
+type ConfigMapReader interface {
+	GetConfigMapData(ctx context.Context, namespace, name, key string) (string, error)
+}
+
+// DefaultConfigMapReader is the default implementation of ConfigMapReader
+type DefaultConfigMapReader struct {
+	Client client.Client
+}
+
+// GetConfigMapData gets a value from the given ConfigMap name, namespace, and key
+func (r *DefaultConfigMapReader) GetConfigMapData(ctx context.Context, namespace, name, key string) (string, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, configMap); err != nil {
+		return "", err
+	}
+
+	if data, ok := configMap.Data[key]; ok {
+		return data, nil
+	}
+
+	if data, ok := configMap.BinaryData[key]; ok {
+		return string(data), nil
+	}
+
+	return "", fmt.Errorf("key %s not found in ConfigMap %s/%s", key, namespace, name)
+}
+
 // SetupWithManager sets up the controller with the Manager
 func SetupWithManager(mgr manager.Manager) error {
 	r := &SyncReconciler{
@@ -76,9 +121,10 @@ func SetupWithManager(mgr manager.Manager) error {
 		Log:             ctrl.Log.WithName("controllers").WithName("SyncConfig"),
 		Scheme:          mgr.GetScheme(),
 		Recorder:        mgr.GetEventRecorderFor("syncconfig-controller"),
-		ProviderCache:   make(map[string]providers.Provider),
-		IngressCache:    make(map[string]ingress.Ingress),
+		ProviderCache:   make(map[string]cachedProvider),
+		IngressCache:    make(map[string]cachedIngress),
 		SecretReader:    &DefaultSecretReader{Client: mgr.GetClient()},
+		ConfigMapReader: &DefaultConfigMapReader{Client: mgr.GetClient()},
 	}
 
 	// Watch for changes to SyncConfig
@@ -91,6 +137,16 @@ func SetupWithManager(mgr manager.Manager) error {
 		Watches(
 			&source.Kind{Type: &ingressmetasyncv1alpha1.IngressConfig{}},
 			handler.EnqueueRequestsFromMapFunc(r.findSyncConfigsForIngress),
+			// The controller writes IngressConfig status itself, so only react to spec changes
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		Watches(
+			&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(r.findSyncConfigsForConfigMap),
+		).
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.findSyncConfigsForSecret),
 		).
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: 4, // Limit concurrent reconciliations
@@ -150,6 +206,57 @@ func (r *SyncReconciler) findSyncConfigsForIngress(ingressObj client.Object) []r
 	return requests
 }
 
+// findSyncConfigsForConfigMap maps a ConfigMap to SyncConfigs whose providers read from it
+func (r *SyncReconciler) findSyncConfigsForConfigMap(ctx context.Context, configMapObj client.Object) []reconcile.Request {
+	return r.findSyncConfigsForProviderSource(ctx, func(spec *ingressmetasyncv1alpha1.ProviderConfigSpec) bool {
+		if spec.Text != nil && spec.Text.ConfigMapRef != nil &&
+			spec.Text.ConfigMapRef.Namespace == configMapObj.GetNamespace() && spec.Text.ConfigMapRef.Name == configMapObj.GetName() {
+			return true
+		}
+		if spec.Static != nil {
+			for _, staticSource := range spec.Static.Sources {
+				if staticSource.ConfigMapRef != nil &&
+					staticSource.ConfigMapRef.Namespace == configMapObj.GetNamespace() && staticSource.ConfigMapRef.Name == configMapObj.GetName() {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// findSyncConfigsForSecret maps a Secret to SyncConfigs whose providers read ranges from it
+func (r *SyncReconciler) findSyncConfigsForSecret(ctx context.Context, secretObj client.Object) []reconcile.Request {
+	return r.findSyncConfigsForProviderSource(ctx, func(spec *ingressmetasyncv1alpha1.ProviderConfigSpec) bool {
+		if spec.Static != nil {
+			for _, staticSource := range spec.Static.Sources {
+				if staticSource.SecretRef != nil &&
+					staticSource.SecretRef.Namespace == secretObj.GetNamespace() && staticSource.SecretRef.Name == secretObj.GetName() {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// findSyncConfigsForProviderSource maps ProviderConfigs matching the given predicate to the SyncConfigs that reference them
+func (r *SyncReconciler) findSyncConfigsForProviderSource(ctx context.Context, references func(spec *ingressmetasyncv1alpha1.ProviderConfigSpec) bool) []reconcile.Request {
+	var providerConfigs ingressmetasyncv1alpha1.ProviderConfigList
+	if err := r.List(ctx, &providerConfigs); err != nil {
+		r.Log.Error(err, "Unable to list ProviderConfigs")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range providerConfigs.Items {
+		if references(&providerConfigs.Items[i].Spec) {
+			requests = append(requests, r.findSyncConfigsForProvider(&providerConfigs.Items[i])...)
+		}
+	}
+	return requests
+}
+
 // Reconcile is part of the main kubernetes reconciliation loop
 func (r *SyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := r.Log.WithValues("syncconfig", req.NamespacedName)
@@ -177,6 +284,15 @@ func (r *SyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	// Collect IP ranges from all providers
 	allRanges, err := r.collectProviderIPRanges(ctx, &syncConfig)
 	if err != nil {
+		if _, ok := providers.IsRateLimited(err); ok {
+			// Returning the error would requeue with the controller's backoff instead of waiting for the reset
+			r.setStatusCondition(&syncConfig, "Ready", metav1.ConditionFalse, "RateLimited", err.Error())
+			if err := r.Status().Update(ctx, &syncConfig); err != nil {
+				log.Error(err, "Unable to update SyncConfig status")
+			}
+			return ctrl.Result{RequeueAfter: r.requeueAfter(&syncConfig)}, nil
+		}
+
 		r.setStatusCondition(&syncConfig, "Ready", metav1.ConditionFalse, "ProviderError", err.Error())
 		if err := r.Status().Update(ctx, &syncConfig); err != nil {
 			log.Error(err, "Unable to update SyncConfig status")
@@ -184,6 +300,13 @@ func (r *SyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return ctrl.Result{RequeueAfter: 5 * time.Minute}, err
 	}
 
+	// Collapse overlapping and adjacent ranges if requested
+	if syncConfig.Spec.Aggregate {
+		aggregated := allRanges.Aggregate()
+		log.Info("Aggregated IP ranges", "before", allRanges.Count(), "after", aggregated.Count())
+		allRanges = aggregated
+	}
+
 	// Apply IP ranges to all ingress services
 	if err := r.applyIPRangesToIngress(ctx, &syncConfig, allRanges); err != nil {
 		r.setStatusCondition(&syncConfig, "Ready", metav1.ConditionFalse, "IngressError", err.Error())
@@ -204,7 +327,21 @@ func (r *SyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	}
 
 	// Requeue for next sync - base on the lowest polling interval
-	return ctrl.Result{RequeueAfter: 1 * time.Minute}, nil
+	return ctrl.Result{RequeueAfter: r.requeueAfter(&syncConfig)}, nil
+}
+
+// requeueAfter returns the delay until the next sync, backing off until every rate-limited provider has reset
+func (r *SyncReconciler) requeueAfter(syncConfig *ingressmetasyncv1alpha1.SyncConfig) time.Duration {
+	requeueAfter := 1 * time.Minute
+	for _, providerStatus := range syncConfig.Status.ProviderStatus {
+		if providerStatus.RateLimitedUntil == nil {
+			continue
+		}
+		if wait := time.Until(providerStatus.RateLimitedUntil.Time); wait > requeueAfter {
+			requeueAfter = wait
+		}
+	}
+	return requeueAfter
 }
 
 // setStatusCondition sets a condition in the status
@@ -244,8 +381,13 @@ func (r *SyncReconciler) setStatusCondition(syncConfig *ingressmetasyncv1alpha1.
 // collectProviderIPRanges collects IP ranges from all providers in the SyncConfig
 func (r *SyncReconciler) collectProviderIPRanges(ctx context.Context, syncConfig *ingressmetasyncv1alpha1.SyncConfig) (*model.IPRangeSet, error) {
 	allRanges := model.NewIPRangeSet()
+	subtractRanges := model.NewIPRangeSet()
 	updatedProviderStatus := make([]ingressmetasyncv1alpha1.ProviderSyncStatus, 0)
 
+	// First-seen times are persisted so provenance survives restarts
+	r.loadFirstSeen(ctx)
+	defer r.saveFirstSeen(ctx)
+
 	for _, providerRef := range syncConfig.Spec.Providers {
 		providerStatus := ingressmetasyncv1alpha1.ProviderSyncStatus{
 			Name:         providerRef.Name,
@@ -253,6 +395,9 @@ func (r *SyncReconciler) collectProviderIPRanges(ctx context.Context, syncConfig
 			Status:       "Pending",
 		}
 
+		// Skipping a failed subtracted provider would allow the ranges it removes, so always fail
+		failFast := providerRef.Subtract || (syncConfig.Spec.SyncPolicy != nil && syncConfig.Spec.SyncPolicy.FailureMode == "fail")
+
 		// Fetch the ProviderConfig
 		var providerConfig ingressmetasyncv1alpha1.ProviderConfig
 		if err := r.Get(ctx, types.NamespacedName{Name: providerRef.Name}, &providerConfig); err != nil {
@@ -261,7 +406,7 @@ func (r *SyncReconciler) collectProviderIPRanges(ctx context.Context, syncConfig
 			providerStatus.Status = "Error"
 			updatedProviderStatus = append(updatedProviderStatus, providerStatus)
 			
-			if syncConfig.Spec.SyncPolicy != nil && syncConfig.Spec.SyncPolicy.FailureMode == "fail" {
+			if failFast {
 				return nil, fmt.Errorf(errMsg)
 			}
 			
@@ -277,7 +422,7 @@ func (r *SyncReconciler) collectProviderIPRanges(ctx context.Context, syncConfig
 			providerStatus.Status = "Error"
 			updatedProviderStatus = append(updatedProviderStatus, providerStatus)
 			
-			if syncConfig.Spec.SyncPolicy != nil && syncConfig.Spec.SyncPolicy.FailureMode == "fail" {
+			if failFast {
 				return nil, fmt.Errorf(errMsg)
 			}
 			
@@ -291,9 +436,19 @@ func (r *SyncReconciler) collectProviderIPRanges(ctx context.Context, syncConfig
 			errMsg := fmt.Sprintf("Unable to fetch IP ranges: %v", err)
 			providerStatus.Error = errMsg
 			providerStatus.Status = "Error"
+			rateLimitErr, rateLimited := providers.IsRateLimited(err)
+			if rateLimited {
+				providerStatus.Status = "RateLimited"
+				providerStatus.RateLimitedUntil = &metav1.Time{Time: rateLimitErr.RetryAt}
+			}
 			updatedProviderStatus = append(updatedProviderStatus, providerStatus)
 			
-			if syncConfig.Spec.SyncPolicy != nil && syncConfig.Spec.SyncPolicy.FailureMode == "fail" {
+			if failFast {
+				if rateLimited {
+					// Keep the reset time in the status so the retry can wait for it
+					syncConfig.Status.ProviderStatus = updatedProviderStatus
+					return nil, fmt.Errorf("Unable to fetch IP ranges: %w", err)
+				}
 				return nil, fmt.Errorf(errMsg)
 			}
 			
@@ -301,6 +456,10 @@ func (r *SyncReconciler) collectProviderIPRanges(ctx context.Context, syncConfig
 			continue
 		}
 
+		// Attribute the ranges to this provider so they can be traced after merging
+		providerRanges = providerRanges.Stamp(providerConfig.Name, providerConfig.Spec.Type,
+			r.firstSeen.observe(providerConfig.Name, providerRanges, time.Now()))
+
 		// Filter ranges if include/exclude are specified
 		filteredRanges := providerRanges
 		if len(providerRef.IncludeRanges) > 0 || len(providerRef.ExcludeRanges) > 0 {
@@ -313,26 +472,84 @@ func (r *SyncReconciler) collectProviderIPRanges(ctx context.Context, syncConfig
 				"excludeFilters", providerRef.ExcludeRanges)
 		}
 
-		// Merge with all ranges
-		allRanges = allRanges.Merge(filteredRanges)
+		// Apply the label selector if specified
+		if providerRef.Selector != "" {
+			selector, err := model.ParseSelector(providerRef.Selector)
+			if err != nil {
+				errMsg := fmt.Sprintf("Invalid selector: %v", err)
+				providerStatus.Error = errMsg
+				providerStatus.Status = "Error"
+				updatedProviderStatus = append(updatedProviderStatus, providerStatus)
+				
+				if failFast {
+					return nil, fmt.Errorf(errMsg)
+				}
+				
+				r.Log.Error(err, "Invalid selector", "provider", providerRef.Name)
+				continue
+			}
+
+			selectedRanges := filteredRanges.Select(selector)
+			r.Log.Info("Selected IP ranges",
+				"provider", providerRef.Name,
+				"before", filteredRanges.Count(),
+				"after", selectedRanges.Count(),
+				"selector", providerRef.Selector)
+			filteredRanges = selectedRanges
+		}
+
+		// Keep only the selected address family
+		familyRanges := filteredRanges.FilterFamily(providerRef.AddressFamily)
+		droppedCount := filteredRanges.Count() - familyRanges.Count()
+		if droppedCount > 0 {
+			r.Log.Info("Dropped IP ranges of unselected address family",
+				"provider", providerRef.Name,
+				"addressFamily", providerRef.AddressFamily,
+				"dropped", droppedCount)
+		}
+		filteredRanges = familyRanges
+
+		// Merge with all ranges, or set aside ranges to remove from the union
+		if providerRef.Subtract {
+			subtractRanges = subtractRanges.Merge(filteredRanges)
+		} else {
+			allRanges = allRanges.Merge(filteredRanges)
+		}
 
 		// Update provider status
 		providerStatus.Status = "Success"
 		providerStatus.IPRangesCount = int32(filteredRanges.Count())
+		ipv4Count, ipv6Count := filteredRanges.CountByFamily()
+		providerStatus.IPv4RangesCount = int32(ipv4Count)
+		providerStatus.IPv6RangesCount = int32(ipv6Count)
+		providerStatus.DroppedRangesCount = int32(droppedCount)
+		if reporter, ok := providerInstance.(providers.StatusReporter); ok {
+			providerStatus.Warnings = reporter.Warnings()
+		}
 		updatedProviderStatus = append(updatedProviderStatus, providerStatus)
 	}
 
 	// Update the SyncConfig status with provider status
 	syncConfig.Status.ProviderStatus = updatedProviderStatus
 
+	// Remove the subtracted ranges from the union of the other providers
+	if subtractRanges.Count() > 0 {
+		remaining := allRanges.Subtract(subtractRanges)
+		r.Log.Info("Subtracted IP ranges",
+			"before", allRanges.Count(),
+			"subtracted", subtractRanges.Count(),
+			"after", remaining.Count())
+		allRanges = remaining
+	}
+
 	return allRanges, nil
 }
 
 // getOrCreateProvider gets an existing provider from cache or creates a new one
 func (r *SyncReconciler) getOrCreateProvider(ctx context.Context, providerConfig *ingressmetasyncv1alpha1.ProviderConfig) (providers.Provider, error) {
-	// Check if we already have this provider in the cache
-	if provider, ok := r.ProviderCache[providerConfig.Name]; ok {
-		return provider, nil
+	// Reuse the cached provider unless the ProviderConfig spec has changed since it was initialized
+	if cached, ok := r.ProviderCache[providerConfig.Name]; ok && cached.generation == providerConfig.Generation {
+		return cached.provider, nil
 	}
 
 	// Create a new provider instance
@@ -344,12 +561,20 @@ func (r *SyncReconciler) getOrCreateProvider(ctx context.Context, providerConfig
 	// Initialize provider with the appropriate configuration
 	options := make(map[string]interface{})
 	options["name"] = providerConfig.Name
+	if providerConfig.Spec.HostBits != "" {
+		options["hostBits"] = providerConfig.Spec.HostBits
+	}
 
 	// Add type-specific configuration
 	switch providerConfig.Spec.Type {
 	case "github":
 		if providerConfig.Spec.GitHub != nil {
-			options["enterprise"] = providerConfig.Spec.GitHub.Enterprise
+			if providerConfig.Spec.GitHub.BaseURL != "" {
+				options["baseURL"] = providerConfig.Spec.GitHub.BaseURL
+			}
+			if providerConfig.Spec.GitHub.APIVersion != "" {
+				options["apiVersion"] = providerConfig.Spec.GitHub.APIVersion
+			}
 			if providerConfig.Spec.GitHub.PollingInterval != "" {
 				options["cacheTTL"] = providerConfig.Spec.GitHub.PollingInterval
 			}
@@ -367,6 +592,25 @@ func (r *SyncReconciler) getOrCreateProvider(ctx context.Context, providerConfig
 				}
 				options["apiToken"] = apiToken
 			}
+
+			// Get GitHub App private key from secret if specified
+			if app := providerConfig.Spec.GitHub.API.App; app != nil {
+				privateKey, err := r.SecretReader.GetSecret(
+					ctx,
+					app.PrivateKeySecretRef.Namespace,
+					app.PrivateKeySecretRef.Name,
+					app.PrivateKeySecretRef.Key,
+				)
+				if err != nil {
+					return nil, fmt.Errorf("error reading GitHub App private key: %w", err)
+				}
+				options["appID"] = app.AppID
+				options["appInstallationID"] = app.InstallationID
+				options["appPrivateKey"] = privateKey
+				if app.TokenURL != "" {
+					options["appTokenURL"] = app.TokenURL
+				}
+			}
 		}
 	case "aws":
 		if providerConfig.Spec.AWS != nil {
@@ -379,6 +623,9 @@ func (r *SyncReconciler) getOrCreateProvider(ctx context.Context, providerConfig
 			if providerConfig.Spec.AWS.PollingInterval != "" {
 				options["cacheTTL"] = providerConfig.Spec.AWS.PollingInterval
 			}
+			if providerConfig.Spec.AWS.URL != "" {
+				options["url"] = providerConfig.Spec.AWS.URL
+			}
 
 			// Get API credentials from secret if specified
 			if providerConfig.Spec.AWS.API.SecretRef.Name != "" {
@@ -405,6 +652,166 @@ func (r *SyncReconciler) getOrCreateProvider(ctx context.Context, providerConfig
 				options["secretKey"] = secretKey
 			}
 		}
+	case "gcp":
+		if providerConfig.Spec.GCP != nil {
+			if len(providerConfig.Spec.GCP.URLs) > 0 {
+				options["urls"] = providerConfig.Spec.GCP.URLs
+			}
+			if len(providerConfig.Spec.GCP.Scopes) > 0 {
+				options["scopes"] = providerConfig.Spec.GCP.Scopes
+			}
+			if len(providerConfig.Spec.GCP.Services) > 0 {
+				options["services"] = providerConfig.Spec.GCP.Services
+			}
+			if providerConfig.Spec.GCP.PollingInterval != "" {
+				options["cacheTTL"] = providerConfig.Spec.GCP.PollingInterval
+			}
+		}
+	case "azure":
+		if providerConfig.Spec.Azure != nil {
+			if providerConfig.Spec.Azure.URL != "" {
+				options["url"] = providerConfig.Spec.Azure.URL
+			}
+			if providerConfig.Spec.Azure.File != "" {
+				options["file"] = providerConfig.Spec.Azure.File
+			}
+			if len(providerConfig.Spec.Azure.ServiceTags) > 0 {
+				options["serviceTags"] = providerConfig.Spec.Azure.ServiceTags
+			}
+			if len(providerConfig.Spec.Azure.Regions) > 0 {
+				options["regions"] = providerConfig.Spec.Azure.Regions
+			}
+			if providerConfig.Spec.Azure.PollingInterval != "" {
+				options["cacheTTL"] = providerConfig.Spec.Azure.PollingInterval
+			}
+		}
+	case "http":
+		if providerConfig.Spec.HTTP != nil {
+			options["url"] = providerConfig.Spec.HTTP.URL
+			if len(providerConfig.Spec.HTTP.Headers) > 0 {
+				options["headers"] = providerConfig.Spec.HTTP.Headers
+			}
+			if providerConfig.Spec.HTTP.PollingInterval != "" {
+				options["cacheTTL"] = providerConfig.Spec.HTTP.PollingInterval
+			}
+
+			// Mappings are passed in order, as several may share a path with different labels
+			mappings := make([]map[string]string, 0, len(providerConfig.Spec.HTTP.Mappings))
+			for _, mapping := range providerConfig.Spec.HTTP.Mappings {
+				mappings = append(mappings, map[string]string{"path": mapping.Path, "label": mapping.Label})
+			}
+			options["mappings"] = mappings
+
+			// Get credential from secret if specified
+			if providerConfig.Spec.HTTP.API != nil && providerConfig.Spec.HTTP.API.SecretRef.Name != "" {
+				credential, err := r.SecretReader.GetSecret(
+					ctx,
+					providerConfig.Spec.HTTP.API.SecretRef.Namespace,
+					providerConfig.Spec.HTTP.API.SecretRef.Name,
+					providerConfig.Spec.HTTP.API.SecretRef.Key,
+				)
+				if err != nil {
+					return nil, fmt.Errorf("error reading HTTP provider credential: %w", err)
+				}
+
+				header := providerConfig.Spec.HTTP.API.Header
+				if header == "" {
+					header = "Authorization"
+				}
+				if header == "Authorization" {
+					scheme := providerConfig.Spec.HTTP.API.Scheme
+					if scheme == "" {
+						scheme = "Bearer"
+					}
+					credential = scheme + " " + credential
+				}
+				options["authHeader"] = header
+				options["authValue"] = credential
+			}
+		}
+	case "text":
+		if providerConfig.Spec.Text != nil {
+			if providerConfig.Spec.Text.URL != "" {
+				options["url"] = providerConfig.Spec.Text.URL
+			}
+			if providerConfig.Spec.Text.Format != "" {
+				options["format"] = providerConfig.Spec.Text.Format
+			}
+			if providerConfig.Spec.Text.CommentPrefix != "" {
+				options["commentPrefix"] = providerConfig.Spec.Text.CommentPrefix
+			}
+			options["cidrColumn"] = providerConfig.Spec.Text.CIDRColumn
+			if len(providerConfig.Spec.Text.LabelColumns) > 0 {
+				options["labelColumns"] = providerConfig.Spec.Text.LabelColumns
+			}
+			if len(providerConfig.Spec.Text.Labels) > 0 {
+				options["labels"] = providerConfig.Spec.Text.Labels
+			}
+			if providerConfig.Spec.Text.PollingInterval != "" {
+				options["cacheTTL"] = providerConfig.Spec.Text.PollingInterval
+			}
+
+			// Read the list from the ConfigMap on every fetch so edits are picked up
+			if ref := providerConfig.Spec.Text.ConfigMapRef; ref != nil && ref.Name != "" {
+				options["contentLoader"] = func(ctx context.Context) (string, error) {
+					return r.ConfigMapReader.GetConfigMapData(ctx, ref.Namespace, ref.Name, ref.Key)
+				}
+			}
+		}
+	case "static":
+		if providerConfig.Spec.Static != nil {
+			ranges := make([]model.IPRange, 0, len(providerConfig.Spec.Static.Ranges))
+			for _, staticRange := range providerConfig.Spec.Static.Ranges {
+				ranges = append(ranges, model.IPRange{CIDR: staticRange.CIDR, Labels: staticRange.Labels})
+			}
+			options["ranges"] = ranges
+
+			// Sources are read on every fetch; ConfigMap and Secret watches trigger the reconcile
+			sources := make([]map[string]interface{}, 0, len(providerConfig.Spec.Static.Sources))
+			for _, staticSource := range providerConfig.Spec.Static.Sources {
+				switch {
+				case staticSource.ConfigMapRef != nil:
+					ref := *staticSource.ConfigMapRef
+					sources = append(sources, map[string]interface{}{
+						"description": fmt.Sprintf("ConfigMap %s/%s key %s", ref.Namespace, ref.Name, ref.Key),
+						"labels":      staticSource.Labels,
+						"loader": func(ctx context.Context) (string, error) {
+							return r.ConfigMapReader.GetConfigMapData(ctx, ref.Namespace, ref.Name, ref.Key)
+						},
+					})
+				case staticSource.SecretRef != nil:
+					ref := *staticSource.SecretRef
+					sources = append(sources, map[string]interface{}{
+						"description": fmt.Sprintf("Secret %s/%s key %s", ref.Namespace, ref.Name, ref.Key),
+						"labels":      staticSource.Labels,
+						"loader": func(ctx context.Context) (string, error) {
+							return r.SecretReader.GetSecret(ctx, ref.Namespace, ref.Name, ref.Key)
+						},
+					})
+				default:
+					return nil, fmt.Errorf("static source must reference a ConfigMap or a Secret")
+				}
+			}
+			options["sources"] = sources
+		}
+	case "dns":
+		if providerConfig.Spec.DNS != nil {
+			if len(providerConfig.Spec.DNS.SPFDomains) > 0 {
+				options["spfDomains"] = providerConfig.Spec.DNS.SPFDomains
+			}
+			if len(providerConfig.Spec.DNS.Hostnames) > 0 {
+				options["hostnames"] = providerConfig.Spec.DNS.Hostnames
+			}
+			if providerConfig.Spec.DNS.Resolver != "" {
+				options["resolver"] = providerConfig.Spec.DNS.Resolver
+			}
+			if providerConfig.Spec.DNS.MaxDepth != 0 {
+				options["maxDepth"] = providerConfig.Spec.DNS.MaxDepth
+			}
+			if providerConfig.Spec.DNS.PollingInterval != "" {
+				options["cacheTTL"] = providerConfig.Spec.DNS.PollingInterval
+			}
+		}
 	}
 
 	// Initialize the provider
@@ -413,7 +820,7 @@ func (r *SyncReconciler) getOrCreateProvider(ctx context.Context, providerConfig
 	}
 
 	// Cache the provider for future use
-	r.ProviderCache[providerConfig.Name] = providerInstance
+	r.ProviderCache[providerConfig.Name] = cachedProvider{generation: providerConfig.Generation, provider: providerInstance}
 
 	return providerInstance, nil
 }
@@ -445,6 +852,29 @@ func (r *SyncReconciler) applyIPRangesToIngress(ctx context.Context, syncConfig
 			continue
 		}
 
+		// Keep only the address family the ingress accepts
+		familyRanges := ipRanges.FilterFamily(ingressConfig.Spec.AddressFamily)
+		droppedCount := ipRanges.Count() - familyRanges.Count()
+
+		// Fit the ranges into the ingress entry budget
+		ingressRanges, overAdmitted, err := fitIngressBudget(&ingressConfig, familyRanges)
+		if err != nil {
+			errMsg := fmt.Sprintf("Unable to fit IP ranges into ingress budget: %v", err)
+			ingressStatus.Error = errMsg
+			ingressStatus.Status = "Error"
+			updatedIngressStatus = append(updatedIngressStatus, ingressStatus)
+			
+			if syncConfig.Spec.SyncPolicy != nil && syncConfig.Spec.SyncPolicy.FailureMode == "fail" {
+				return fmt.Errorf(errMsg)
+			}
+			
+			r.Log.Error(err, "Unable to fit IP ranges into ingress budget", "ingress", ingressRef.Name)
+			continue
+		}
+		if overAdmitted != nil && overAdmitted.Sign() > 0 {
+			ingressStatus.OverAdmittedAddresses = overAdmitted.String()
+		}
+
 		// Get the ingress instance from cache or create a new one
 		ingressInstance, err := r.getOrCreateIngress(ctx, &ingressConfig)
 		if err != nil {
@@ -462,7 +892,25 @@ func (r *SyncReconciler) applyIPRangesToIngress(ctx context.Context, syncConfig
 		}
 
 		// Apply IP ranges to the ingress
-		if err := ingressInstance.ApplyIPRanges(ctx, ipRanges); err != nil {
+		err = ingressInstance.ApplyIPRanges(ctx, ingressRanges)
+		r.updateIngressConfigStatus(ctx, &ingressConfig, ingressInstance, ingressRanges, err)
+		if err != nil {
+			if partialErr, ok := ingress.IsPartiallyApplied(err); ok {
+				ingressStatus.AddedCount = int32(partialErr.Added)
+				ingressStatus.RemovedCount = int32(partialErr.Removed)
+				ingressStatus.Failures = partialErr.Failures
+				ingressStatus.Error = fmt.Sprintf("Unable to apply all IP ranges: %v", err)
+				ingressStatus.Status = "PartiallyApplied"
+				updatedIngressStatus = append(updatedIngressStatus, ingressStatus)
+				
+				if syncConfig.Spec.SyncPolicy != nil && syncConfig.Spec.SyncPolicy.FailureMode == "fail" {
+					return fmt.Errorf(ingressStatus.Error)
+				}
+				
+				r.Log.Error(err, "IP ranges were only partially applied", "ingress", ingressRef.Name)
+				continue
+			}
+			
 			errMsg := fmt.Sprintf("Unable to apply IP ranges: %v", err)
 			ingressStatus.Error = errMsg
 			ingressStatus.Status = "Error"
@@ -478,7 +926,11 @@ func (r *SyncReconciler) applyIPRangesToIngress(ctx context.Context, syncConfig
 
 		// Update ingress status
 		ingressStatus.Status = "Success"
-		ingressStatus.IPRangesCount = int32(ipRanges.Count())
+		ingressStatus.IPRangesCount = int32(ingressRanges.Count())
+		ipv4Count, ipv6Count := ingressRanges.CountByFamily()
+		ingressStatus.IPv4RangesCount = int32(ipv4Count)
+		ingressStatus.IPv6RangesCount = int32(ipv6Count)
+		ingressStatus.DroppedRangesCount = int32(droppedCount)
 		updatedIngressStatus = append(updatedIngressStatus, ingressStatus)
 	}
 
@@ -488,11 +940,90 @@ func (r *SyncReconciler) applyIPRangesToIngress(ctx context.Context, syncConfig
 	return nil
 }
 
+// updateIngressConfigStatus records the outcome of applying ranges on the IngressConfig itself,
+// including the outcome for each target of ingresses applying to several targets and the
+// objects the ingress manages
+func (r *SyncReconciler) updateIngressConfigStatus(ctx context.Context, ingressConfig *ingressmetasyncv1alpha1.IngressConfig, ingressInstance ingress.Ingress, ipRanges *model.IPRangeSet, applyErr error) {
+	now := metav1.Time{Time: time.Now()}
+	ingressConfig.Status.LastSyncTime = &now
+	if applyErr != nil {
+		ingressConfig.Status.LastSyncError = applyErr.Error()
+	} else {
+		ingressConfig.Status.LastSuccessfulSync = &now
+		ingressConfig.Status.LastSyncError = ""
+		ingressConfig.Status.IPRangesCount = int32(ipRanges.Count())
+	}
+
+	if reporter, ok := ingressInstance.(ingress.TargetReporter); ok {
+		targets := reporter.TargetStatuses()
+		zones := make([]ingressmetasyncv1alpha1.ZoneSyncStatus, 0, len(targets))
+		for _, target := range targets {
+			zoneStatus := ingressmetasyncv1alpha1.ZoneSyncStatus{
+				ZoneID:       target.ID,
+				ZoneName:     target.Name,
+				LastSyncTime: &metav1.Time{Time: target.LastSyncTime},
+				Status:       "Success",
+			}
+			if target.Error != "" {
+				zoneStatus.Status = "Error"
+				zoneStatus.Error = target.Error
+			}
+			zones = append(zones, zoneStatus)
+		}
+		ingressConfig.Status.Zones = zones
+	}
+
+	if reporter, ok := ingressInstance.(ingress.ObjectReporter); ok {
+		objects := reporter.ManagedObjects()
+		managedObjects := make([]ingressmetasyncv1alpha1.ManagedObjectStatus, 0, len(objects))
+		for _, object := range objects {
+			managedObjects = append(managedObjects, ingressmetasyncv1alpha1.ManagedObjectStatus{
+				Kind:  object.Kind,
+				ID:    object.ID,
+				Scope: object.Scope,
+			})
+		}
+		ingressConfig.Status.ManagedObjects = managedObjects
+	}
+
+	if err := r.Status().Update(ctx, ingressConfig); err != nil {
+		r.Log.Error(err, "Unable to update IngressConfig status", "ingress", ingressConfig.Name)
+	}
+}
+
+// fitIngressBudget compresses the ranges to the IngressConfig's maxEntries and returns the
+// number of extra addresses admitted. It fails rather than exceed maxOverAdmission.
+func fitIngressBudget(ingressConfig *ingressmetasyncv1alpha1.IngressConfig, ipRanges *model.IPRangeSet) (*model.IPRangeSet, *big.Int, error) {
+	maxEntries := int(ingressConfig.Spec.MaxEntries)
+	if maxEntries <= 0 || ipRanges.Count() <= maxEntries {
+		return ipRanges, nil, nil
+	}
+
+	tolerance := new(big.Int)
+	if ingressConfig.Spec.MaxOverAdmission != "" {
+		if _, ok := tolerance.SetString(ingressConfig.Spec.MaxOverAdmission, 10); !ok {
+			return nil, nil, fmt.Errorf("invalid maxOverAdmission '%s'", ingressConfig.Spec.MaxOverAdmission)
+		}
+	}
+
+	compressed, overAdmitted, err := ipRanges.Compress(maxEntries)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if overAdmitted.Cmp(tolerance) > 0 {
+		return nil, nil, fmt.Errorf("fitting %d ranges into %d entries admits %s extra addresses, more than maxOverAdmission %s",
+			ipRanges.Count(), maxEntries, overAdmitted, tolerance)
+	}
+
+	return compressed, overAdmitted, nil
+}
+
 // getOrCreateIngress gets an existing ingress from cache or creates a new one
 func (r *SyncReconciler) getOrCreateIngress(ctx context.Context, ingressConfig *ingressmetasyncv1alpha1.IngressConfig) (ingress.Ingress, error) {
-	// Check if we already have this ingress in the cache
-	if ingressInstance, ok := r.IngressCache[ingressConfig.Name]; ok {
-		return ingressInstance, nil
+	// Reuse the cached ingress unless the IngressConfig spec has changed since it was initialized
+	if cached, ok := r.IngressCache[ingressConfig.Name]; ok && cached.generation == ingressConfig.Generation {
+		return cached.ingress, nil
 	}
 
 	// Create a new ingress instance
@@ -525,6 +1056,18 @@ func (r *SyncReconciler) getOrCreateIngress(ctx context.Context, ingressConfig *
 
 			// Add rule configuration
 			options["zoneId"] = ingressConfig.Spec.Cloudflare.RuleConfig.ZoneID
+			
+			if len(ingressConfig.Spec.Cloudflare.RuleConfig.ZoneIDs) > 0 {
+				options["zoneIds"] = ingressConfig.Spec.Cloudflare.RuleConfig.ZoneIDs
+			}
+			
+			if ingressConfig.Spec.Cloudflare.RuleConfig.ZoneSelector != "" {
+				options["zoneSelector"] = ingressConfig.Spec.Cloudflare.RuleConfig.ZoneSelector
+			}
+			
+			if ingressConfig.Spec.Cloudflare.RuleConfig.ZoneConcurrency != 0 {
+				options["zoneConcurrency"] = ingressConfig.Spec.Cloudflare.RuleConfig.ZoneConcurrency
+			}
 			options["ruleName"] = ingressConfig.Spec.Cloudflare.RuleConfig.RuleName
 			
 			if ingressConfig.Spec.Cloudflare.RuleConfig.Description != "" {
@@ -542,6 +1085,41 @@ func (r *SyncReconciler) getOrCreateIngress(ctx context.Context, ingressConfig *
 			if ingressConfig.Spec.Cloudflare.UpdateStrategy != "" {
 				options["updateStrategy"] = ingressConfig.Spec.Cloudflare.UpdateStrategy
 			}
+			
+			if ingressConfig.Spec.Cloudflare.Engine != "" {
+				options["engine"] = ingressConfig.Spec.Cloudflare.Engine
+			}
+			
+			if ingressConfig.Spec.Cloudflare.API.BaseURL != "" {
+				options["baseURL"] = ingressConfig.Spec.Cloudflare.API.BaseURL
+			}
+			
+			if ingressConfig.Spec.Cloudflare.RuleConfig.AccountID != "" {
+				options["accountId"] = ingressConfig.Spec.Cloudflare.RuleConfig.AccountID
+			}
+			
+			if ingressConfig.Spec.Cloudflare.RuleConfig.ListName != "" {
+				options["listName"] = ingressConfig.Spec.Cloudflare.RuleConfig.ListName
+			}
+			
+			// Managed objects are tagged as owned by this IngressConfig and found again by ID
+			options["ownerUID"] = string(ingressConfig.UID)
+			options["adopt"] = ingressConfig.Spec.Cloudflare.Adopt
+			
+			managedObjects := make([]ingress.ManagedObject, 0, len(ingressConfig.Status.ManagedObjects))
+			for _, object := range ingressConfig.Status.ManagedObjects {
+				managedObjects = append(managedObjects, ingress.ManagedObject{Kind: object.Kind, ID: object.ID, Scope: object.Scope})
+			}
+			options["managedObjects"] = managedObjects
+			
+			// List mode takes the list settings from the list configuration
+			if list := ingressConfig.Spec.Cloudflare.List; list != nil {
+				options["mode"] = "list"
+				options["accountId"] = list.AccountID
+				options["listName"] = list.Name
+				options["listDescription"] = list.Description
+				options["createRule"] = list.CreateRule
+			}
 		}
 	case "istio":
 		if ingressConfig.Spec.Istio != nil {
@@ -570,7 +1148,7 @@ func (r *SyncReconciler) getOrCreateIngress(ctx context.Context, ingressConfig *
 	}
 
 	// Cache the ingress for future use
-	r.IngressCache[ingressConfig.Name] = ingressInstance
+	r.IngressCache[ingressConfig.Name] = cachedIngress{generation: ingressConfig.Generation, ingress: ingressInstance}
 
 	return ingressInstance, nil
 }
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	ingressmetasyncv1alpha1 "github.com/galbakal/k8s-ingress-meta-sync/pkg/apis/ingressmetasync/v1alpha1"
+)
+
+func newTestReconciler(t *testing.T) *SyncReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := ingressmetasyncv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	providerConfigs := []*ingressmetasyncv1alpha1.ProviderConfig{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "office-text"},
+			Spec: ingressmetasyncv1alpha1.ProviderConfigSpec{
+				Text: &ingressmetasyncv1alpha1.TextProviderConfig{
+					ConfigMapRef: &ingressmetasyncv1alpha1.ConfigMapReference{Namespace: "default", Name: "office-ranges", Key: "ranges"},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "office-static"},
+			Spec: ingressmetasyncv1alpha1.ProviderConfigSpec{
+				Static: &ingressmetasyncv1alpha1.StaticProviderConfig{
+					Sources: []ingressmetasyncv1alpha1.StaticSource{
+						{ConfigMapRef: &ingressmetasyncv1alpha1.ConfigMapReference{Namespace: "default", Name: "office-ranges", Key: "more"}},
+						{SecretRef: &ingressmetasyncv1alpha1.SecretReference{Namespace: "default", Name: "vpn-ranges", Key: "ranges"}},
+					},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "unrelated"},
+			Spec: ingressmetasyncv1alpha1.ProviderConfigSpec{
+				Static: &ingressmetasyncv1alpha1.StaticProviderConfig{
+					Ranges: []ingressmetasyncv1alpha1.StaticIPRange{{CIDR: "192.0.2.0/24"}},
+				},
+			},
+		},
+	}
+	syncConfigs := []*ingressmetasyncv1alpha1.SyncConfig{
+		newSyncConfig("office", "office-text"),
+		newSyncConfig("vpn", "office-static"),
+		newSyncConfig("other", "unrelated"),
+	}
+
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for _, providerConfig := range providerConfigs {
+		builder = builder.WithObjects(providerConfig)
+	}
+	for _, syncConfig := range syncConfigs {
+		builder = builder.WithObjects(syncConfig)
+	}
+	return &SyncReconciler{Client: builder.Build(), Log: logr.Discard(), Scheme: scheme}
+}
+
+func newSyncConfig(name string, providerNames ...string) *ingressmetasyncv1alpha1.SyncConfig {
+	syncConfig := &ingressmetasyncv1alpha1.SyncConfig{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	for _, providerName := range providerNames {
+		syncConfig.Spec.Providers = append(syncConfig.Spec.Providers, ingressmetasyncv1alpha1.ProviderReference{Name: providerName})
+	}
+	return syncConfig
+}
+
+func TestSourceChangesEnqueueSyncConfigs(t *testing.T) {
+	r := newTestReconciler(t)
+
+	tests := []struct {
+		name    string
+		handler handler.EventHandler
+		old     func(meta metav1.ObjectMeta) event.UpdateEvent
+		object  *metav1.ObjectMeta
+		want    []string
+	}{
+		{
+			name:    "ConfigMap read by text and static providers",
+			handler: handler.EnqueueRequestsFromMapFunc(r.findSyncConfigsForConfigMap),
+			old:     configMapUpdate,
+			object:  &metav1.ObjectMeta{Namespace: "default", Name: "office-ranges"},
+			want:    []string{"office", "vpn"},
+		},
+		{
+			name:    "ConfigMap in another namespace",
+			handler: handler.EnqueueRequestsFromMapFunc(r.findSyncConfigsForConfigMap),
+			old:     configMapUpdate,
+			object:  &metav1.ObjectMeta{Namespace: "other", Name: "office-ranges"},
+		},
+		{
+			name:    "Secret read by a static provider",
+			handler: handler.EnqueueRequestsFromMapFunc(r.findSyncConfigsForSecret),
+			old:     secretUpdate,
+			object:  &metav1.ObjectMeta{Namespace: "default", Name: "vpn-ranges"},
+			want:    []string{"vpn"},
+		},
+		{
+			name:    "unreferenced Secret",
+			handler: handler.EnqueueRequestsFromMapFunc(r.findSyncConfigsForSecret),
+			old:     secretUpdate,
+			object:  &metav1.ObjectMeta{Namespace: "default", Name: "api-token"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			defer queue.ShutDown()
+
+			tt.handler.Update(context.Background(), tt.old(*tt.object), queue)
+
+			var got []string
+			for queue.Len() > 0 {
+				item, _ := queue.Get()
+				got = append(got, item.(reconcile.Request).Name)
+				queue.Done(item)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("enqueued %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func configMapUpdate(meta metav1.ObjectMeta) event.UpdateEvent {
+	return event.UpdateEvent{
+		ObjectOld: &corev1.ConfigMap{ObjectMeta: meta, Data: map[string]string{"ranges": "192.0.2.0/24"}},
+		ObjectNew: &corev1.ConfigMap{ObjectMeta: meta, Data: map[string]string{"ranges": "198.51.100.0/24"}},
+	}
+}
+
+func secretUpdate(meta metav1.ObjectMeta) event.UpdateEvent {
+	return event.UpdateEvent{
+		ObjectOld: &corev1.Secret{ObjectMeta: meta, Data: map[string][]byte{"ranges": []byte("192.0.2.0/24")}},
+		ObjectNew: &corev1.Secret{ObjectMeta: meta, Data: map[string][]byte{"ranges": []byte("198.51.100.0/24")}},
+	}
+}
@@ -0,0 +1,249 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+)
+
+const (
+	// firstSeenConfigMapName is the ConfigMap persisting when each provider first returned each prefix
+	firstSeenConfigMapName = "ingress-meta-sync-first-seen"
+
+	// defaultControllerNamespace is used when the controller's namespace is not set in POD_NAMESPACE
+	defaultControllerNamespace = "ingress-meta-sync-system"
+)
+
+// controllerNamespace returns the namespace the controller runs in
+func controllerNamespace() string {
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	return defaultControllerNamespace
+}
+
+// firstSeenTracker remembers when each provider first returned each prefix. The times are
+// loaded from and saved to a ConfigMap so they survive restarts and leader changes.
+type firstSeenTracker struct {
+	mutex  sync.Mutex
+	seen   map[string]map[netip.Prefix]time.Time
+	dirty  map[string]bool
+	loaded bool
+}
+
+// observe records the prefixes currently returned by a provider, forgetting those it no longer
+// returns, and returns a lookup of their first-seen times
+func (t *firstSeenTracker) observe(providerName string, ipRanges *model.IPRangeSet, now time.Time) func(netip.Prefix) time.Time {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.seen == nil {
+		t.seen = make(map[string]map[netip.Prefix]time.Time)
+	}
+	if t.dirty == nil {
+		t.dirty = make(map[string]bool)
+	}
+
+	// Times are persisted with second precision, so keep them that way in memory too
+	now = now.UTC().Truncate(time.Second)
+
+	previous := t.seen[providerName]
+	current := make(map[netip.Prefix]time.Time, ipRanges.Count())
+	for _, ipRange := range ipRanges.Ranges {
+		if firstSeen, ok := previous[ipRange.Prefix]; ok {
+			current[ipRange.Prefix] = firstSeen
+		} else {
+			current[ipRange.Prefix] = now
+		}
+	}
+	if len(current) != len(previous) {
+		t.dirty[providerName] = true
+	} else {
+		for prefix := range current {
+			if _, ok := previous[prefix]; !ok {
+				t.dirty[providerName] = true
+				break
+			}
+		}
+	}
+	t.seen[providerName] = current
+
+	return func(prefix netip.Prefix) time.Time {
+		return current[prefix]
+	}
+}
+
+// restore loads the first-seen times saved in the ConfigMap data, keeping any earlier time already
+// tracked. A provider whose entry cannot be decoded is skipped and reported.
+func (t *firstSeenTracker) restore(data map[string]string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.seen == nil {
+		t.seen = make(map[string]map[netip.Prefix]time.Time)
+	}
+	t.loaded = true
+
+	var firstErr error
+	for providerName, value := range data {
+		if err := t.restoreProvider(providerName, value); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// restoreProvider loads the saved first-seen times of one provider
+func (t *firstSeenTracker) restoreProvider(providerName, value string) error {
+	var byTime map[string][]string
+	if err := json.Unmarshal([]byte(value), &byTime); err != nil {
+		return fmt.Errorf("error unmarshaling first-seen times of provider %s: %w", providerName, err)
+	}
+
+	seen := t.seen[providerName]
+	if seen == nil {
+		seen = make(map[netip.Prefix]time.Time)
+		t.seen[providerName] = seen
+	}
+	for timestamp, cidrs := range byTime {
+		firstSeen, err := time.Parse(time.RFC3339, timestamp)
+		if err != nil {
+			return fmt.Errorf("invalid first-seen time '%s' for provider %s: %w", timestamp, providerName, err)
+		}
+		for _, cidr := range cidrs {
+			prefix, err := model.ParsePrefix(cidr)
+			if err != nil {
+				continue
+			}
+			if existing, ok := seen[prefix]; !ok || firstSeen.Before(existing) {
+				seen[prefix] = firstSeen
+			}
+		}
+	}
+	return nil
+}
+
+// changes returns the encoded first-seen times of every provider changed since the last save.
+// Prefixes are grouped by time, as most of a provider's prefixes are first seen together.
+func (t *firstSeenTracker) changes() (map[string]string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	changes := make(map[string]string, len(t.dirty))
+	for providerName := range t.dirty {
+		byTime := make(map[string][]string)
+		for prefix, firstSeen := range t.seen[providerName] {
+			timestamp := firstSeen.UTC().Format(time.RFC3339)
+			byTime[timestamp] = append(byTime[timestamp], prefix.String())
+		}
+		for _, cidrs := range byTime {
+			sort.Strings(cidrs)
+		}
+
+		value, err := json.Marshal(byTime)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling first-seen times of provider %s: %w", providerName, err)
+		}
+		changes[providerName] = string(value)
+	}
+	return changes, nil
+}
+
+// saved clears the changed flag of the given providers
+func (t *firstSeenTracker) saved(changes map[string]string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for providerName := range changes {
+		delete(t.dirty, providerName)
+	}
+}
+
+// isLoaded reports whether the saved first-seen times have been loaded
+func (t *firstSeenTracker) isLoaded() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.loaded
+}
+
+// loadFirstSeen loads the persisted first-seen times once, before the first provider is observed
+func (r *SyncReconciler) loadFirstSeen(ctx context.Context) {
+	if r.firstSeen.isLoaded() {
+		return
+	}
+
+	configMap := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: controllerNamespace(), Name: firstSeenConfigMapName}, configMap)
+	if err != nil && !errors.IsNotFound(err) {
+		// Retried on the next reconcile; until then prefixes are timed from now
+		r.Log.Error(err, "Unable to load first-seen times")
+		return
+	}
+
+	if err := r.firstSeen.restore(configMap.Data); err != nil {
+		r.Log.Error(err, "Unable to restore first-seen times")
+	}
+}
+
+// saveFirstSeen persists the first-seen times of the providers that changed
+func (r *SyncReconciler) saveFirstSeen(ctx context.Context) {
+	changes, err := r.firstSeen.changes()
+	if err != nil {
+		r.Log.Error(err, "Unable to encode first-seen times")
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	configMap := &corev1.ConfigMap{}
+	key := types.NamespacedName{Namespace: controllerNamespace(), Name: firstSeenConfigMapName}
+	if err := r.Get(ctx, key, configMap); err != nil {
+		if !errors.IsNotFound(err) {
+			r.Log.Error(err, "Unable to get first-seen ConfigMap")
+			return
+		}
+
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "ingress-meta-sync",
+				},
+			},
+			Data: changes,
+		}
+		if err := r.Create(ctx, configMap); err != nil {
+			r.Log.Error(err, "Unable to create first-seen ConfigMap")
+			return
+		}
+		r.firstSeen.saved(changes)
+		return
+	}
+
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string, len(changes))
+	}
+	for providerName, value := range changes {
+		configMap.Data[providerName] = value
+	}
+	if err := r.Update(ctx, configMap); err != nil {
+		// The providers stay marked as changed and are saved on the next reconcile
+		r.Log.Error(err, "Unable to update first-seen ConfigMap")
+		return
+	}
+	r.firstSeen.saved(changes)
+}
@@ -1,15 +1,13 @@
 package cloudflare
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"sync"
 	"time"
 
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/cloudflareapi"
 	"github.com/galbakal/k8s-ingress-meta-sync/pkg/ingress"
 	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -24,8 +22,21 @@ func init() {
 // CloudflareIngress implements the Ingress interface for Cloudflare
 type CloudflareIngress struct {
 	name          string
-	apiToken      string
+	client        *cloudflareapi.Client
 	zoneID        string
+	engine        string
+	accountID     string
+	listName      string
+	listID        string
+	listDescription string
+	mode          string
+	manageRule    bool
+	zoneIDs       []string
+	zoneSelector  string
+	zoneConcurrency int
+	zones         map[string]*CloudflareIngress
+	zoneStatuses  []ingress.TargetStatus
+	zonesMutex    sync.Mutex
 	ruleName      string
 	description   string
 	action        string
@@ -35,9 +46,23 @@ type CloudflareIngress struct {
 	lastFetch     time.Time
 	cachedData    *model.IPRangeSet
 	cacheMutex    sync.RWMutex
-	httpClient    *http.Client
+	migrationPending bool
+	ownerRef      string
+	adopt         bool
+	knownObjects  map[objectKey]string
+	filterID      string
+	firewallRuleID string
+	ruleID        string
 }
 
+const (
+	// modeRule keeps the ranges in a zone rule, inline or in a list depending on the update strategy
+	modeRule = "rule"
+
+	// modeList keeps the ranges in an account-level IP list that rules in any zone can reference
+	modeList = "list"
+)
+
 var log = ctrl.Log.WithName("ingress.cloudflare")
 
 // Name returns the ingress name
@@ -56,9 +81,9 @@ func (c *CloudflareIngress) Init(ctx context.Context, options map[string]interfa
 	c.action = "allow"
 	c.cacheTTL = 1 * time.Hour
 	c.updateStrategy = "direct"
-	c.httpClient = &http.Client{
-		Timeout: 30 * time.Second,
-	}
+	c.engine = engineRulesets
+	c.mode = modeRule
+	c.zoneConcurrency = defaultZoneConcurrency
 
 	// Process options
 	if name, ok := options["name"].(string); ok {
@@ -68,21 +93,53 @@ func (c *CloudflareIngress) Init(ctx context.Context, options map[string]interfa
 	}
 
 	// Required options
-	if apiToken, ok := options["apiToken"].(string); ok {
-		c.apiToken = apiToken
-	} else {
+	apiToken, ok := options["apiToken"].(string)
+	if !ok {
 		return fmt.Errorf("apiToken is required")
 	}
 
-	if zoneID, ok := options["zoneId"].(string); ok {
-		c.zoneID = zoneID
-	} else {
-		return fmt.Errorf("zoneId is required")
+	baseURL, _ := options["baseURL"].(string)
+	c.client = cloudflareapi.New(apiToken, cloudflareapi.WithBaseURL(baseURL))
+
+	if mode, ok := options["mode"].(string); ok && mode != "" {
+		if mode != modeRule && mode != modeList {
+			return fmt.Errorf("unknown mode: %s", mode)
+		}
+		c.mode = mode
+	}
+
+	// In list mode the rules referencing the list are left to humans unless createRule is set
+	c.manageRule = c.mode == modeRule
+	if createRule, ok := options["createRule"].(bool); ok && createRule {
+		c.manageRule = true
+	}
+
+	if zoneIDs, ok := options["zoneIds"].([]string); ok {
+		c.zoneIDs = zoneIDs
+	}
+
+	if zoneSelector, ok := options["zoneSelector"].(string); ok {
+		c.zoneSelector = zoneSelector
+	}
+
+	if zoneConcurrency, ok := options["zoneConcurrency"].(int32); ok && zoneConcurrency > 0 {
+		c.zoneConcurrency = int(zoneConcurrency)
 	}
 
-	if ruleName, ok := options["ruleName"].(string); ok {
+	if zoneID, ok := options["zoneId"].(string); ok && zoneID != "" {
+		if c.multiZone() {
+			// Fan out to the single zone as well
+			c.zoneIDs = append([]string{zoneID}, c.zoneIDs...)
+		} else {
+			c.zoneID = zoneID
+		}
+	} else if c.manageRule && !c.multiZone() {
+		return fmt.Errorf("zoneId, zoneIds or zoneSelector is required")
+	}
+
+	if ruleName, ok := options["ruleName"].(string); ok && ruleName != "" {
 		c.ruleName = ruleName
-	} else {
+	} else if c.manageRule {
 		return fmt.Errorf("ruleName is required")
 	}
 
@@ -105,6 +162,59 @@ func (c *CloudflareIngress) Init(ctx context.Context, options map[string]interfa
 		c.updateStrategy = updateStrategy
 	}
 
+	if engine, ok := options["engine"].(string); ok && engine != "" {
+		if engine != engineRulesets && engine != engineFirewallRules {
+			return fmt.Errorf("unknown rule engine: %s", engine)
+		}
+		c.engine = engine
+	}
+
+	if c.engine == engineRulesets {
+		if err := validateRulesetAction(c.action); err != nil {
+			return err
+		}
+	}
+
+	if accountID, ok := options["accountId"].(string); ok {
+		c.accountID = accountID
+	}
+
+	if listName, ok := options["listName"].(string); ok && listName != "" {
+		c.listName = listName
+	} else if c.mode == modeList {
+		return fmt.Errorf("listName is required in list mode")
+	} else {
+		c.listName = defaultListName(c.ruleName)
+	}
+
+	if listDescription, ok := options["listDescription"].(string); ok && listDescription != "" {
+		c.listDescription = listDescription
+	} else {
+		c.listDescription = c.description
+	}
+
+	// Managed objects are tagged with a ref derived from the IngressConfig UID
+	if ownerUID, ok := options["ownerUID"].(string); ok && ownerUID != "" {
+		c.ownerRef = ownershipRef(ownerUID)
+	} else {
+		c.ownerRef = ownershipRef(c.name)
+	}
+
+	if adopt, ok := options["adopt"].(bool); ok {
+		c.adopt = adopt
+	}
+
+	managedObjects, _ := options["managedObjects"].([]ingress.ManagedObject)
+	c.restoreObjects(managedObjects)
+
+	if c.mode == modeList && c.accountID == "" {
+		return fmt.Errorf("accountId is required in list mode")
+	}
+
+	if c.updateStrategy == "incremental" && c.accountID == "" {
+		return fmt.Errorf("accountId is required for the incremental update strategy")
+	}
+
 	if cacheTTL, ok := options["cacheTTL"].(string); ok {
 		duration, err := time.ParseDuration(cacheTTL)
 		if err != nil {
@@ -116,7 +226,12 @@ func (c *CloudflareIngress) Init(ctx context.Context, options map[string]interfa
 	log.Info("Initialized Cloudflare ingress", 
 		"name", c.name, 
 		"zoneID", c.zoneID, 
+		"zoneIDs", c.zoneIDs,
+		"zoneSelector", c.zoneSelector,
 		"ruleName", c.ruleName,
+		"mode", c.mode,
+		"listName", c.listName,
+		"engine", c.engine,
 		"updateStrategy", c.updateStrategy)
 
 	return nil
@@ -130,6 +245,7 @@ type CloudflareRule struct {
 	Action      string `json:"action"`
 	Priority    int32  `json:"priority,omitempty"`
 	Filter      Filter `json:"filter"`
+	Ref         string `json:"ref,omitempty"`
 }
 
 // Filter represents a Cloudflare firewall filter
@@ -138,24 +254,16 @@ type Filter struct {
 	Expression  string `json:"expression"`
 	Paused      bool   `json:"paused"`
 	Description string `json:"description"`
-}
-
-// CloudflareResponse represents a response from the Cloudflare API
-type CloudflareResponse struct {
-	Success  bool              `json:"success"`
-	Errors   []CloudflareError `json:"errors"`
-	Messages []string          `json:"messages"`
-	Result   json.RawMessage   `json:"result"`
-}
-
-// CloudflareError represents an error from the Cloudflare API
-type CloudflareError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Ref         string `json:"ref,omitempty"`
 }
 
 // GetCurrentIPRanges gets the current IP ranges configured in Cloudflare
 func (c *CloudflareIngress) GetCurrentIPRanges(ctx context.Context) (*model.IPRangeSet, error) {
+	// Each zone caches its own rule
+	if c.multiZone() && !c.usesList() {
+		return c.getZonesIPRanges(ctx)
+	}
+
 	// Check if we have a valid cache
 	c.cacheMutex.RLock()
 	if c.cachedData != nil && time.Since(c.lastFetch) < c.cacheTTL {
@@ -175,29 +283,46 @@ func (c *CloudflareIngress) GetCurrentIPRanges(ctx context.Context) (*model.IPRa
 		return c.cachedData, nil
 	}
 
-	// First, get all filters to find our filter
-	filterID, err := c.findFilterID(ctx)
+	// In list mode and incremental mode the ranges live in the IP list
+	if c.usesList() {
+		ipRanges, err := c.getListIPRanges(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Info("Got current Cloudflare IP list items", "ingress", c.name, "list", c.listName, "count", ipRanges.Count())
+
+		// The list may be up to date while the rule referencing it still has to be migrated
+		if c.manageRule && c.engine == engineRulesets && !c.multiZone() {
+			_, rule, err := c.findRulesetRule(ctx)
+			if err != nil {
+				return nil, err
+			}
+			c.migrationPending = rule == nil
+		}
+
+		c.cachedData = ipRanges
+		c.lastFetch = time.Now()
+		return ipRanges, nil
+	}
+
+	// Get the expression of our rule
+	expression, enabled, err := c.getRuleExpression(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if filterID == "" {
-		// Filter doesn't exist yet, return empty set
-		log.Info("Filter doesn't exist yet", "ingress", c.name, "ruleName", c.ruleName)
+	if expression == "" || !enabled {
+		// Rule doesn't exist yet or is disabled because there are no ranges, return empty set
+		log.Info("Rule doesn't exist yet or is disabled", "ingress", c.name, "ruleName", c.ruleName)
 		emptySet := model.NewIPRangeSet()
 		c.cachedData = emptySet
 		c.lastFetch = time.Now()
 		return emptySet, nil
 	}
 
-	// Get the filter details
-	filterDetails, err := c.getFilterDetails(ctx, filterID)
-	if err != nil {
-		return nil, err
-	}
-
 	// Parse the filter expression to extract IP ranges
-	ipRanges := c.parseFilterExpression(filterDetails.Expression)
+	ipRanges := c.parseFilterExpression(expression)
 
 	log.Info("Got current Cloudflare IP ranges", "ingress", c.name, "count", ipRanges.Count())
 
@@ -208,141 +333,73 @@ func (c *CloudflareIngress) GetCurrentIPRanges(ctx context.Context) (*model.IPRa
 	return ipRanges, nil
 }
 
-// parseFilterExpression parses a Cloudflare filter expression to extract IP ranges
+// parseFilterExpression parses a Cloudflare filter expression to extract the IP ranges of its managed clause
 func (c *CloudflareIngress) parseFilterExpression(expression string) *model.IPRangeSet {
-	// Example expression: (ip.src in {1.2.3.4/32 5.6.7.8/32})
-	// We need to extract the CIDRs
-	
-	ipRangeSet := model.NewIPRangeSet()
-	
-	// Very simple parser for now - this could be improved
-	// This assumes the expression is in the format (ip.src in {cidr1 cidr2 ...})
-	
-	// Find the opening brace
-	openBrace := 0
-	for i, char := range expression {
-		if char == '{' {
-			openBrace = i
-			break
-		}
-	}
-	
-	// Find the closing brace
-	closeBrace := 0
-	for i := len(expression) - 1; i >= 0; i-- {
-		if expression[i] == '}' {
-			closeBrace = i
-			break
-		}
-	}
-	
-	if openBrace == 0 || closeBrace == 0 || openBrace >= closeBrace {
-		log.Error(fmt.Errorf("invalid filter expression format"), "Failed to parse filter expression", "expression", expression)
-		return ipRangeSet
-	}
-	
-	// Extract the CIDRs
-	cidrsStr := expression[openBrace+1:closeBrace]
-	cidrs := make([]string, 0)
-	
-	// Split by space
-	for _, cidr := range bytes.Fields([]byte(cidrsStr)) {
-		cidrs = append(cidrs, string(cidr))
-	}
-	
-	// Add to the IP range set
-	for _, cidr := range cidrs {
-		if err := ipRangeSet.Add(cidr, []string{"cloudflare"}); err != nil {
-			log.Error(err, "Error adding CIDR to IP range set", "cidr", cidr)
-		}
+	// Example expression: (ip.src in {1.2.3.4/32 5.6.7.8/32}) and http.host eq "example.com"
+	parsed, err := parseExpression(expression)
+	if err != nil {
+		log.Error(err, "Failed to parse filter expression", "expression", expression)
+		return model.NewIPRangeSet()
 	}
 	
-	return ipRangeSet
+	return parsed.ranges()
 }
 
-// findFilterID finds the filter ID for the given rule name
-func (c *CloudflareIngress) findFilterID(ctx context.Context) (string, error) {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/filters", c.zoneID)
-	
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
+// findFilter returns the managed filter, or nil if there is none. A filter created or adopted
+// earlier is found by ID or ref; one merely described as the rule name is only adopted if allowed.
+func (c *CloudflareIngress) findFilter(ctx context.Context) (*Filter, error) {
+	if c.filterID != "" {
+		filter, err := c.getFilterDetails(ctx, c.filterID)
+		if err == nil {
+			return filter, nil
+		}
+		if !cloudflareapi.IsNotFound(err) {
+			return nil, err
+		}
+		c.filterID = ""
 	}
 	
-	req.Header.Set("Authorization", "Bearer "+c.apiToken)
-	req.Header.Set("Content-Type", "application/json")
-	
-	resp, err := c.httpClient.Do(req)
+	var owned, unowned *Filter
+	err := c.client.List(ctx, fmt.Sprintf("/zones/%s/filters", c.zoneID), nil, func(result json.RawMessage) error {
+		var filters []Filter
+		if err := json.Unmarshal(result, &filters); err != nil {
+			return fmt.Errorf("error unmarshaling filters: %w", err)
+		}
+		
+		for i := range filters {
+			switch {
+			case filters[i].Ref == c.ownerRef && owned == nil:
+				owned = &filters[i]
+			case filters[i].Description == c.ruleName && unowned == nil:
+				unowned = &filters[i]
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("error getting filters: %w", err)
+		return nil, fmt.Errorf("error getting filters: %w", err)
 	}
-	defer resp.Body.Close()
 	
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API returned non-OK status: %d, body: %s", resp.StatusCode, body)
+	switch {
+	case owned != nil:
+		c.filterID = owned.ID
+		return owned, nil
+	case unowned == nil:
+		return nil, nil
+	case !c.adopt:
+		return nil, c.notOwnedError(objectFilter, unowned.ID, unowned.Description)
 	}
 	
-	var response CloudflareResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", fmt.Errorf("error decoding response: %w", err)
-	}
-	
-	if !response.Success {
-		return "", fmt.Errorf("API request was not successful: %v", response.Errors)
-	}
-	
-	var filters []Filter
-	if err := json.Unmarshal(response.Result, &filters); err != nil {
-		return "", fmt.Errorf("error unmarshaling filters: %w", err)
-	}
-	
-	// Find the filter with the matching description
-	for _, filter := range filters {
-		if filter.Description == c.ruleName {
-			return filter.ID, nil
-		}
-	}
-	
-	// Filter not found
-	return "", nil
+	log.Info("Adopting existing Cloudflare filter", "ingress", c.name, "ruleName", c.ruleName, "filterID", unowned.ID)
+	c.filterID = unowned.ID
+	return unowned, nil
 }
 
 // getFilterDetails gets the details of a filter
 func (c *CloudflareIngress) getFilterDetails(ctx context.Context, filterID string) (*Filter, error) {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/filters/%s", c.zoneID, filterID)
-	
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
-	}
-	
-	req.Header.Set("Authorization", "Bearer "+c.apiToken)
-	req.Header.Set("Content-Type", "application/json")
-	
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error getting filter details: %w", err)
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned non-OK status: %d, body: %s", resp.StatusCode, body)
-	}
-	
-	var response CloudflareResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
-	}
-	
-	if !response.Success {
-		return nil, fmt.Errorf("API request was not successful: %v", response.Errors)
-	}
-	
 	var filter Filter
-	if err := json.Unmarshal(response.Result, &filter); err != nil {
-		return nil, fmt.Errorf("error unmarshaling filter: %w", err)
+	if _, err := c.client.Do(ctx, "GET", fmt.Sprintf("/zones/%s/filters/%s", c.zoneID, filterID), nil, &filter); err != nil {
+		return nil, fmt.Errorf("error getting filter details: %w", err)
 	}
 	
 	return &filter, nil
@@ -351,6 +408,11 @@ func (c *CloudflareIngress) getFilterDetails(ctx context.Context, filterID strin
 // ApplyIPRanges applies the given IP ranges to Cloudflare
 func (c *CloudflareIngress) ApplyIPRanges(ctx context.Context, ipRanges *model.IPRangeSet) error {
 	log.Info("Applying IP ranges to Cloudflare", "ingress", c.name, "count", ipRanges.Count())
+
+	// Zones are diffed separately, and zones matching the selector may have been added since the last sync
+	if c.multiZone() {
+		return c.applyToZones(ctx, ipRanges)
+	}
 	
 	// Get current IP ranges for diff
 	currentRanges, err := c.GetCurrentIPRanges(ctx)
@@ -361,132 +423,82 @@ func (c *CloudflareIngress) ApplyIPRanges(ctx context.Context, ipRanges *model.I
 	added, removed := currentRanges.Diff(ipRanges)
 	log.Info("IP range diff", "ingress", c.name, "added", added.Count(), "removed", removed.Count())
 	
-	// If no changes, we're done
-	if added.Count() == 0 && removed.Count() == 0 {
+	// If no changes, we're done, unless the rule still has to be migrated to rulesets
+	if added.Count() == 0 && removed.Count() == 0 && !c.migrationPending {
 		log.Info("No changes to apply", "ingress", c.name)
 		return nil
 	}
 	
-	// Based on update strategy
-	if c.updateStrategy == "direct" || currentRanges.Count() == 0 {
+	// Based on mode and update strategy
+	if c.usesList() {
+		// List mode or incremental update - add/remove specific items of the IP list
+		return c.applyIncremental(ctx, ipRanges)
+	} else if c.updateStrategy == "direct" {
 		// Direct update - replace the entire rule
 		return c.createOrUpdateRule(ctx, ipRanges)
-	} else if c.updateStrategy == "incremental" {
-		// Incremental update - add/remove specific IPs
-		// Note: This would require implementing more complex logic to update the existing rule
-		// For simplicity, we'll just do the direct update for now
-		return c.createOrUpdateRule(ctx, ipRanges)
 	}
 	
 	return fmt.Errorf("unknown update strategy: %s", c.updateStrategy)
 }
 
-// createOrUpdateRule creates or updates a Cloudflare firewall rule
+// createOrUpdateRule creates or updates the Cloudflare rule
 func (c *CloudflareIngress) createOrUpdateRule(ctx context.Context, ipRanges *model.IPRangeSet) error {
-	// Check if rule already exists
-	filterID, err := c.findFilterID(ctx)
+	current, enabled, err := c.getRuleExpression(ctx)
 	if err != nil {
-		return fmt.Errorf("error finding filter ID: %w", err)
-	}
-	
-	// Build the expression
-	expression := c.buildFilterExpression(ipRanges)
-	
-	if filterID == "" {
-		// Create new rule
-		log.Info("Creating new Cloudflare rule", "ingress", c.name, "ruleName", c.ruleName)
-		return c.createRule(ctx, expression)
+		return fmt.Errorf("error getting rule expression: %w", err)
 	}
 	
-	// Update existing rule
-	log.Info("Updating existing Cloudflare rule", "ingress", c.name, "ruleName", c.ruleName, "filterID", filterID)
-	return c.updateRule(ctx, filterID, expression)
-}
-
-// buildFilterExpression builds a Cloudflare filter expression from IP ranges
-func (c *CloudflareIngress) buildFilterExpression(ipRanges *model.IPRangeSet) string {
-	cidrs := ipRanges.GetCIDRs()
-	
-	if len(cidrs) == 0 {
-		// Empty rule
-		return "(ip.src eq 0.0.0.0/32)"
+	// Cloudflare rejects empty sets, so a rule without ranges is disabled rather than matching a placeholder
+	if ipRanges.Count() == 0 {
+		if current == "" || !enabled {
+			c.cacheExpression("")
+			return nil
+		}
+		log.Info("Disabling Cloudflare rule without IP ranges", "ingress", c.name, "ruleName", c.ruleName)
+		return c.setRuleExpression(ctx, current, false)
 	}
 	
-	// Combine all CIDRs
-	cidrList := ""
-	for i, cidr := range cidrs {
-		if i > 0 {
-			cidrList += " "
-		}
-		cidrList += cidr
+	// Build the expression, keeping any clauses added around the managed one
+	expression, err := buildExpression(current, rangesValue(ipRanges))
+	if err != nil {
+		return err
 	}
 	
-	return fmt.Sprintf("(ip.src in {%s})", cidrList)
+	return c.setRuleExpression(ctx, expression, true)
 }
 
 // createRule creates a new Cloudflare firewall rule
 func (c *CloudflareIngress) createRule(ctx context.Context, expression string) error {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/filters", c.zoneID)
-	
 	// Create filter first
 	filter := Filter{
 		Expression:  expression,
 		Paused:      false,
 		Description: c.ruleName,
-	}
-	
-	filterData, err := json.Marshal([]Filter{filter})
-	if err != nil {
-		return fmt.Errorf("error marshaling filter: %w", err)
-	}
-	
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(filterData))
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
-	}
-	
-	req.Header.Set("Authorization", "Bearer "+c.apiToken)
-	req.Header.Set("Content-Type", "application/json")
-	
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("error creating filter: %w", err)
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API returned non-OK status: %d, body: %s", resp.StatusCode, body)
-	}
-	
-	var response CloudflareResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return fmt.Errorf("error decoding response: %w", err)
-	}
-	
-	if !response.Success {
-		return fmt.Errorf("API request was not successful: %v", response.Errors)
+		Ref:         c.ownerRef,
 	}
 	
 	var filters []Filter
-	if err := json.Unmarshal(response.Result, &filters); err != nil {
-		return fmt.Errorf("error unmarshaling filters: %w", err)
+	if _, err := c.client.Do(ctx, "POST", fmt.Sprintf("/zones/%s/filters", c.zoneID), []Filter{filter}, &filters); err != nil {
+		return fmt.Errorf("error creating filter: %w", err)
 	}
 	
 	if len(filters) == 0 {
 		return fmt.Errorf("no filter was created")
 	}
-	
-	filterID := filters[0].ID
+	c.filterID = filters[0].ID
 	
 	// Now create the rule
-	return c.createFirewallRule(ctx, filterID)
+	if err := c.createFirewallRule(ctx, filters[0].ID); err != nil {
+		return err
+	}
+	
+	c.cacheExpression(expression)
+	
+	return nil
 }
 
 // createFirewallRule creates a new Cloudflare firewall rule
 func (c *CloudflareIngress) createFirewallRule(ctx context.Context, filterID string) error {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/firewall/rules", c.zoneID)
-	
 	rule := CloudflareRule{
 		Filter: Filter{
 			ID: filterID,
@@ -494,121 +506,91 @@ func (c *CloudflareIngress) createFirewallRule(ctx context.Context, filterID str
 		Action:      c.action,
 		Description: c.description,
 		Paused:      false,
+		Ref:         c.ownerRef,
 	}
 	
 	if c.priority != 0 {
 		rule.Priority = c.priority
 	}
 	
-	ruleData, err := json.Marshal([]CloudflareRule{rule})
-	if err != nil {
-		return fmt.Errorf("error marshaling rule: %w", err)
+	var rules []CloudflareRule
+	if _, err := c.client.Do(ctx, "POST", fmt.Sprintf("/zones/%s/firewall/rules", c.zoneID), []CloudflareRule{rule}, &rules); err != nil {
+		return fmt.Errorf("error creating firewall rule: %w", err)
 	}
 	
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(ruleData))
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+	if len(rules) > 0 {
+		rule.ID = rules[0].ID
+		c.firewallRuleID = rule.ID
 	}
 	
-	req.Header.Set("Authorization", "Bearer "+c.apiToken)
-	req.Header.Set("Content-Type", "application/json")
+	log.Info("Successfully created Cloudflare rule", "ingress", c.name, "ruleID", rule.ID)
 	
-	resp, err := c.httpClient.Do(req)
+	return nil
+}
+
+// findFirewallRule returns the firewall rule using the given filter, or nil if there is none
+func (c *CloudflareIngress) findFirewallRule(ctx context.Context, filterID string) (*CloudflareRule, error) {
+	var found *CloudflareRule
+	err := c.client.List(ctx, fmt.Sprintf("/zones/%s/firewall/rules", c.zoneID), nil, func(result json.RawMessage) error {
+		var rules []CloudflareRule
+		if err := json.Unmarshal(result, &rules); err != nil {
+			return fmt.Errorf("error unmarshaling firewall rules: %w", err)
+		}
+
+		for i := range rules {
+			if rules[i].Filter.ID == filterID && found == nil {
+				found = &rules[i]
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("error creating firewall rule: %w", err)
+		return nil, fmt.Errorf("error getting firewall rules: %w", err)
 	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API returned non-OK status: %d, body: %s", resp.StatusCode, body)
+
+	c.firewallRuleID = ""
+	if found != nil {
+		c.firewallRuleID = found.ID
 	}
-	
-	var response CloudflareResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return fmt.Errorf("error decoding response: %w", err)
+	return found, nil
+}
+
+// setFirewallRulePaused pauses or resumes the firewall rule using the given filter, tagging it as ours
+func (c *CloudflareIngress) setFirewallRulePaused(ctx context.Context, filterID string, paused bool) error {
+	rule, err := c.findFirewallRule(ctx, filterID)
+	if err != nil {
+		return err
 	}
-	
-	if !response.Success {
-		return fmt.Errorf("API request was not successful: %v", response.Errors)
+	if rule == nil || (rule.Paused == paused && rule.Ref == c.ownerRef) {
+		return nil
 	}
-	
-	// Update cache
-	ipRangeSet := model.NewIPRangeSet()
-	for _, cidr := range c.parseFilterExpression(rule.Filter.Expression).GetCIDRs() {
-		if err := ipRangeSet.Add(cidr, []string{"cloudflare"}); err != nil {
-			log.Error(err, "Error adding CIDR to IP range set", "cidr", cidr)
-		}
+
+	rule.Paused = paused
+	rule.Ref = c.ownerRef
+	if _, err := c.client.Do(ctx, "PUT", fmt.Sprintf("/zones/%s/firewall/rules/%s", c.zoneID, rule.ID), rule, nil); err != nil {
+		return fmt.Errorf("error updating firewall rule %s: %w", rule.ID, err)
 	}
-	
-	c.cacheMutex.Lock()
-	c.cachedData = ipRangeSet
-	c.lastFetch = time.Now()
-	c.cacheMutex.Unlock()
-	
-	log.Info("Successfully created Cloudflare rule", "ingress", c.name, "ruleID", rule.ID)
-	
 	return nil
 }
 
 // updateRule updates an existing Cloudflare firewall rule
 func (c *CloudflareIngress) updateRule(ctx context.Context, filterID string, expression string) error {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/filters/%s", c.zoneID, filterID)
-	
 	filter := Filter{
-		ID:         filterID,
-		Expression: expression,
-		Paused:     false,
-	}
-	
-	filterData, err := json.Marshal(filter)
-	if err != nil {
-		return fmt.Errorf("error marshaling filter: %w", err)
-	}
-	
-	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(filterData))
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		ID:          filterID,
+		Expression:  expression,
+		Paused:      false,
+		Description: c.ruleName,
+		Ref:         c.ownerRef,
 	}
 	
-	req.Header.Set("Authorization", "Bearer "+c.apiToken)
-	req.Header.Set("Content-Type", "application/json")
-	
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
+	if _, err := c.client.Do(ctx, "PUT", fmt.Sprintf("/zones/%s/filters/%s", c.zoneID, filterID), filter, nil); err != nil {
 		return fmt.Errorf("error updating filter: %w", err)
 	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API returned non-OK status: %d, body: %s", resp.StatusCode, body)
-	}
-	
-	var response CloudflareResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return fmt.Errorf("error decoding response: %w", err)
-	}
-	
-	if !response.Success {
-		return fmt.Errorf("API request was not successful: %v", response.Errors)
-	}
 	
 	// Update cache
-	ipRangeSet := model.NewIPRangeSet()
-	cidrs := c.parseFilterExpression(expression).GetCIDRs()
-	for _, cidr := range cidrs {
-		if err := ipRangeSet.Add(cidr, []string{"cloudflare"}); err != nil {
-			log.Error(err, "Error adding CIDR to IP range set", "cidr", cidr)
-		}
-	}
-	
-	c.cacheMutex.Lock()
-	c.cachedData = ipRangeSet
-	c.lastFetch = time.Now()
-	c.cacheMutex.Unlock()
+	c.cacheExpression(expression)
 	
-	log.Info("Successfully updated Cloudflare rule", "ingress", c.name, "filterID", filterID, "cidrCount", len(cidrs))
+	log.Info("Successfully updated Cloudflare rule", "ingress", c.name, "filterID", filterID)
 	
 	return nil
 }
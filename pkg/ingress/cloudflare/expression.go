@@ -0,0 +1,555 @@
+package cloudflare
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+)
+
+// maxExpressionLength is the longest rule expression Cloudflare accepts, in bytes
+const maxExpressionLength = 4096
+
+// managedField is the field of the clause holding the synced ranges
+const managedField = "ip.src"
+
+// tokenKind is the kind of a token of the Rules language
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenList
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenLeftBrace
+	tokenRightBrace
+	tokenLeftBracket
+	tokenRightBracket
+	tokenComma
+)
+
+// token is a token of the Rules language with its byte offsets in the expression
+type token struct {
+	kind  tokenKind
+	text  string
+	start int
+	end   int
+}
+
+// symbolOperators are the operators written with symbols, longest first
+var symbolOperators = []string{"==", "!=", "<=", ">=", "&&", "||", "^^", "<", ">", "~", "!"}
+
+// comparisonOperators are the operators comparing a field to a value
+var comparisonOperators = map[string]bool{
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"contains": true, "matches": true, "~": true, "wildcard": true, "in": true,
+}
+
+// isWordChar reports whether a character can be part of a field, function name, number or address
+func isWordChar(char byte) bool {
+	switch char {
+	case ' ', '\t', '\n', '\r', '(', ')', '{', '}', '[', ']', ',', '"', '$', '<', '>', '=', '!', '~', '&', '|', '^':
+		return false
+	}
+	return true
+}
+
+// tokenize splits an expression into tokens
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(expression) {
+		char := expression[i]
+		start := i
+
+		switch {
+		case char == ' ' || char == '\t' || char == '\n' || char == '\r':
+			i++
+			continue
+
+		case strings.IndexByte("(){}[],", char) >= 0:
+			kind := map[byte]tokenKind{
+				'(': tokenLeftParen, ')': tokenRightParen,
+				'{': tokenLeftBrace, '}': tokenRightBrace,
+				'[': tokenLeftBracket, ']': tokenRightBracket,
+				',': tokenComma,
+			}[char]
+			i++
+			tokens = append(tokens, token{kind: kind, text: expression[start:i], start: start, end: i})
+			continue
+
+		case char == '"':
+			end, err := scanString(expression, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+
+		case char == 'r' && i+1 < len(expression) && (expression[i+1] == '"' || expression[i+1] == '#'):
+			end, err := scanRawString(expression, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+
+		case char == '$':
+			i++
+			for i < len(expression) && isWordChar(expression[i]) {
+				i++
+			}
+			if i == start+1 {
+				return nil, fmt.Errorf("missing list name at offset %d", start)
+			}
+			tokens = append(tokens, token{kind: tokenList, text: expression[start:i], start: start, end: i})
+			continue
+
+		default:
+			if operator := matchSymbolOperator(expression[i:]); operator != "" {
+				i += len(operator)
+				tokens = append(tokens, token{kind: tokenOperator, text: operator, start: start, end: i})
+				continue
+			}
+			for i < len(expression) && isWordChar(expression[i]) {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", char, start)
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: expression[start:i], start: start, end: i})
+			continue
+		}
+
+		tokens = append(tokens, token{kind: tokenString, text: expression[start:i], start: start, end: i})
+	}
+
+	return append(tokens, token{kind: tokenEOF, start: len(expression), end: len(expression)}), nil
+}
+
+// matchSymbolOperator returns the symbol operator at the start of s, if any
+func matchSymbolOperator(s string) string {
+	for _, operator := range symbolOperators {
+		if strings.HasPrefix(s, operator) {
+			return operator
+		}
+	}
+	return ""
+}
+
+// scanString returns the offset just after the quoted string starting at start
+func scanString(expression string, start int) (int, error) {
+	for i := start + 1; i < len(expression); i++ {
+		switch expression[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string at offset %d", start)
+}
+
+// scanRawString returns the offset just after the raw string (r"..." or r#"..."#) starting at start
+func scanRawString(expression string, start int) (int, error) {
+	i := start + 1
+	hashes := 0
+	for i < len(expression) && expression[i] == '#' {
+		hashes++
+		i++
+	}
+	if i >= len(expression) || expression[i] != '"' {
+		return 0, fmt.Errorf("invalid raw string at offset %d", start)
+	}
+
+	terminator := `"` + strings.Repeat("#", hashes)
+	end := strings.Index(expression[i+1:], terminator)
+	if end < 0 {
+		return 0, fmt.Errorf("unterminated raw string at offset %d", start)
+	}
+	return i + 1 + end + len(terminator), nil
+}
+
+// exprNode is a node of a parsed expression
+type exprNode interface {
+	exprNode()
+}
+
+// logicalNode joins two expressions with and, or or xor
+type logicalNode struct {
+	operator string
+	left     exprNode
+	right    exprNode
+}
+
+// notNode negates an expression
+type notNode struct {
+	operator string
+	operand  exprNode
+}
+
+// groupNode is a parenthesized expression
+type groupNode struct {
+	inner exprNode
+}
+
+// comparisonNode compares a field to a value, or is a bare boolean field when operator is empty
+type comparisonNode struct {
+	left     *valueNode
+	operator string
+	right    exprNode
+}
+
+// valueNode is a field, function call or literal, kept as written
+type valueNode struct {
+	text string
+}
+
+// setNode is an inline set of values, e.g. {1.2.3.0/24 5.6.7.8}
+type setNode struct {
+	elements []string
+	start    int
+	end      int
+}
+
+// listNode references a list, e.g. $office_ranges
+type listNode struct {
+	name  string
+	start int
+	end   int
+}
+
+func (*logicalNode) exprNode()    {}
+func (*notNode) exprNode()        {}
+func (*groupNode) exprNode()      {}
+func (*comparisonNode) exprNode() {}
+func (*valueNode) exprNode()      {}
+func (*setNode) exprNode()        {}
+func (*listNode) exprNode()       {}
+
+// expression is a parsed rule expression
+type expression struct {
+	source string
+	root   exprNode
+
+	// managed is the first "ip.src in {...}" or "ip.src in $list" clause, if any
+	managed *comparisonNode
+}
+
+// parseExpression parses a rule expression in the subset of the Rules language used by firewall rules
+func parseExpression(source string) (*expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &expressionParser{source: source, tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.peek().text, p.peek().start)
+	}
+
+	return &expression{source: source, root: root, managed: p.managed}, nil
+}
+
+// ranges returns the ranges of the managed clause, which is empty if it references a list or is missing
+func (e *expression) ranges() *model.IPRangeSet {
+	ipRangeSet := model.NewIPRangeSet()
+	if e.managed == nil {
+		return ipRangeSet
+	}
+
+	set, ok := e.managed.right.(*setNode)
+	if !ok {
+		return ipRangeSet
+	}
+
+	for _, cidr := range set.elements {
+		if err := ipRangeSet.Add(cidr, []string{"cloudflare"}); err != nil {
+			log.Error(err, "Error adding CIDR to IP range set", "cidr", cidr)
+		}
+	}
+	return ipRangeSet
+}
+
+// replaceManaged returns the source with the value of the managed clause replaced, leaving every other
+// clause as written. Without a managed clause, the managed clause is joined to the source with and.
+func (e *expression) replaceManaged(value string) string {
+	if e.managed != nil {
+		switch right := e.managed.right.(type) {
+		case *setNode:
+			return e.source[:right.start] + value + e.source[right.end:]
+		case *listNode:
+			return e.source[:right.start] + value + e.source[right.end:]
+		}
+	}
+
+	// and binds tighter than or and xor, so a source joined by either has to be grouped first
+	source := strings.TrimSpace(e.source)
+	if logical, ok := e.root.(*logicalNode); ok && logical.operator != "and" && logical.operator != "&&" {
+		source = "(" + source + ")"
+	}
+	return source + " and " + managedClause(value)
+}
+
+// managedClause returns a new managed clause matching the given set or list
+func managedClause(value string) string {
+	return fmt.Sprintf("(%s in %s)", managedField, value)
+}
+
+// rangesValue renders the ranges as a set
+func rangesValue(ipRanges *model.IPRangeSet) string {
+	return "{" + strings.Join(ipRanges.GetCIDRs(), " ") + "}"
+}
+
+// listValue renders a reference to a list
+func listValue(name string) string {
+	return "$" + name
+}
+
+// buildExpression updates the managed clause of the current expression, adds the clause if the current
+// expression has none, or creates it if there is no current expression, and checks the result against the
+// size limit. An expression that does not parse is left alone and reported as an error.
+func buildExpression(current, value string) (string, error) {
+	result := managedClause(value)
+	if strings.TrimSpace(current) != "" {
+		parsed, err := parseExpression(current)
+		if err != nil {
+			return "", fmt.Errorf("error parsing rule expression %q: %w", current, err)
+		}
+		if parsed.managed == nil {
+			log.Info("Rule expression has no managed clause, adding one", "expression", current)
+		}
+		result = parsed.replaceManaged(value)
+	}
+
+	if len(result) > maxExpressionLength {
+		return "", fmt.Errorf("rule expression is %d bytes, more than the %d bytes Cloudflare accepts; "+
+			"reduce the ranges with maxEntries or use an IP list", len(result), maxExpressionLength)
+	}
+	return result, nil
+}
+
+// expressionParser is a recursive descent parser over the tokens of an expression.
+// Precedence from lowest to highest is or, xor, and, not.
+type expressionParser struct {
+	source  string
+	tokens  []token
+	pos     int
+	managed *comparisonNode
+}
+
+func (p *expressionParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *expressionParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *expressionParser) expect(kind tokenKind, description string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, fmt.Errorf("expected %s at offset %d, found %q", description, t.start, t.text)
+	}
+	return t, nil
+}
+
+// acceptOperator consumes the next token if it is one of the given operators
+func (p *expressionParser) acceptOperator(operators ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenWord && t.kind != tokenOperator {
+		return "", false
+	}
+	for _, operator := range operators {
+		if t.text == operator {
+			p.next()
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+func (p *expressionParser) parseOr() (exprNode, error) {
+	return p.parseLogical(p.parseXor, "or", "||")
+}
+
+func (p *expressionParser) parseXor() (exprNode, error) {
+	return p.parseLogical(p.parseAnd, "xor", "^^")
+}
+
+func (p *expressionParser) parseAnd() (exprNode, error) {
+	return p.parseLogical(p.parseNot, "and", "&&")
+}
+
+// parseLogical parses operands joined by the given operators, associating to the left
+func (p *expressionParser) parseLogical(operand func() (exprNode, error), operators ...string) (exprNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok := p.acceptOperator(operators...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{operator: operator, left: left, right: right}
+	}
+}
+
+func (p *expressionParser) parseNot() (exprNode, error) {
+	if operator, ok := p.acceptOperator("not", "!"); ok {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operator: operator, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *expressionParser) parsePrimary() (exprNode, error) {
+	if p.peek().kind == tokenLeftParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRightParen, "')'"); err != nil {
+			return nil, err
+		}
+		return &groupNode{inner: inner}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *expressionParser) parseComparison() (exprNode, error) {
+	left, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &comparisonNode{left: left}
+
+	t := p.peek()
+	switch {
+	case t.kind == tokenWord && t.text == "strict":
+		// "strict wildcard" is the only two-word operator
+		p.next()
+		if _, ok := p.acceptOperator("wildcard"); !ok {
+			return nil, fmt.Errorf("expected 'wildcard' after 'strict' at offset %d", p.peek().start)
+		}
+		comparison.operator = "strict wildcard"
+	case (t.kind == tokenWord || t.kind == tokenOperator) && comparisonOperators[t.text]:
+		p.next()
+		comparison.operator = t.text
+	default:
+		// Bare boolean field, e.g. ssl or cf.bot_management.verified_bot
+		return comparison, nil
+	}
+
+	if comparison.operator == "in" {
+		comparison.right, err = p.parseSetOrList()
+		if err != nil {
+			return nil, err
+		}
+		if left.text == managedField && p.managed == nil {
+			p.managed = comparison
+		}
+		return comparison, nil
+	}
+
+	comparison.right, err = p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return comparison, nil
+}
+
+// parseValue parses a field, literal or function call, with any index suffixes, keeping its source text
+func (p *expressionParser) parseValue() (*valueNode, error) {
+	first := p.next()
+	switch first.kind {
+	case tokenString:
+		return &valueNode{text: first.text}, nil
+	case tokenWord:
+	default:
+		return nil, fmt.Errorf("expected a field or value at offset %d, found %q", first.start, first.text)
+	}
+
+	end := first.end
+
+	// Function call, e.g. lower(http.host) or any(http.request.headers.names[*] == "x")
+	if p.peek().kind == tokenLeftParen {
+		p.next()
+		if p.peek().kind != tokenRightParen {
+			for {
+				if _, err := p.parseOr(); err != nil {
+					return nil, err
+				}
+				if p.peek().kind != tokenComma {
+					break
+				}
+				p.next()
+			}
+		}
+		closing, err := p.expect(tokenRightParen, "')'")
+		if err != nil {
+			return nil, err
+		}
+		end = closing.end
+	}
+
+	// Index suffixes, e.g. http.request.headers["x-forwarded-for"][0]
+	for p.peek().kind == tokenLeftBracket {
+		p.next()
+		index := p.next()
+		if index.kind != tokenWord && index.kind != tokenString {
+			return nil, fmt.Errorf("expected an index at offset %d, found %q", index.start, index.text)
+		}
+		closing, err := p.expect(tokenRightBracket, "']'")
+		if err != nil {
+			return nil, err
+		}
+		end = closing.end
+	}
+
+	return &valueNode{text: p.source[first.start:end]}, nil
+}
+
+// parseSetOrList parses the right-hand side of an in comparison
+func (p *expressionParser) parseSetOrList() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenList:
+		return &listNode{name: strings.TrimPrefix(t.text, "$"), start: t.start, end: t.end}, nil
+	case tokenLeftBrace:
+		set := &setNode{start: t.start}
+		for {
+			element := p.next()
+			switch element.kind {
+			case tokenRightBrace:
+				set.end = element.end
+				return set, nil
+			case tokenWord, tokenString:
+				set.elements = append(set.elements, element.text)
+			default:
+				return nil, fmt.Errorf("unexpected %q in set at offset %d", element.text, element.start)
+			}
+		}
+	}
+	return nil, fmt.Errorf("expected a set or list at offset %d, found %q", t.start, t.text)
+}
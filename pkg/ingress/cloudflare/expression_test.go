@@ -0,0 +1,195 @@
+package cloudflare
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseExpression(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		wantRanges []string
+		wantErr    bool
+	}{
+		{
+			name:       "managed set",
+			expression: "(ip.src in {1.2.3.0/24 2001:db8::/32})",
+			wantRanges: []string{"1.2.3.0/24", "2001:db8::/32"},
+		},
+		{
+			name:       "managed list",
+			expression: "ip.src in $office and http.host eq \"example.com\"",
+		},
+		{
+			name:       "no managed clause",
+			expression: `http.request.uri.path contains "/admin" or not ssl`,
+		},
+		{
+			name:       "functions, indexes and raw strings",
+			expression: `any(lower(http.request.headers.names[*])[*] == "x-test") and http.request.headers["x-forwarded-for"][0] ne r#"a"b"# and ip.src in {10.0.0.1}`,
+			wantRanges: []string{"10.0.0.1/32"},
+		},
+		{
+			name:       "symbol operators",
+			expression: `(ip.geoip.country == "GB" || ip.geoip.country == "FR") && !(ip.src in {192.0.2.0/24})`,
+			wantRanges: []string{"192.0.2.0/24"},
+		},
+		{
+			name:       "strict wildcard",
+			expression: `http.host strict wildcard "*.example.com"`,
+		},
+		{
+			name:       "unsupported operator",
+			expression: "http.request.timestamp.sec & 1 == 0 and ip.src in {1.1.1.1}",
+			wantErr:    true,
+		},
+		{
+			name:       "unbalanced parentheses",
+			expression: "(ip.src in {1.1.1.1}",
+			wantErr:    true,
+		},
+		{
+			name:       "unterminated string",
+			expression: `http.host eq "example.com`,
+			wantErr:    true,
+		},
+		{
+			name:       "trailing tokens",
+			expression: "ssl ssl",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parseExpression(tt.expression)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseExpression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := parsed.ranges().GetCIDRs()
+			if len(got) == 0 && len(tt.wantRanges) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.wantRanges) {
+				t.Errorf("ranges() = %v, want %v", got, tt.wantRanges)
+			}
+		})
+	}
+}
+
+func TestBuildExpression(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "no current expression",
+			value: "{2.2.2.2}",
+			want:  "(ip.src in {2.2.2.2})",
+		},
+		{
+			name:    "replaces the managed set only",
+			current: `http.host eq "example.com" and ip.src in {1.1.1.1}  and not ssl`,
+			value:   "{2.2.2.2 3.3.3.0/24}",
+			want:    `http.host eq "example.com" and ip.src in {2.2.2.2 3.3.3.0/24}  and not ssl`,
+		},
+		{
+			name:    "replaces a list with a set",
+			current: "(ip.src in $old_list)",
+			value:   "{2.2.2.2}",
+			want:    "(ip.src in {2.2.2.2})",
+		},
+		{
+			name:    "replaces a set with a list",
+			current: `ip.src in {1.1.1.1} and http.request.method eq "POST"`,
+			value:   "$new_list",
+			want:    `ip.src in $new_list and http.request.method eq "POST"`,
+		},
+		{
+			name:    "keeps only the first managed clause",
+			current: "ip.src in {1.1.1.1} or ip.src in {4.4.4.4}",
+			value:   "{2.2.2.2}",
+			want:    "ip.src in {2.2.2.2} or ip.src in {4.4.4.4}",
+		},
+		{
+			name:    "adds the managed clause to a conjunction",
+			current: `http.host eq "example.com" and not ssl`,
+			value:   "{2.2.2.2}",
+			want:    `http.host eq "example.com" and not ssl and (ip.src in {2.2.2.2})`,
+		},
+		{
+			name:    "groups a disjunction before adding the managed clause",
+			current: `http.host eq "a.example.com" or http.host eq "b.example.com"`,
+			value:   "{2.2.2.2}",
+			want:    `(http.host eq "a.example.com" or http.host eq "b.example.com") and (ip.src in {2.2.2.2})`,
+		},
+		{
+			name:    "ip.src compared to a single address is not managed",
+			current: "ip.src eq 1.1.1.1",
+			value:   "{2.2.2.2}",
+			want:    "ip.src eq 1.1.1.1 and (ip.src in {2.2.2.2})",
+		},
+		{
+			name:    "unparseable expression is an error",
+			current: "http.request.timestamp.sec & 1 == 0 and ip.src in {1.1.1.1}",
+			value:   "{2.2.2.2}",
+			wantErr: true,
+		},
+		{
+			name:    "too long",
+			value:   "{" + strings.Repeat("192.0.2.1 ", maxExpressionLength/10) + "}",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildExpression(tt.current, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildExpression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("buildExpression() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildExpressionRoundTrip(t *testing.T) {
+	// A rebuilt expression parses again, and rebuilding it with the same value is a no-op
+	expressions := []string{
+		"",
+		`http.host eq "example.com"`,
+		`(http.host eq "a" or ssl) and ip.src in {1.1.1.1}`,
+		`not ip.src in $office xor cf.bot_management.verified_bot`,
+	}
+
+	for _, current := range expressions {
+		first, err := buildExpression(current, "{192.0.2.0/24}")
+		if err != nil {
+			t.Fatalf("buildExpression(%q) error = %v", current, err)
+		}
+		parsed, err := parseExpression(first)
+		if err != nil {
+			t.Fatalf("parseExpression(%q) error = %v", first, err)
+		}
+		if got := parsed.ranges().GetCIDRs(); !reflect.DeepEqual(got, []string{"192.0.2.0/24"}) {
+			t.Errorf("ranges of %q = %v, want [192.0.2.0/24]", first, got)
+		}
+		second, err := buildExpression(first, "{192.0.2.0/24}")
+		if err != nil {
+			t.Fatalf("buildExpression(%q) error = %v", first, err)
+		}
+		if second != first {
+			t.Errorf("rebuilding %q = %q, want it unchanged", first, second)
+		}
+	}
+}
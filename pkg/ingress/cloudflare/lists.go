@@ -0,0 +1,344 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/ingress"
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+)
+
+const (
+	// listItemBatchSize is the number of items sent in a single add or delete request
+	listItemBatchSize = 1000
+
+	// bulkOperationPollInterval is how often the status of a bulk list operation is checked
+	bulkOperationPollInterval = 1 * time.Second
+
+	// bulkOperationTimeout is how long to wait for a bulk list operation to finish
+	bulkOperationTimeout = 2 * time.Minute
+
+	// maxItemCommentLength is the longest comment Cloudflare accepts on a list item
+	maxItemCommentLength = 500
+)
+
+// IPList represents a Cloudflare IP list
+type IPList struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Description string `json:"description,omitempty"`
+	NumItems    int    `json:"num_items,omitempty"`
+}
+
+// IPListItem represents an item of a Cloudflare IP list
+type IPListItem struct {
+	ID      string `json:"id,omitempty"`
+	IP      string `json:"ip,omitempty"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// BulkOperation represents the status of an asynchronous list operation
+type BulkOperation struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// usesList reports whether the ranges are kept in an IP list rather than inline in the rule expression
+func (c *CloudflareIngress) usesList() bool {
+	return c.mode == modeList || c.updateStrategy == "incremental"
+}
+
+// itemComment describes where a range came from, within the length Cloudflare accepts
+func itemComment(ipRange model.IPRange) string {
+	comment := ipRange.Comment()
+	if len(comment) > maxItemCommentLength {
+		comment = comment[:maxItemCommentLength]
+	}
+	return comment
+}
+
+// invalidListNameChars matches the characters not allowed in a list name
+var invalidListNameChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// defaultListName derives a valid list name (lowercase letters, digits and underscores, at most 50 characters) from the rule name
+func defaultListName(ruleName string) string {
+	name := strings.Trim(invalidListNameChars.ReplaceAllString(strings.ToLower(ruleName), "_"), "_")
+	if len(name) > 50 {
+		name = name[:50]
+	}
+	return name
+}
+
+// listsPath returns the path of the account's lists API, followed by the given path elements
+func (c *CloudflareIngress) listsPath(elements ...string) string {
+	return "/accounts/" + c.accountID + "/rules/lists" + joinPath(elements...)
+}
+
+// joinPath joins path elements, escaping each of them
+func joinPath(elements ...string) string {
+	var builder strings.Builder
+	for _, element := range elements {
+		builder.WriteString("/")
+		builder.WriteString(url.PathEscape(element))
+	}
+	return builder.String()
+}
+
+// findOrCreateList returns the ID of the configured list, creating the list if it does not exist.
+// An existing list is only used if it was created for this IngressConfig or may be adopted.
+func (c *CloudflareIngress) findOrCreateList(ctx context.Context) (string, error) {
+	if c.listID != "" {
+		return c.listID, nil
+	}
+
+	var lists []IPList
+	if _, err := c.client.Do(ctx, "GET", c.listsPath(), nil, &lists); err != nil {
+		return "", fmt.Errorf("error getting lists: %w", err)
+	}
+
+	for _, list := range lists {
+		if list.Name == c.listName {
+			if list.Kind != "ip" {
+				return "", fmt.Errorf("list %s exists but is of kind %s", c.listName, list.Kind)
+			}
+			if list.ID != c.knownID(objectList) && !strings.Contains(list.Description, c.ownerMarker()) {
+				if !c.adopt {
+					return "", c.notOwnedError(objectList, list.ID, list.Name)
+				}
+				if err := c.adoptList(ctx, list); err != nil {
+					return "", err
+				}
+			}
+			c.listID = list.ID
+			return c.listID, nil
+		}
+	}
+
+	log.Info("Creating Cloudflare IP list", "ingress", c.name, "list", c.listName)
+
+	var created IPList
+	list := IPList{Name: c.listName, Kind: "ip", Description: c.markedDescription(c.listDescription)}
+	if _, err := c.client.Do(ctx, "POST", c.listsPath(), list, &created); err != nil {
+		return "", fmt.Errorf("error creating list: %w", err)
+	}
+
+	c.listID = created.ID
+	return c.listID, nil
+}
+
+// adoptList marks an existing list as managed for this IngressConfig
+func (c *CloudflareIngress) adoptList(ctx context.Context, list IPList) error {
+	log.Info("Adopting existing Cloudflare IP list", "ingress", c.name, "list", list.Name, "listID", list.ID)
+
+	update := map[string]string{"description": c.markedDescription(list.Description)}
+	if _, err := c.client.Do(ctx, "PUT", c.listsPath(list.ID), update, nil); err != nil {
+		return fmt.Errorf("error marking list %s as managed: %w", list.Name, err)
+	}
+	return nil
+}
+
+// getListItems returns every item of the list, following pagination cursors
+func (c *CloudflareIngress) getListItems(ctx context.Context, listID string) ([]IPListItem, error) {
+	var items []IPListItem
+	err := c.client.List(ctx, c.listsPath(listID, "items"), nil, func(result json.RawMessage) error {
+		var page []IPListItem
+		if err := json.Unmarshal(result, &page); err != nil {
+			return fmt.Errorf("error unmarshaling list items: %w", err)
+		}
+		items = append(items, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting list items: %w", err)
+	}
+	return items, nil
+}
+
+// waitForBulkOperation polls a bulk list operation until it completes or fails
+func (c *CloudflareIngress) waitForBulkOperation(ctx context.Context, operationID string) error {
+	ctx, cancel := context.WithTimeout(ctx, bulkOperationTimeout)
+	defer cancel()
+
+	for {
+		var operation BulkOperation
+		if _, err := c.client.Do(ctx, "GET", c.listsPath("bulk_operations", operationID), nil, &operation); err != nil {
+			return fmt.Errorf("error getting bulk operation %s: %w", operationID, err)
+		}
+
+		switch operation.Status {
+		case "completed":
+			return nil
+		case "failed":
+			return fmt.Errorf("bulk operation %s failed: %s", operationID, operation.Error)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for bulk operation %s: %w", operationID, ctx.Err())
+		case <-time.After(bulkOperationPollInterval):
+		}
+	}
+}
+
+// applyIncremental updates the list by adding and deleting only the items that changed,
+// then makes sure the managed rule, if any, references the list
+func (c *CloudflareIngress) applyIncremental(ctx context.Context, ipRanges *model.IPRangeSet) error {
+	listID, err := c.findOrCreateList(ctx)
+	if err != nil {
+		return err
+	}
+
+	items, err := c.getListItems(ctx, listID)
+	if err != nil {
+		return err
+	}
+
+	// Index the current items by canonical prefix
+	current := make(map[string]string, len(items))
+	for _, item := range items {
+		prefix, err := model.ParsePrefix(item.IP)
+		if err != nil {
+			log.Error(err, "Ignoring unparseable list item", "ingress", c.name, "ip", item.IP)
+			continue
+		}
+		current[prefix.Masked().String()] = item.ID
+	}
+
+	desired := make(map[string]bool, ipRanges.Count())
+	var toAdd []IPListItem
+	for _, ipRange := range ipRanges.Ranges {
+		desired[ipRange.CIDR] = true
+		if _, exists := current[ipRange.CIDR]; !exists {
+			toAdd = append(toAdd, IPListItem{IP: ipRange.CIDR, Comment: itemComment(ipRange)})
+		}
+	}
+
+	var toDelete []IPListItem
+	for cidr, id := range current {
+		if !desired[cidr] {
+			toDelete = append(toDelete, IPListItem{ID: id})
+		}
+	}
+
+	log.Info("IP list diff", "ingress", c.name, "list", c.listName, "added", len(toAdd), "removed", len(toDelete))
+
+	result := &ingress.PartialApplyError{Ingress: c.name}
+
+	// Add new items first so that ranges moving between prefixes are never briefly missing
+	for start := 0; start < len(toAdd); start += listItemBatchSize {
+		batch := toAdd[start:min(start+listItemBatchSize, len(toAdd))]
+		if err := c.bulkUpdate(ctx, "POST", listID, batch); err != nil {
+			result.Failures = append(result.Failures, fmt.Sprintf("adding %d items: %v", len(batch), err))
+			continue
+		}
+		result.Added += len(batch)
+	}
+
+	// A range being replaced by a different prefix would be missing until the next successful sync
+	// if its old prefix were deleted after the new one failed to be added, so keep every item instead
+	if len(result.Failures) > 0 && len(toDelete) > 0 {
+		log.Info("Skipping IP list deletions after failed additions", "ingress", c.name, "list", c.listName, "skipped", len(toDelete))
+		result.Skipped = len(toDelete)
+		result.Failures = append(result.Failures, fmt.Sprintf("skipped deleting %d items because adding items failed", len(toDelete)))
+		toDelete = nil
+	}
+
+	for start := 0; start < len(toDelete); start += listItemBatchSize {
+		batch := toDelete[start:min(start+listItemBatchSize, len(toDelete))]
+		if err := c.bulkUpdate(ctx, "DELETE", listID, map[string]interface{}{"items": batch}); err != nil {
+			result.Failures = append(result.Failures, fmt.Sprintf("deleting %d items: %v", len(batch), err))
+			continue
+		}
+		result.Removed += len(batch)
+	}
+
+	if len(result.Failures) > 0 {
+		// The list contents are unknown after a failure, so read them again next time
+		c.cacheMutex.Lock()
+		c.cachedData = nil
+		c.cacheMutex.Unlock()
+
+		if result.Added == 0 && result.Removed == 0 {
+			return fmt.Errorf("error updating list %s: %s", c.listName, strings.Join(result.Failures, "; "))
+		}
+		return result
+	}
+
+	// With several zones, the caller makes sure each zone's rule references the list
+	if c.manageRule && !c.multiZone() {
+		if err := c.ensureListRule(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Update cache after the rule, since creating or updating the rule caches its expression
+	c.cacheMutex.Lock()
+	c.cachedData = ipRanges
+	c.lastFetch = time.Now()
+	c.cacheMutex.Unlock()
+
+	log.Info("Successfully updated Cloudflare IP list", "ingress", c.name, "list", c.listName, "added", result.Added, "removed", result.Removed)
+
+	return nil
+}
+
+// bulkUpdate sends an add or delete request for list items and waits for the resulting bulk operation
+func (c *CloudflareIngress) bulkUpdate(ctx context.Context, method, listID string, body interface{}) error {
+	var operation struct {
+		OperationID string `json:"operation_id"`
+	}
+	if _, err := c.client.Do(ctx, method, c.listsPath(listID, "items"), body, &operation); err != nil {
+		return err
+	}
+	return c.waitForBulkOperation(ctx, operation.OperationID)
+}
+
+// ensureListRule creates the rule, or points the existing rule's managed clause at the list if it references anything else
+func (c *CloudflareIngress) ensureListRule(ctx context.Context) error {
+	current, enabled, err := c.getRuleExpression(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting rule expression: %w", err)
+	}
+
+	// Keep any clauses added around the managed one
+	expression, err := buildExpression(current, listValue(c.listName))
+	if err != nil {
+		return err
+	}
+
+	// The rulesets engine also has to migrate a rule that is otherwise up to date
+	if current == expression && enabled && !c.migrationPending {
+		return nil
+	}
+
+	log.Info("Pointing Cloudflare rule at IP list", "ingress", c.name, "ruleName", c.ruleName, "list", c.listName)
+	return c.setRuleExpression(ctx, expression, true)
+}
+
+// getListIPRanges returns the current items of the list as an IPRangeSet
+func (c *CloudflareIngress) getListIPRanges(ctx context.Context) (*model.IPRangeSet, error) {
+	listID, err := c.findOrCreateList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := c.getListItems(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+
+	ipRangeSet := model.NewIPRangeSet()
+	for _, item := range items {
+		if err := ipRangeSet.Add(item.IP, []string{"cloudflare"}); err != nil {
+			log.Error(err, "Error adding list item to IP range set", "ip", item.IP)
+		}
+	}
+	return ipRangeSet, nil
+}
@@ -0,0 +1,159 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/ingress"
+)
+
+// fakeLists serves a single account IP list. If failAdds is set, item additions fail once
+// addsBeforeFailure of them succeeded.
+type fakeLists struct {
+	mutex             sync.Mutex
+	items             []IPListItem
+	failAdds          bool
+	addsBeforeFailure int
+	adds              int
+	changes           []string
+}
+
+func (f *fakeLists) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if r.Method != http.MethodGet {
+		f.changes = append(f.changes, r.Method+" "+r.URL.Path)
+	}
+
+	listsPath := "/accounts/account-id/rules/lists"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == listsPath:
+		writeResult(w, []IPList{{ID: "list-id", Name: "office", Kind: "ip", Description: "[" + ownershipRef("uid") + "]"}})
+	case r.Method == http.MethodGet && r.URL.Path == listsPath+"/list-id/items":
+		writeResult(w, f.items)
+	case r.Method == http.MethodPost && r.URL.Path == listsPath+"/list-id/items":
+		f.adds++
+		if f.failAdds && f.adds > f.addsBeforeFailure {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"success": false, "errors": [{"code": 10001, "message": "internal error"}], "messages": [], "result": null}`))
+			return
+		}
+		writeResult(w, map[string]string{"operation_id": "operation-id"})
+	case r.Method == http.MethodDelete && r.URL.Path == listsPath+"/list-id/items":
+		writeResult(w, map[string]string{"operation_id": "operation-id"})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, listsPath+"/bulk_operations/"):
+		writeResult(w, BulkOperation{ID: "operation-id", Status: "completed"})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestListIngress(t *testing.T, api *fakeLists) *CloudflareIngress {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	c := &CloudflareIngress{}
+	err := c.Init(context.Background(), map[string]interface{}{
+		"name":      "office",
+		"apiToken":  "token",
+		"baseURL":   server.URL,
+		"mode":      modeList,
+		"accountId": "account-id",
+		"listName":  "office",
+		"ownerUID":  "uid",
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	return c
+}
+
+func TestApplyIncremental(t *testing.T) {
+	tests := []struct {
+		name        string
+		failAdds    bool
+		wantChanges []string
+		wantErr     bool
+	}{
+		{
+			name: "adds before deleting",
+			wantChanges: []string{
+				"POST /accounts/account-id/rules/lists/list-id/items",
+				"DELETE /accounts/account-id/rules/lists/list-id/items",
+			},
+		},
+		{
+			name:     "failed additions skip every deletion",
+			failAdds: true,
+			wantChanges: []string{
+				"POST /accounts/account-id/rules/lists/list-id/items",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeLists{
+				// The two /24s are replaced by the /23 covering them
+				items: []IPListItem{
+					{ID: "item-1", IP: "10.0.0.0/24"},
+					{ID: "item-2", IP: "10.0.1.0/24"},
+					{ID: "item-3", IP: "192.0.2.0/24"},
+				},
+				failAdds: tt.failAdds,
+			}
+			c := newTestListIngress(t, api)
+
+			err := c.ApplyIPRanges(context.Background(), newRangeSet(t, "10.0.0.0/23", "192.0.2.0/24"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyIPRanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "skipped deleting 2 items") {
+				t.Errorf("ApplyIPRanges() error = %v, want it to report the skipped deletions", err)
+			}
+			if !reflect.DeepEqual(api.changes, tt.wantChanges) {
+				t.Errorf("ApplyIPRanges() sent %v, want %v", api.changes, tt.wantChanges)
+			}
+		})
+	}
+}
+
+func TestApplyIncrementalPartialAdd(t *testing.T) {
+	api := &fakeLists{
+		items:             []IPListItem{{ID: "item-1", IP: "10.0.0.0/24"}},
+		failAdds:          true,
+		addsBeforeFailure: 1,
+	}
+	c := newTestListIngress(t, api)
+
+	// More additions than fit in a batch, the second of which fails
+	ranges := newRangeSet(t)
+	for i := 0; i <= listItemBatchSize; i++ {
+		if err := ranges.Add(fmt.Sprintf("198.18.%d.%d", i/256, i%256), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := c.ApplyIPRanges(context.Background(), ranges)
+	partialErr, ok := ingress.IsPartiallyApplied(err)
+	if !ok {
+		t.Fatalf("ApplyIPRanges() error = %v, want a PartialApplyError", err)
+	}
+	if partialErr.Added != listItemBatchSize || partialErr.Removed != 0 || partialErr.Skipped != 1 || len(partialErr.Failures) != 2 {
+		t.Errorf("PartialApplyError = %+v, want %d added, 1 skipped and 2 failures", partialErr, listItemBatchSize)
+	}
+	for _, change := range api.changes {
+		if strings.HasPrefix(change, http.MethodDelete) {
+			t.Errorf("ApplyIPRanges() sent %s after a failed addition", change)
+		}
+	}
+}
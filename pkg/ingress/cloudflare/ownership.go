@@ -0,0 +1,128 @@
+package cloudflare
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/ingress"
+)
+
+// Kinds of the objects managed in Cloudflare, as reported in the IngressConfig status
+const (
+	objectList         = "list"
+	objectFilter       = "filter"
+	objectFirewallRule = "firewallRule"
+	objectRulesetRule  = "rulesetRule"
+)
+
+// objectKey identifies a managed object recorded by an earlier sync
+type objectKey struct {
+	kind  string
+	scope string
+}
+
+// ownershipRef derives the ref tagging the objects managed for an IngressConfig from its UID,
+// so that it survives renames and is never shared with another IngressConfig
+func ownershipRef(uid string) string {
+	sum := sha256.Sum256([]byte(uid))
+	return "ingress_meta_sync_owner_" + hex.EncodeToString(sum[:8])
+}
+
+// ownerMarker returns the marker added to the description of objects that have no ref, such as lists
+func (c *CloudflareIngress) ownerMarker() string {
+	return "[" + c.ownerRef + "]"
+}
+
+// markedDescription returns the description followed by the ownership marker
+func (c *CloudflareIngress) markedDescription(description string) string {
+	marker := c.ownerMarker()
+	switch {
+	case strings.Contains(description, marker):
+		return description
+	case description == "":
+		return marker
+	default:
+		return description + " " + marker
+	}
+}
+
+// knownID returns the ID of the object of the given kind recorded by an earlier sync, if any
+func (c *CloudflareIngress) knownID(kind string) string {
+	scope := c.zoneID
+	if kind == objectList {
+		scope = c.accountID
+	}
+	return c.knownObjects[objectKey{kind: kind, scope: scope}]
+}
+
+// restoreObjects records the objects reported by an earlier sync and picks up those of the zone
+func (c *CloudflareIngress) restoreObjects(objects []ingress.ManagedObject) {
+	c.knownObjects = make(map[objectKey]string, len(objects))
+	for _, object := range objects {
+		c.knownObjects[objectKey{kind: object.Kind, scope: object.Scope}] = object.ID
+	}
+	c.restoreZoneObjects()
+}
+
+// restoreZoneObjects picks up the rule objects recorded for the ingress's zone
+func (c *CloudflareIngress) restoreZoneObjects() {
+	c.filterID = c.knownID(objectFilter)
+	c.firewallRuleID = c.knownID(objectFirewallRule)
+	c.ruleID = c.knownID(objectRulesetRule)
+}
+
+// notOwnedError reports an existing object that matches the configuration but was not created for this IngressConfig
+func (c *CloudflareIngress) notOwnedError(kind, id, name string) error {
+	return fmt.Errorf("cloudflare %s %s (%s) exists but is not managed by ingress %s; set adopt: true to take it over",
+		kind, id, name, c.name)
+}
+
+// ManagedObjects returns the list and rules managed for the IngressConfig, in every zone
+func (c *CloudflareIngress) ManagedObjects() []ingress.ManagedObject {
+	var objects []ingress.ManagedObject
+	if c.usesList() {
+		listID := c.listID
+		if listID == "" {
+			listID = c.knownID(objectList)
+		}
+		if listID != "" {
+			objects = append(objects, ingress.ManagedObject{Kind: objectList, ID: listID, Scope: c.accountID})
+		}
+	}
+
+	if !c.multiZone() {
+		return append(objects, c.zoneObjects()...)
+	}
+
+	c.zonesMutex.Lock()
+	defer c.zonesMutex.Unlock()
+
+	zoneIDs := make([]string, 0, len(c.zones))
+	for zoneID := range c.zones {
+		zoneIDs = append(zoneIDs, zoneID)
+	}
+	sort.Strings(zoneIDs)
+
+	for _, zoneID := range zoneIDs {
+		objects = append(objects, c.zones[zoneID].zoneObjects()...)
+	}
+	return objects
+}
+
+// zoneObjects returns the rule objects managed in the ingress's zone
+func (c *CloudflareIngress) zoneObjects() []ingress.ManagedObject {
+	var objects []ingress.ManagedObject
+	for _, object := range []struct{ kind, id string }{
+		{objectFilter, c.filterID},
+		{objectFirewallRule, c.firewallRuleID},
+		{objectRulesetRule, c.ruleID},
+	} {
+		if object.id != "" {
+			objects = append(objects, ingress.ManagedObject{Kind: object.kind, ID: object.id, Scope: c.zoneID})
+		}
+	}
+	return objects
+}
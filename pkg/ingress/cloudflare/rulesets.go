@@ -0,0 +1,310 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/cloudflareapi"
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+)
+
+const (
+	// engineRulesets manages the rule in the zone's custom firewall ruleset
+	engineRulesets = "rulesets"
+
+	// engineFirewallRules manages the rule with the deprecated Filters and Firewall Rules APIs
+	engineFirewallRules = "firewallRules"
+
+	// customRulesPhase is the ruleset phase holding a zone's custom firewall rules
+	customRulesPhase = "http_request_firewall_custom"
+)
+
+// rulesetActions maps the configured action to a Rulesets API action
+var rulesetActions = map[string]string{
+	"allow":             "skip",
+	"skip":              "skip",
+	"block":             "block",
+	"managed_challenge": "managed_challenge",
+	"challenge":         "challenge",
+	"js_challenge":      "js_challenge",
+	"log":               "log",
+}
+
+// Ruleset represents a Cloudflare ruleset
+type Ruleset struct {
+	ID    string        `json:"id,omitempty"`
+	Name  string        `json:"name,omitempty"`
+	Kind  string        `json:"kind,omitempty"`
+	Phase string        `json:"phase,omitempty"`
+	Rules []RulesetRule `json:"rules"`
+}
+
+// RulesetRule represents a rule of a Cloudflare ruleset
+type RulesetRule struct {
+	ID               string                 `json:"id,omitempty"`
+	Ref              string                 `json:"ref,omitempty"`
+	Action           string                 `json:"action"`
+	ActionParameters map[string]interface{} `json:"action_parameters,omitempty"`
+	Expression       string                 `json:"expression"`
+	Description      string                 `json:"description,omitempty"`
+	Enabled          bool                   `json:"enabled"`
+}
+
+// legacyRuleRef returns the ref that identified the managed rule before rules were tagged with
+// their owner, so that such rules can be adopted
+func (c *CloudflareIngress) legacyRuleRef() string {
+	return "ingress_meta_sync_" + defaultListName(c.ruleName)
+}
+
+// getRuleExpression returns the expression of the managed rule and whether the rule is enabled,
+// or "" if the rule doesn't exist yet
+func (c *CloudflareIngress) getRuleExpression(ctx context.Context) (string, bool, error) {
+	if c.engine == engineRulesets {
+		_, rule, err := c.findRulesetRule(ctx)
+		if err != nil {
+			return "", false, err
+		}
+		if rule != nil {
+			return rule.Expression, rule.Enabled, nil
+		}
+		// Not migrated yet, the ranges are still in the filter-based rule
+	}
+
+	filter, err := c.findFilter(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	if filter == nil {
+		return "", false, nil
+	}
+	c.migrationPending = c.engine == engineRulesets
+
+	rule, err := c.findFirewallRule(ctx, filter.ID)
+	if err != nil {
+		return "", false, err
+	}
+	return filter.Expression, rule == nil || !rule.Paused, nil
+}
+
+// setRuleExpression creates the managed rule or updates its expression, enabling or disabling it
+func (c *CloudflareIngress) setRuleExpression(ctx context.Context, expression string, enabled bool) error {
+	if c.engine == engineRulesets {
+		return c.putRulesetRule(ctx, expression, enabled)
+	}
+
+	filter, err := c.findFilter(ctx)
+	if err != nil {
+		return fmt.Errorf("error finding filter: %w", err)
+	}
+
+	if filter == nil {
+		log.Info("Creating new Cloudflare rule", "ingress", c.name, "ruleName", c.ruleName)
+		return c.createRule(ctx, expression)
+	}
+
+	log.Info("Updating existing Cloudflare rule", "ingress", c.name, "ruleName", c.ruleName, "filterID", filter.ID)
+	if err := c.updateRule(ctx, filter.ID, expression); err != nil {
+		return err
+	}
+
+	if err := c.setFirewallRulePaused(ctx, filter.ID, !enabled); err != nil {
+		return err
+	}
+	if !enabled {
+		c.cacheExpression("")
+	}
+	return nil
+}
+
+// entrypointPath returns the path of the zone's entry point ruleset for custom rules
+func (c *CloudflareIngress) entrypointPath() string {
+	return fmt.Sprintf("/zones/%s/rulesets/phases/%s/entrypoint", c.zoneID, customRulesPhase)
+}
+
+// findRulesetRule returns the zone's entry point ruleset and the managed rule in it.
+// Either is nil if it doesn't exist yet. A rule with the legacy ref is only adopted if allowed.
+func (c *CloudflareIngress) findRulesetRule(ctx context.Context) (*Ruleset, *RulesetRule, error) {
+	var ruleset Ruleset
+	if _, err := c.client.Do(ctx, "GET", c.entrypointPath(), nil, &ruleset); err != nil {
+		if cloudflareapi.IsNotFound(err) {
+			c.ruleID = ""
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("error getting entry point ruleset: %w", err)
+	}
+
+	var owned, unowned *RulesetRule
+	legacyRef := c.legacyRuleRef()
+	for i := range ruleset.Rules {
+		rule := &ruleset.Rules[i]
+		switch {
+		case rule.Ref == c.ownerRef || (c.ruleID != "" && rule.ID == c.ruleID):
+			if owned == nil {
+				owned = rule
+			}
+		case rule.Ref == legacyRef:
+			if unowned == nil {
+				unowned = rule
+			}
+		}
+	}
+
+	switch {
+	case owned != nil:
+		c.ruleID = owned.ID
+		return &ruleset, owned, nil
+	case unowned == nil:
+		c.ruleID = ""
+		return &ruleset, nil, nil
+	case !c.adopt:
+		return nil, nil, c.notOwnedError(objectRulesetRule, unowned.ID, unowned.Ref)
+	}
+
+	log.Info("Adopting existing Cloudflare ruleset rule", "ingress", c.name, "ref", unowned.Ref, "ruleID", unowned.ID)
+	c.ruleID = unowned.ID
+	return &ruleset, unowned, nil
+}
+
+// recordRulesetRule records the ID of the managed rule in a ruleset returned by the API
+func (c *CloudflareIngress) recordRulesetRule(ruleset *Ruleset) {
+	for _, rule := range ruleset.Rules {
+		if rule.Ref == c.ownerRef {
+			c.ruleID = rule.ID
+			return
+		}
+	}
+}
+
+// rulesetRule builds the managed rule with the given expression
+func (c *CloudflareIngress) rulesetRule(expression string, enabled bool) RulesetRule {
+	rule := RulesetRule{
+		Ref:         c.ownerRef,
+		Action:      rulesetActions[c.action],
+		Expression:  expression,
+		Description: c.ruleName,
+		Enabled:     enabled,
+	}
+
+	// Skip the remaining custom rules for matching requests
+	if rule.Action == "skip" {
+		rule.ActionParameters = map[string]interface{}{"ruleset": "current"}
+	}
+
+	return rule
+}
+
+// putRulesetRule creates or updates the managed rule in the zone's entry point ruleset,
+// migrating a filter-based rule of the same name the first time
+func (c *CloudflareIngress) putRulesetRule(ctx context.Context, expression string, enabled bool) error {
+	ruleset, existing, err := c.findRulesetRule(ctx)
+	if err != nil {
+		return err
+	}
+
+	rule := c.rulesetRule(expression, enabled)
+	if !enabled {
+		// A disabled rule matches nothing, whatever its expression
+		expression = ""
+	}
+
+	switch {
+	case ruleset == nil:
+		// The zone has no custom rules yet, create the entry point ruleset with our rule
+		log.Info("Creating Cloudflare entry point ruleset", "ingress", c.name, "ref", rule.Ref)
+		entrypoint := Ruleset{Name: "default", Kind: "zone", Phase: customRulesPhase, Rules: []RulesetRule{rule}}
+		var created Ruleset
+		if _, err := c.client.Do(ctx, "PUT", c.entrypointPath(), entrypoint, &created); err != nil {
+			return fmt.Errorf("error creating entry point ruleset: %w", err)
+		}
+		c.recordRulesetRule(&created)
+
+	case existing == nil:
+		log.Info("Creating new Cloudflare ruleset rule", "ingress", c.name, "ref", rule.Ref)
+		url := fmt.Sprintf("/zones/%s/rulesets/%s/rules", c.zoneID, ruleset.ID)
+		var updated Ruleset
+		if _, err := c.client.Do(ctx, "POST", url, rule, &updated); err != nil {
+			return fmt.Errorf("error creating ruleset rule: %w", err)
+		}
+		c.recordRulesetRule(&updated)
+
+	default:
+		if existing.Expression == rule.Expression && existing.Action == rule.Action && existing.Enabled == rule.Enabled && existing.Ref == rule.Ref {
+			c.cacheExpression(expression)
+			return nil
+		}
+
+		log.Info("Updating existing Cloudflare ruleset rule", "ingress", c.name, "ref", rule.Ref, "ruleID", existing.ID)
+		url := fmt.Sprintf("/zones/%s/rulesets/%s/rules/%s", c.zoneID, ruleset.ID, existing.ID)
+		if _, err := c.client.Do(ctx, "PATCH", url, rule, nil); err != nil {
+			return fmt.Errorf("error updating ruleset rule: %w", err)
+		}
+	}
+
+	c.cacheExpression(expression)
+
+	if existing == nil {
+		if err := c.migrateFilterRule(ctx); err != nil {
+			// The new rule is in place, so the old one only duplicates it
+			log.Error(err, "Error removing migrated filter-based rule", "ingress", c.name, "ruleName", c.ruleName)
+		}
+	}
+	c.migrationPending = false
+
+	return nil
+}
+
+// migrateFilterRule deletes the filter-based rule and filter previously managed for this rule, if any
+func (c *CloudflareIngress) migrateFilterRule(ctx context.Context) error {
+	filter, err := c.findFilter(ctx)
+	if err != nil {
+		return err
+	}
+	if filter == nil {
+		return nil
+	}
+	filterID := filter.ID
+
+	log.Info("Migrating filter-based Cloudflare rule to rulesets", "ingress", c.name, "ruleName", c.ruleName, "filterID", filterID)
+
+	rule, err := c.findFirewallRule(ctx, filterID)
+	if err != nil {
+		return err
+	}
+	if rule != nil {
+		url := fmt.Sprintf("/zones/%s/firewall/rules/%s", c.zoneID, rule.ID)
+		if _, err := c.client.Do(ctx, "DELETE", url, nil, nil); err != nil {
+			return fmt.Errorf("error deleting firewall rule %s: %w", rule.ID, err)
+		}
+	}
+
+	url := fmt.Sprintf("/zones/%s/filters/%s", c.zoneID, filterID)
+	if _, err := c.client.Do(ctx, "DELETE", url, nil, nil); err != nil {
+		return fmt.Errorf("error deleting filter %s: %w", filterID, err)
+	}
+	c.filterID = ""
+	c.firewallRuleID = ""
+
+	return nil
+}
+
+// cacheExpression caches the ranges of an expression that was just applied, or an empty set for ""
+func (c *CloudflareIngress) cacheExpression(expression string) {
+	ipRangeSet := model.NewIPRangeSet()
+	if expression != "" {
+		ipRangeSet = c.parseFilterExpression(expression)
+	}
+
+	c.cacheMutex.Lock()
+	c.cachedData = ipRangeSet
+	c.lastFetch = time.Now()
+	c.cacheMutex.Unlock()
+}
+
+// validateRulesetAction checks that the action is supported by the Rulesets API
+func validateRulesetAction(action string) error {
+	if _, ok := rulesetActions[action]; !ok {
+		return fmt.Errorf("action %s is not supported by the rulesets engine (supported: allow, skip, block, managed_challenge, challenge, js_challenge, log)", action)
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/ingress"
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+)
+
+const testZoneID = "zone-id"
+
+// fakeCloudflare serves the filters, firewall rules and rulesets of a single zone
+type fakeCloudflare struct {
+	mutex         sync.Mutex
+	filters       []Filter
+	firewallRules []CloudflareRule
+	ruleset       *Ruleset
+	changes       []string
+	nextID        int
+}
+
+func (f *fakeCloudflare) newID(kind string) string {
+	f.nextID++
+	return fmt.Sprintf("%s-%d", kind, f.nextID)
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if r.Method != http.MethodGet {
+		f.changes = append(f.changes, r.Method+" "+r.URL.Path)
+	}
+
+	zonePath := "/zones/" + testZoneID
+	entrypointPath := zonePath + "/rulesets/phases/" + customRulesPhase + "/entrypoint"
+	path := r.URL.Path
+
+	switch {
+	case r.Method == http.MethodGet && path == zonePath+"/filters":
+		writeResult(w, f.filters)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, zonePath+"/filters/"):
+		for _, filter := range f.filters {
+			if filter.ID == strings.TrimPrefix(path, zonePath+"/filters/") {
+				writeResult(w, filter)
+				return
+			}
+		}
+		writeNotFound(w)
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, zonePath+"/filters/"):
+		f.filters = removeByID(f.filters, strings.TrimPrefix(path, zonePath+"/filters/"), func(filter Filter) string { return filter.ID })
+		writeResult(w, nil)
+	case r.Method == http.MethodGet && path == zonePath+"/firewall/rules":
+		writeResult(w, f.firewallRules)
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, zonePath+"/firewall/rules/"):
+		f.firewallRules = removeByID(f.firewallRules, strings.TrimPrefix(path, zonePath+"/firewall/rules/"), func(rule CloudflareRule) string { return rule.ID })
+		writeResult(w, nil)
+
+	case r.Method == http.MethodGet && path == entrypointPath:
+		if f.ruleset == nil {
+			writeNotFound(w)
+			return
+		}
+		writeResult(w, f.ruleset)
+	case r.Method == http.MethodPut && path == entrypointPath:
+		var ruleset Ruleset
+		if err := json.NewDecoder(r.Body).Decode(&ruleset); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ruleset.ID = f.newID("ruleset")
+		for i := range ruleset.Rules {
+			ruleset.Rules[i].ID = f.newID("rule")
+		}
+		f.ruleset = &ruleset
+		writeResult(w, f.ruleset)
+	case f.ruleset != nil && r.Method == http.MethodPost && path == zonePath+"/rulesets/"+f.ruleset.ID+"/rules":
+		var rule RulesetRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rule.ID = f.newID("rule")
+		f.ruleset.Rules = append(f.ruleset.Rules, rule)
+		writeResult(w, f.ruleset)
+	case f.ruleset != nil && r.Method == http.MethodPatch && strings.HasPrefix(path, zonePath+"/rulesets/"+f.ruleset.ID+"/rules/"):
+		ruleID := strings.TrimPrefix(path, zonePath+"/rulesets/"+f.ruleset.ID+"/rules/")
+		for i := range f.ruleset.Rules {
+			if f.ruleset.Rules[i].ID == ruleID {
+				var rule RulesetRule
+				if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				rule.ID = ruleID
+				f.ruleset.Rules[i] = rule
+				writeResult(w, f.ruleset)
+				return
+			}
+		}
+		writeNotFound(w)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// takeChanges returns the modifying requests received since the last call
+func (f *fakeCloudflare) takeChanges() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	changes := f.changes
+	f.changes = nil
+	return changes
+}
+
+func removeByID[T any](items []T, id string, idOf func(T) string) []T {
+	var kept []T
+	for _, item := range items {
+		if idOf(item) != id {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	data, _ := json.Marshal(result)
+	fmt.Fprintf(w, `{"success": true, "errors": [], "messages": [], "result": %s}`, data)
+}
+
+func writeNotFound(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write([]byte(`{"success": false, "errors": [{"code": 7003, "message": "Could not route"}], "messages": [], "result": null}`))
+}
+
+// newTestIngress returns a Cloudflare ingress for the test zone talking to the fake API
+func newTestIngress(t *testing.T, api *fakeCloudflare, options map[string]interface{}) *CloudflareIngress {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	allOptions := map[string]interface{}{
+		"name":     "office",
+		"apiToken": "token",
+		"baseURL":  server.URL,
+		"zoneId":   testZoneID,
+		"ruleName": "Office ranges",
+		"ownerUID": "uid",
+	}
+	for key, value := range options {
+		allOptions[key] = value
+	}
+
+	c := &CloudflareIngress{}
+	if err := c.Init(context.Background(), allOptions); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	return c
+}
+
+func newRangeSet(t *testing.T, cidrs ...string) *model.IPRangeSet {
+	t.Helper()
+	s := model.NewIPRangeSet()
+	for _, cidr := range cidrs {
+		if err := s.Add(cidr, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestMigrateFilterRule(t *testing.T) {
+	unrelated := RulesetRule{ID: "rule-other", Ref: "other", Action: "block", Expression: `ip.geoip.country eq "XX"`, Enabled: true}
+	api := &fakeCloudflare{
+		filters: []Filter{
+			{ID: "filter-other", Expression: `http.host eq "other.example.com"`, Description: "Other"},
+			{ID: "filter-office", Expression: `(ip.src in {192.0.2.0/24}) and http.host eq "example.com"`, Description: "Office ranges", Ref: ownershipRef("uid")},
+		},
+		firewallRules: []CloudflareRule{
+			{ID: "firewall-other", Action: "block", Filter: Filter{ID: "filter-other"}},
+			{ID: "firewall-office", Action: "allow", Filter: Filter{ID: "filter-office"}, Ref: ownershipRef("uid")},
+		},
+		ruleset: &Ruleset{ID: "ruleset-entrypoint", Phase: customRulesPhase, Rules: []RulesetRule{unrelated}},
+	}
+	c := newTestIngress(t, api, nil)
+
+	current, err := c.GetCurrentIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("GetCurrentIPRanges() error = %v", err)
+	}
+	if got := current.GetCIDRs(); !reflect.DeepEqual(got, []string{"192.0.2.0/24"}) {
+		t.Errorf("GetCurrentIPRanges() = %v, want the ranges of the filter-based rule", got)
+	}
+
+	// Unchanged ranges are still applied so that the rule is migrated
+	if err := c.ApplyIPRanges(context.Background(), current); err != nil {
+		t.Fatalf("ApplyIPRanges() error = %v", err)
+	}
+
+	wantChanges := []string{
+		"POST /zones/zone-id/rulesets/ruleset-entrypoint/rules",
+		"DELETE /zones/zone-id/firewall/rules/firewall-office",
+		"DELETE /zones/zone-id/filters/filter-office",
+	}
+	if got := api.takeChanges(); !reflect.DeepEqual(got, wantChanges) {
+		t.Errorf("ApplyIPRanges() sent %v, want %v", got, wantChanges)
+	}
+
+	if len(api.ruleset.Rules) != 2 || !reflect.DeepEqual(api.ruleset.Rules[0], unrelated) {
+		t.Fatalf("ruleset rules = %+v, want the unrelated rule followed by the migrated one", api.ruleset.Rules)
+	}
+	migrated := api.ruleset.Rules[1]
+	want := RulesetRule{
+		ID:               migrated.ID,
+		Ref:              ownershipRef("uid"),
+		Action:           "skip",
+		ActionParameters: map[string]interface{}{"ruleset": "current"},
+		Expression:       `(ip.src in {192.0.2.0/24}) and http.host eq "example.com"`,
+		Description:      "Office ranges",
+		Enabled:          true,
+	}
+	if !reflect.DeepEqual(migrated, want) {
+		t.Errorf("migrated rule = %+v, want %+v", migrated, want)
+	}
+
+	if len(api.filters) != 1 || api.filters[0].ID != "filter-other" || len(api.firewallRules) != 1 || api.firewallRules[0].ID != "firewall-other" {
+		t.Errorf("filters = %+v and firewall rules = %+v, want only the unrelated ones", api.filters, api.firewallRules)
+	}
+
+	wantObjects := []ingress.ManagedObject{{Kind: objectRulesetRule, ID: migrated.ID, Scope: testZoneID}}
+	if got := c.ManagedObjects(); !reflect.DeepEqual(got, wantObjects) {
+		t.Errorf("ManagedObjects() = %+v, want %+v", got, wantObjects)
+	}
+
+	// Once migrated, applying the same ranges again changes nothing
+	c.cacheTTL = 0
+	if err := c.ApplyIPRanges(context.Background(), current); err != nil {
+		t.Fatalf("ApplyIPRanges() error = %v", err)
+	}
+	if got := api.takeChanges(); len(got) != 0 {
+		t.Errorf("ApplyIPRanges() sent %v, want no changes", got)
+	}
+
+	// New ranges update the migrated rule in place
+	if err := c.ApplyIPRanges(context.Background(), newRangeSet(t, "192.0.2.0/24", "198.51.100.0/24")); err != nil {
+		t.Fatalf("ApplyIPRanges() error = %v", err)
+	}
+	wantChanges = []string{"PATCH /zones/zone-id/rulesets/ruleset-entrypoint/rules/" + migrated.ID}
+	if got := api.takeChanges(); !reflect.DeepEqual(got, wantChanges) {
+		t.Errorf("ApplyIPRanges() sent %v, want %v", got, wantChanges)
+	}
+	if got, want := api.ruleset.Rules[1].Expression, `(ip.src in {192.0.2.0/24 198.51.100.0/24}) and http.host eq "example.com"`; got != want {
+		t.Errorf("updated expression = %q, want %q", got, want)
+	}
+}
+
+func TestCreateEntrypointRuleset(t *testing.T) {
+	api := &fakeCloudflare{}
+	c := newTestIngress(t, api, map[string]interface{}{"action": "block"})
+
+	if err := c.ApplyIPRanges(context.Background(), newRangeSet(t, "192.0.2.0/24")); err != nil {
+		t.Fatalf("ApplyIPRanges() error = %v", err)
+	}
+
+	wantChanges := []string{"PUT /zones/zone-id/rulesets/phases/http_request_firewall_custom/entrypoint"}
+	if got := api.takeChanges(); !reflect.DeepEqual(got, wantChanges) {
+		t.Errorf("ApplyIPRanges() sent %v, want %v", got, wantChanges)
+	}
+	if api.ruleset == nil || len(api.ruleset.Rules) != 1 {
+		t.Fatalf("ruleset = %+v, want the entry point with the managed rule", api.ruleset)
+	}
+	rule := api.ruleset.Rules[0]
+	if rule.Action != "block" || rule.ActionParameters != nil || rule.Expression != "(ip.src in {192.0.2.0/24})" || c.ruleID != rule.ID {
+		t.Errorf("rule = %+v (recorded ID %q), want a block rule for the ranges", rule, c.ruleID)
+	}
+}
+
+func TestRulesetRuleOwnership(t *testing.T) {
+	legacy := func() *fakeCloudflare {
+		return &fakeCloudflare{
+			ruleset: &Ruleset{ID: "ruleset-entrypoint", Rules: []RulesetRule{
+				{ID: "rule-legacy", Ref: "ingress_meta_sync_office_ranges", Action: "skip", Expression: "(ip.src in {192.0.2.0/24})", Enabled: true},
+			}},
+		}
+	}
+
+	t.Run("legacy ref is not taken over without adopt", func(t *testing.T) {
+		api := legacy()
+		c := newTestIngress(t, api, nil)
+		if err := c.ApplyIPRanges(context.Background(), newRangeSet(t, "198.51.100.0/24")); err == nil {
+			t.Error("ApplyIPRanges() error = nil, want an error for a rule that is not owned")
+		}
+		if got := api.takeChanges(); len(got) != 0 {
+			t.Errorf("ApplyIPRanges() sent %v, want no changes", got)
+		}
+	})
+
+	t.Run("legacy ref is adopted and tagged", func(t *testing.T) {
+		api := legacy()
+		c := newTestIngress(t, api, map[string]interface{}{"adopt": true})
+		if err := c.ApplyIPRanges(context.Background(), newRangeSet(t, "198.51.100.0/24")); err != nil {
+			t.Fatalf("ApplyIPRanges() error = %v", err)
+		}
+		rule := api.ruleset.Rules[0]
+		if rule.ID != "rule-legacy" || rule.Ref != ownershipRef("uid") || rule.Expression != "(ip.src in {198.51.100.0/24})" {
+			t.Errorf("rule = %+v, want the legacy rule tagged and updated", rule)
+		}
+	})
+
+	t.Run("recorded rule is found by ID", func(t *testing.T) {
+		api := legacy()
+		c := newTestIngress(t, api, map[string]interface{}{
+			"managedObjects": []ingress.ManagedObject{{Kind: objectRulesetRule, ID: "rule-legacy", Scope: testZoneID}},
+		})
+		if err := c.ApplyIPRanges(context.Background(), newRangeSet(t, "198.51.100.0/24")); err != nil {
+			t.Fatalf("ApplyIPRanges() error = %v", err)
+		}
+		if rule := api.ruleset.Rules[0]; len(api.ruleset.Rules) != 1 || rule.Ref != ownershipRef("uid") {
+			t.Errorf("rules = %+v, want the recorded rule tagged and updated", api.ruleset.Rules)
+		}
+	})
+}
+
+func TestFilterOwnership(t *testing.T) {
+	untagged := func() *fakeCloudflare {
+		return &fakeCloudflare{
+			filters:       []Filter{{ID: "filter-human", Expression: "(ip.src in {203.0.113.0/24})", Description: "Office ranges"}},
+			firewallRules: []CloudflareRule{{ID: "firewall-human", Action: "allow", Filter: Filter{ID: "filter-human"}}},
+		}
+	}
+
+	for _, engine := range []string{engineRulesets, engineFirewallRules} {
+		t.Run("untagged filter is not taken over without adopt by "+engine, func(t *testing.T) {
+			api := untagged()
+			c := newTestIngress(t, api, map[string]interface{}{"engine": engine})
+			if err := c.ApplyIPRanges(context.Background(), newRangeSet(t, "198.51.100.0/24")); err == nil {
+				t.Error("ApplyIPRanges() error = nil, want an error for a filter that is not owned")
+			}
+			if got := api.takeChanges(); len(got) != 0 {
+				t.Errorf("ApplyIPRanges() sent %v, want no changes", got)
+			}
+			if len(api.filters) != 1 || api.filters[0].Expression != "(ip.src in {203.0.113.0/24})" || len(api.firewallRules) != 1 {
+				t.Errorf("filters = %+v and firewall rules = %+v, want them untouched", api.filters, api.firewallRules)
+			}
+		})
+	}
+
+	t.Run("untagged filter is migrated with adopt", func(t *testing.T) {
+		api := untagged()
+		c := newTestIngress(t, api, map[string]interface{}{"adopt": true})
+		if err := c.ApplyIPRanges(context.Background(), newRangeSet(t, "198.51.100.0/24")); err != nil {
+			t.Fatalf("ApplyIPRanges() error = %v", err)
+		}
+		if len(api.filters) != 0 || len(api.firewallRules) != 0 || api.ruleset == nil || len(api.ruleset.Rules) != 1 {
+			t.Errorf("filters = %+v, firewall rules = %+v and ruleset = %+v, want the rule migrated", api.filters, api.firewallRules, api.ruleset)
+		}
+	})
+}
+
+func TestValidateRulesetAction(t *testing.T) {
+	for _, action := range []string{"allow", "skip", "block", "managed_challenge", "challenge", "js_challenge", "log"} {
+		if err := validateRulesetAction(action); err != nil {
+			t.Errorf("validateRulesetAction(%q) error = %v", action, err)
+		}
+	}
+	for _, action := range []string{"bypass", "Block", ""} {
+		if err := validateRulesetAction(action); err == nil {
+			t.Errorf("validateRulesetAction(%q) error = nil, want an error", action)
+		}
+	}
+
+	// Actions only known to firewall rules are still accepted by that engine
+	options := map[string]interface{}{"apiToken": "token", "zoneId": testZoneID, "ruleName": "r", "action": "bypass"}
+	if err := (&CloudflareIngress{}).Init(context.Background(), options); err == nil {
+		t.Error("Init() error = nil, want an error for an action the rulesets engine does not support")
+	}
+	options["engine"] = engineFirewallRules
+	if err := (&CloudflareIngress{}).Init(context.Background(), options); err != nil {
+		t.Errorf("Init() error = %v", err)
+	}
+}
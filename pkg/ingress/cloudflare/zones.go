@@ -0,0 +1,196 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/ingress"
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+)
+
+// defaultZoneConcurrency is the number of zones updated at once by default
+const defaultZoneConcurrency = 4
+
+// Zone represents a Cloudflare zone
+type Zone struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// multiZone reports whether the rule is applied to several zones
+func (c *CloudflareIngress) multiZone() bool {
+	return len(c.zoneIDs) > 0 || c.zoneSelector != ""
+}
+
+// resolveZones returns the configured zones followed by the zones matching the selector, without duplicates
+func (c *CloudflareIngress) resolveZones(ctx context.Context) ([]Zone, error) {
+	var zones []Zone
+	seen := make(map[string]bool)
+	for _, zoneID := range c.zoneIDs {
+		if !seen[zoneID] {
+			seen[zoneID] = true
+			zones = append(zones, Zone{ID: zoneID})
+		}
+	}
+
+	if c.zoneSelector == "" {
+		return zones, nil
+	}
+
+	query := url.Values{}
+	query.Set("name", c.zoneSelector)
+	if c.accountID != "" {
+		query.Set("account.id", c.accountID)
+	}
+
+	err := c.client.List(ctx, "/zones", query, func(result json.RawMessage) error {
+		var matched []Zone
+		if err := json.Unmarshal(result, &matched); err != nil {
+			return fmt.Errorf("error unmarshaling zones: %w", err)
+		}
+
+		for _, zone := range matched {
+			if !seen[zone.ID] {
+				seen[zone.ID] = true
+				zones = append(zones, zone)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing zones matching %s: %w", c.zoneSelector, err)
+	}
+	return zones, nil
+}
+
+// zoneIngress returns the ingress managing the rule of a single zone, reusing it across syncs to keep its cache
+func (c *CloudflareIngress) zoneIngress(zoneID string) *CloudflareIngress {
+	c.zonesMutex.Lock()
+	defer c.zonesMutex.Unlock()
+
+	if zone, ok := c.zones[zoneID]; ok {
+		return zone
+	}
+
+	zone := &CloudflareIngress{
+		name:            c.name,
+		client:          c.client,
+		zoneID:          zoneID,
+		engine:          c.engine,
+		accountID:       c.accountID,
+		listName:        c.listName,
+		listDescription: c.listDescription,
+		mode:            modeRule,
+		manageRule:      true,
+		ruleName:        c.ruleName,
+		description:     c.description,
+		action:          c.action,
+		priority:        c.priority,
+		updateStrategy:  "direct",
+		cacheTTL:        c.cacheTTL,
+		ownerRef:        c.ownerRef,
+		adopt:           c.adopt,
+		knownObjects:    c.knownObjects,
+	}
+	zone.restoreZoneObjects()
+	if c.zones == nil {
+		c.zones = make(map[string]*CloudflareIngress)
+	}
+	c.zones[zoneID] = zone
+	return zone
+}
+
+// applyToZones applies the ranges to every zone, a bounded number at a time. With a list, the list is
+// updated once for the account and each zone only gets a rule referencing it.
+func (c *CloudflareIngress) applyToZones(ctx context.Context, ipRanges *model.IPRangeSet) error {
+	if c.usesList() {
+		if err := c.applyIncremental(ctx, ipRanges); err != nil {
+			return err
+		}
+		if !c.manageRule {
+			return nil
+		}
+	}
+
+	zones, err := c.resolveZones(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Applying IP ranges to Cloudflare zones", "ingress", c.name, "zones", len(zones), "concurrency", c.zoneConcurrency)
+
+	statuses := make([]ingress.TargetStatus, len(zones))
+	semaphore := make(chan struct{}, c.zoneConcurrency)
+	var wg sync.WaitGroup
+
+	for i, zone := range zones {
+		wg.Add(1)
+		go func(i int, zone Zone) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			zoneIngress := c.zoneIngress(zone.ID)
+
+			var err error
+			if c.usesList() {
+				err = zoneIngress.ensureListRule(ctx)
+			} else {
+				err = zoneIngress.ApplyIPRanges(ctx, ipRanges)
+			}
+
+			statuses[i] = ingress.TargetStatus{ID: zone.ID, Name: zone.Name, LastSyncTime: time.Now()}
+			if err != nil {
+				log.Error(err, "Error applying IP ranges to Cloudflare zone", "ingress", c.name, "zoneID", zone.ID, "zoneName", zone.Name)
+				statuses[i].Error = err.Error()
+			}
+		}(i, zone)
+	}
+	wg.Wait()
+
+	c.zonesMutex.Lock()
+	c.zoneStatuses = statuses
+	c.zonesMutex.Unlock()
+
+	var failed []string
+	for _, status := range statuses {
+		if status.Error != "" {
+			failed = append(failed, status.ID)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("error applying IP ranges to %d of %d zones: %s", len(failed), len(zones), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// getZonesIPRanges returns the union of the ranges applied to every zone
+func (c *CloudflareIngress) getZonesIPRanges(ctx context.Context) (*model.IPRangeSet, error) {
+	zones, err := c.resolveZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ipRanges := model.NewIPRangeSet()
+	for _, zone := range zones {
+		zoneRanges, err := c.zoneIngress(zone.ID).GetCurrentIPRanges(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting IP ranges of zone %s: %w", zone.ID, err)
+		}
+		ipRanges = ipRanges.Merge(zoneRanges)
+	}
+	return ipRanges, nil
+}
+
+// TargetStatuses returns the outcome of the last apply for each zone
+func (c *CloudflareIngress) TargetStatuses() []ingress.TargetStatus {
+	c.zonesMutex.Lock()
+	defer c.zonesMutex.Unlock()
+	return append([]ingress.TargetStatus(nil), c.zoneStatuses...)
+}
@@ -2,6 +2,10 @@ package ingress
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 	
 	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -25,6 +29,64 @@ type Ingress interface {
 	GetCurrentIPRanges(ctx context.Context) (*model.IPRangeSet, error)
 }
 
+// TargetStatus is the outcome of the last apply on one target of an ingress, e.g. a Cloudflare zone
+type TargetStatus struct {
+	ID           string
+	Name         string
+	Error        string
+	LastSyncTime time.Time
+}
+
+// TargetReporter is implemented by ingresses that apply the same ranges to several targets
+type TargetReporter interface {
+	// TargetStatuses returns the outcome of the last apply for each target
+	TargetStatuses() []TargetStatus
+}
+
+// ManagedObject identifies an object an ingress created or adopted in the target system
+type ManagedObject struct {
+	// Kind is the type of object, e.g. "filter" or "list"
+	Kind string
+	
+	// ID is the identifier of the object in the target system
+	ID string
+	
+	// Scope is where the object lives, e.g. a Cloudflare zone or account
+	Scope string
+}
+
+// ObjectReporter is implemented by ingresses that keep track of the objects they manage,
+// so that they can find them again without matching on names that may change
+type ObjectReporter interface {
+	// ManagedObjects returns the objects currently managed by the ingress
+	ManagedObjects() []ManagedObject
+}
+
+// PartialApplyError is returned by ApplyIPRanges when some changes were applied and others failed
+// Skipped counts the changes that were not attempted because an earlier operation failed.
+type PartialApplyError struct {
+	Ingress  string
+	Added    int
+	Removed  int
+	Skipped  int
+	Failures []string
+}
+
+// Error implements the error interface
+func (e *PartialApplyError) Error() string {
+	return fmt.Sprintf("ingress %s partially applied (%d added, %d removed, %d skipped), %d operations failed: %s",
+		e.Ingress, e.Added, e.Removed, e.Skipped, len(e.Failures), strings.Join(e.Failures, "; "))
+}
+
+// IsPartiallyApplied returns the PartialApplyError wrapped in err, if any
+func IsPartiallyApplied(err error) (*PartialApplyError, bool) {
+	var partialErr *PartialApplyError
+	if errors.As(err, &partialErr) {
+		return partialErr, true
+	}
+	return nil, false
+}
+
 var log = ctrl.Log.WithName("ingress")
 
 // Registry is a registry of available ingress services
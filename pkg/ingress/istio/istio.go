@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -13,6 +14,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -59,6 +61,17 @@ type IstioIngress struct {
 
 var log = ctrl.Log.WithName("ingress.istio")
 
+const (
+	// provenanceKey is the ConfigMap key holding the sources of the ranges, grouped by source
+	provenanceKey = "ip_ranges_provenance"
+
+	// maxProvenanceSize caps the encoded provenance, leaving most of the 1MiB ConfigMap limit to the ranges
+	maxProvenanceSize = 256 * 1024
+
+	// sourcesAnnotation summarizes how many ranges each provider contributed
+	sourcesAnnotation = "ingress-meta-sync.k8s.io/sources"
+)
+
 // Name returns the ingress name
 func (i *IstioIngress) Name() string {
 	return i.name
@@ -187,8 +200,16 @@ func (i *IstioIngress) GetCurrentIPRanges(ctx context.Context) (*model.IPRangeSe
 			return nil, fmt.Errorf("error unmarshaling IP ranges: %w", err)
 		}
 
+		// Restore where each range came from, if recorded
+		var provenance map[string][]model.Provenance
+		if provenanceData, ok := configMap.Data[provenanceKey]; ok {
+			if provenance, err = decodeProvenance(provenanceData); err != nil {
+				log.Error(err, "Error unmarshaling IP range provenance", "ingress", i.name)
+			}
+		}
+
 		for _, cidr := range ranges {
-			if err := ipRanges.Add(cidr, []string{"istio"}); err != nil {
+			if err := ipRanges.AddIPRange(model.IPRange{CIDR: cidr, Labels: []string{"istio"}, Sources: provenance[cidr]}); err != nil {
 				log.Error(err, "Error adding CIDR to IP range set", "cidr", cidr)
 			}
 		}
@@ -247,6 +268,13 @@ func (i *IstioIngress) updateConfigMap(ctx context.Context, ipRanges *model.IPRa
 	if err != nil {
 		return fmt.Errorf("error marshaling IP ranges: %w", err)
 	}
+
+	// Record where every range came from so it can be traced back to its provider. The ranges
+	// matter more than their provenance, so they are stored without it if it cannot be.
+	provenanceJSON, err := encodeProvenance(ipRanges)
+	if err != nil {
+		log.Error(err, "Storing IP ranges without their provenance", "ingress", i.name)
+	}
 	
 	// Check if the ConfigMap already exists
 	configMap := &corev1.ConfigMap{}
@@ -266,13 +294,23 @@ func (i *IstioIngress) updateConfigMap(ctx context.Context, ipRanges *model.IPRa
 					"app.kubernetes.io/instance":   i.name,
 					"app.kubernetes.io/managed-by": "ingress-meta-sync-controller",
 				},
+				Annotations: map[string]string{
+					sourcesAnnotation: sourcesSummary(ipRanges),
+				},
 			},
 			Data: map[string]string{
 				"ip_ranges": string(rangesJSON),
 			},
 		}
+		setProvenance(configMap, provenanceJSON)
 		
-		if err := i.k8sClient.Create(ctx, configMap); err != nil {
+		err := i.k8sClient.Create(ctx, configMap)
+		if err != nil && rejectedForProvenance(configMap, err) {
+			log.Error(err, "Creating ConfigMap without IP range provenance", "ingress", i.name, "configMap", configMapName)
+			setProvenance(configMap, "")
+			err = i.k8sClient.Create(ctx, configMap)
+		}
+		if err != nil {
 			return fmt.Errorf("error creating ConfigMap: %w", err)
 		}
 		
@@ -283,8 +321,19 @@ func (i *IstioIngress) updateConfigMap(ctx context.Context, ipRanges *model.IPRa
 			configMap.Data = make(map[string]string)
 		}
 		configMap.Data["ip_ranges"] = string(rangesJSON)
+		setProvenance(configMap, provenanceJSON)
+		if configMap.Annotations == nil {
+			configMap.Annotations = make(map[string]string)
+		}
+		configMap.Annotations[sourcesAnnotation] = sourcesSummary(ipRanges)
 		
-		if err := i.k8sClient.Update(ctx, configMap); err != nil {
+		err := i.k8sClient.Update(ctx, configMap)
+		if err != nil && rejectedForProvenance(configMap, err) {
+			log.Error(err, "Updating ConfigMap without IP range provenance", "ingress", i.name, "configMap", configMapName)
+			setProvenance(configMap, "")
+			err = i.k8sClient.Update(ctx, configMap)
+		}
+		if err != nil {
 			return fmt.Errorf("error updating ConfigMap: %w", err)
 		}
 		
@@ -300,6 +349,101 @@ func (i *IstioIngress) updateConfigMap(ctx context.Context, ipRanges *model.IPRa
 	return nil
 }
 
+// provenanceGroup lists the ranges that came from the same source
+type provenanceGroup struct {
+	Source model.Provenance `json:"source"`
+	CIDRs  []string         `json:"cidrs"`
+}
+
+// encodeProvenance returns the sources of the ranges, grouped by source as most of a provider's
+// ranges share one. It fails if the result would not leave room for the ranges in the ConfigMap.
+func encodeProvenance(ipRanges *model.IPRangeSet) (string, error) {
+	// Groups are kept in the order their source first appears, so the encoding is stable
+	var groups []provenanceGroup
+	groupIndex := make(map[model.Provenance]int)
+	for _, ipRange := range ipRanges.Ranges {
+		for _, source := range ipRange.Sources {
+			source.FirstSeen = source.FirstSeen.UTC()
+			idx, ok := groupIndex[source]
+			if !ok {
+				idx = len(groups)
+				groupIndex[source] = idx
+				groups = append(groups, provenanceGroup{Source: source})
+			}
+			groups[idx].CIDRs = append(groups[idx].CIDRs, ipRange.CIDR)
+		}
+	}
+
+	value, err := json.Marshal(groups)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling IP range provenance: %w", err)
+	}
+	if len(value) > maxProvenanceSize {
+		return "", fmt.Errorf("provenance of %d ranges takes %d bytes, over the limit of %d", ipRanges.Count(), len(value), maxProvenanceSize)
+	}
+	return string(value), nil
+}
+
+// decodeProvenance returns the sources of every range, keyed by CIDR
+func decodeProvenance(value string) (map[string][]model.Provenance, error) {
+	var groups []provenanceGroup
+	if err := json.Unmarshal([]byte(value), &groups); err != nil {
+		return nil, err
+	}
+
+	provenance := make(map[string][]model.Provenance)
+	for _, group := range groups {
+		for _, cidr := range group.CIDRs {
+			provenance[cidr] = append(provenance[cidr], group.Source)
+		}
+	}
+	return provenance, nil
+}
+
+// setProvenance stores the encoded provenance in the ConfigMap, or removes it if there is none
+func setProvenance(configMap *corev1.ConfigMap, provenanceJSON string) {
+	if provenanceJSON == "" {
+		delete(configMap.Data, provenanceKey)
+		return
+	}
+	configMap.Data[provenanceKey] = provenanceJSON
+}
+
+// rejectedForProvenance reports whether writing the ConfigMap failed in a way that storing it
+// without provenance could avoid
+func rejectedForProvenance(configMap *corev1.ConfigMap, err error) bool {
+	if _, ok := configMap.Data[provenanceKey]; !ok {
+		return false
+	}
+	return apierrors.IsRequestEntityTooLargeError(err) || apierrors.IsInvalid(err)
+}
+
+// sourcesSummary describes how many ranges each provider contributed, e.g. "aws-ranges=12, github-ip-ranges=4321"
+func sourcesSummary(ipRanges *model.IPRangeSet) string {
+	counts := make(map[string]int)
+	for _, ipRange := range ipRanges.Ranges {
+		providerNames := make(map[string]bool)
+		for _, source := range ipRange.Sources {
+			providerNames[source.ProviderName] = true
+		}
+		for providerName := range providerNames {
+			counts[providerName]++
+		}
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for idx, name := range names {
+		parts[idx] = fmt.Sprintf("%s=%d", name, counts[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
 // updateEnvoyFilter updates or creates an EnvoyFilter to handle X-Forwarded-For headers
 func (i *IstioIngress) updateEnvoyFilter(ctx context.Context, ipRanges *model.IPRangeSet) error {
 	if ipRanges.Count() == 0 {
@@ -0,0 +1,191 @@
+package istio
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+)
+
+// rejectingClient fails writes of ConfigMaps holding provenance, as the API server does for oversized objects
+type rejectingClient struct {
+	client.Client
+}
+
+func (c *rejectingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.reject(obj); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *rejectingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.reject(obj); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *rejectingClient) reject(obj client.Object) error {
+	if configMap, ok := obj.(*corev1.ConfigMap); ok {
+		if _, ok := configMap.Data[provenanceKey]; ok {
+			return apierrors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, configMap.Name, nil)
+		}
+	}
+	return nil
+}
+
+func newTestIngress(t *testing.T, k8sClient client.Client) *IstioIngress {
+	t.Helper()
+	return &IstioIngress{
+		name:         "office",
+		namespace:    "istio-system",
+		resourceName: "ip-ranges",
+		cacheTTL:     time.Hour,
+		k8sClient:    k8sClient,
+	}
+}
+
+func newFakeClient(t *testing.T) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).Build()
+}
+
+func testRanges(t *testing.T) *model.IPRangeSet {
+	t.Helper()
+	firstSeen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	github := model.Provenance{ProviderName: "github-ranges", ProviderType: "github", Category: "actions", FirstSeen: firstSeen}
+	aws := model.Provenance{ProviderName: "aws-ranges", ProviderType: "aws", Category: "EC2", Region: "us-east-1", FirstSeen: firstSeen}
+
+	ipRanges := model.NewIPRangeSet()
+	for _, cidr := range []string{"192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24"} {
+		if err := ipRanges.AddWithSource(cidr, nil, github); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ipRanges.AddWithSource("203.0.113.0/24", nil, aws); err != nil {
+		t.Fatal(err)
+	}
+	return ipRanges
+}
+
+func TestUpdateConfigMapProvenance(t *testing.T) {
+	k8sClient := newFakeClient(t)
+	ipRanges := testRanges(t)
+
+	i := newTestIngress(t, k8sClient)
+	if err := i.updateConfigMap(context.Background(), ipRanges); err != nil {
+		t.Fatalf("updateConfigMap() error = %v", err)
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := k8sClient.Get(context.Background(), client.ObjectKey{Namespace: "istio-system", Name: "office-ip-ranges"}, configMap); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	// Each source is stored once, with the ranges it contributed
+	provenanceData := configMap.Data[provenanceKey]
+	if got := strings.Count(provenanceData, `"providerName"`); got != 2 {
+		t.Errorf("provenance records %d sources, want 2: %s", got, provenanceData)
+	}
+
+	// A new ingress reads the ranges and their sources back
+	current, err := newTestIngress(t, k8sClient).GetCurrentIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("GetCurrentIPRanges() error = %v", err)
+	}
+	if got, want := current.GetCIDRs(), ipRanges.GetCIDRs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCurrentIPRanges() = %v, want %v", got, want)
+	}
+	for idx, ipRange := range current.Ranges {
+		if got, want := ipRange.Comment(), ipRanges.Ranges[idx].Comment(); !sameSources(got, want) {
+			t.Errorf("sources of %s = %s, want %s", ipRange.CIDR, got, want)
+		}
+	}
+}
+
+// sameSources reports whether two range comments list the same sources, in any order
+func sameSources(a, b string) bool {
+	sourcesA, sourcesB := strings.Split(a, "; "), strings.Split(b, "; ")
+	sort.Strings(sourcesA)
+	sort.Strings(sourcesB)
+	return reflect.DeepEqual(sourcesA, sourcesB)
+}
+
+func TestUpdateConfigMapWithoutProvenance(t *testing.T) {
+	tests := []struct {
+		name     string
+		client   func(t *testing.T) client.Client
+		ipRanges func(t *testing.T) *model.IPRangeSet
+	}{
+		{
+			name: "rejected by the API server",
+			client: func(t *testing.T) client.Client {
+				return &rejectingClient{Client: newFakeClient(t)}
+			},
+			ipRanges: testRanges,
+		},
+		{
+			name:   "over the size limit",
+			client: newFakeClient,
+			ipRanges: func(t *testing.T) *model.IPRangeSet {
+				// Every range has its own first-seen time, so none can be grouped
+				ipRanges := model.NewIPRangeSet()
+				for n := 0; n < 4096; n++ {
+					source := model.Provenance{
+						ProviderName: "github-ranges",
+						ProviderType: "github",
+						Category:     "actions",
+						FirstSeen:    time.Unix(int64(n), 0),
+					}
+					if err := ipRanges.AddWithSource(fmt.Sprintf("10.%d.%d.0/24", n/256, n%256), nil, source); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return ipRanges
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k8sClient := tt.client(t)
+			ipRanges := tt.ipRanges(t)
+			i := newTestIngress(t, k8sClient)
+
+			// Once when creating the ConfigMap and once when updating it
+			for attempt := 0; attempt < 2; attempt++ {
+				if err := i.updateConfigMap(context.Background(), ipRanges); err != nil {
+					t.Fatalf("updateConfigMap() error = %v", err)
+				}
+
+				configMap := &corev1.ConfigMap{}
+				if err := k8sClient.Get(context.Background(), client.ObjectKey{Namespace: "istio-system", Name: "office-ip-ranges"}, configMap); err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				if _, ok := configMap.Data[provenanceKey]; ok {
+					t.Errorf("ConfigMap has provenance, want it stored without")
+				}
+				if configMap.Data["ip_ranges"] == "" {
+					t.Errorf("ConfigMap has no ip_ranges")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"net/netip"
+	"sort"
+)
+
+// prefixEntry is a parsed range used while aggregating
+type prefixEntry struct {
+	prefix  netip.Prefix
+	labels  []string
+	sources []Provenance
+}
+
+// Aggregate returns a new IPRangeSet with the minimal set of prefixes covering the same addresses.
+// Duplicates are removed, prefixes contained in larger ones are dropped, and adjacent siblings
+// are merged into their supernet. Merged ranges carry the union of the labels and sources of their parts.
+func (s *IPRangeSet) Aggregate() *IPRangeSet {
+	var ipv4, ipv6 []prefixEntry
+	for _, ipRange := range s.Ranges {
+		prefix, err := ipRange.prefix()
+		if err != nil {
+			// Ranges are validated on Add, so this only happens for sets built by hand
+			continue
+		}
+
+		entry := prefixEntry{prefix: prefix, labels: ipRange.Labels, sources: ipRange.Sources}
+		if prefix.Addr().Is4() {
+			ipv4 = append(ipv4, entry)
+		} else {
+			ipv6 = append(ipv6, entry)
+		}
+	}
+
+	result := NewIPRangeSet()
+	for _, entry := range append(aggregatePrefixes(ipv4), aggregatePrefixes(ipv6)...) {
+		result.Ranges = append(result.Ranges, IPRange{
+			CIDR:    entry.prefix.String(),
+			Prefix:  entry.prefix,
+			Labels:  entry.labels,
+			Sources: entry.sources,
+		})
+	}
+	return result
+}
+
+// aggregatePrefixes aggregates prefixes of a single address family
+func aggregatePrefixes(entries []prefixEntry) []prefixEntry {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// Order by address, then shortest prefix first so containers precede what they contain
+	sort.Slice(entries, func(i, j int) bool {
+		if c := entries[i].prefix.Addr().Compare(entries[j].prefix.Addr()); c != 0 {
+			return c < 0
+		}
+		return entries[i].prefix.Bits() < entries[j].prefix.Bits()
+	})
+
+	stack := make([]prefixEntry, 0, len(entries))
+	for _, entry := range entries {
+		// Drop duplicates and prefixes contained in the previous one
+		if n := len(stack); n > 0 && stack[n-1].prefix.Bits() <= entry.prefix.Bits() && stack[n-1].prefix.Contains(entry.prefix.Addr()) {
+			stack[n-1].labels = unionLabels(stack[n-1].labels, entry.labels)
+			stack[n-1].sources = unionSources(stack[n-1].sources, entry.sources)
+			continue
+		}
+
+		stack = append(stack, prefixEntry{
+			prefix:  entry.prefix,
+			labels:  unionLabels(nil, entry.labels),
+			sources: unionSources(nil, entry.sources),
+		})
+
+		// Merge sibling pairs into their supernet for as long as possible
+		for len(stack) >= 2 {
+			lower, upper := stack[len(stack)-2], stack[len(stack)-1]
+			parent, ok := siblingParent(lower.prefix, upper.prefix)
+			if !ok {
+				break
+			}
+			stack = stack[:len(stack)-2]
+			stack = append(stack, prefixEntry{
+				prefix:  parent,
+				labels:  unionLabels(lower.labels, upper.labels),
+				sources: unionSources(lower.sources, upper.sources),
+			})
+		}
+	}
+
+	return stack
+}
+
+// siblingParent returns the supernet of two prefixes if they are the two halves of it
+func siblingParent(lower, upper netip.Prefix) (netip.Prefix, bool) {
+	bits := lower.Bits()
+	if bits == 0 || bits != upper.Bits() || lower.Addr() == upper.Addr() {
+		return netip.Prefix{}, false
+	}
+
+	parent, err := lower.Addr().Prefix(bits - 1)
+	if err != nil || parent.Addr() != lower.Addr() || !parent.Contains(upper.Addr()) {
+		return netip.Prefix{}, false
+	}
+	return parent, true
+}
+
+// unionLabels appends the labels of other to labels, skipping duplicates and keeping first-seen order
+func unionLabels(labels []string, other []string) []string {
+	result := make([]string, 0, len(labels)+len(other))
+	seen := make(map[string]bool, len(labels)+len(other))
+	for _, label := range append(append([]string{}, labels...), other...) {
+		if !seen[label] {
+			seen[label] = true
+			result = append(result, label)
+		}
+	}
+	return result
+}
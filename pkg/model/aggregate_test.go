@@ -0,0 +1,102 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestSet builds a set from CIDRs, failing the test on invalid input
+func newTestSet(t *testing.T, cidrs ...string) *IPRangeSet {
+	t.Helper()
+	s := NewIPRangeSet()
+	for _, cidr := range cidrs {
+		if err := s.Add(cidr, nil); err != nil {
+			t.Fatalf("Add(%q) error = %v", cidr, err)
+		}
+	}
+	return s
+}
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidrs []string
+		want  []string
+	}{
+		{
+			name: "empty",
+			want: []string{},
+		},
+		{
+			name:  "siblings merge into their parent",
+			cidrs: []string{"192.0.2.0/25", "192.0.2.128/25"},
+			want:  []string{"192.0.2.0/24"},
+		},
+		{
+			name:  "merges cascade",
+			cidrs: []string{"10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/25", "10.0.1.0/24"},
+			want:  []string{"10.0.0.0/23"},
+		},
+		{
+			name:  "contained prefixes are dropped",
+			cidrs: []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.3"},
+			want:  []string{"10.0.0.0/8"},
+		},
+		{
+			name:  "adjacent prefixes that are not siblings stay apart",
+			cidrs: []string{"10.0.1.0/24", "10.0.2.0/24"},
+			want:  []string{"10.0.1.0/24", "10.0.2.0/24"},
+		},
+		{
+			name:  "output is sorted",
+			cidrs: []string{"10.0.3.0/24", "10.0.0.0/24", "172.16.0.0/12"},
+			want:  []string{"10.0.0.0/24", "10.0.3.0/24", "172.16.0.0/12"},
+		},
+		{
+			name:  "families are aggregated separately, IPv4 first",
+			cidrs: []string{"2001:db8:0:1::/64", "0.0.0.0/1", "2001:db8::/64", "128.0.0.0/1"},
+			want:  []string{"0.0.0.0/0", "2001:db8::/63"},
+		},
+		{
+			name:  "prefixes of different families never merge",
+			cidrs: []string{"::/1", "0.0.0.0/1"},
+			want:  []string{"0.0.0.0/1", "::/1"},
+		},
+		{
+			name:  "host routes",
+			cidrs: []string{"192.0.2.0", "192.0.2.1", "192.0.2.2", "2001:db8::1"},
+			want:  []string{"192.0.2.0/31", "192.0.2.2/32", "2001:db8::1/128"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newTestSet(t, tt.cidrs...).Aggregate().GetCIDRs()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Aggregate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateMergesLabelsAndSources(t *testing.T) {
+	s := NewIPRangeSet()
+	if err := s.AddWithSource("192.0.2.0/25", []string{"a"}, Provenance{ProviderName: "one"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddWithSource("192.0.2.128/25", []string{"b", "a"}, Provenance{ProviderName: "two"}); err != nil {
+		t.Fatal(err)
+	}
+
+	aggregated := s.Aggregate()
+	if aggregated.Count() != 1 {
+		t.Fatalf("Aggregate() = %v, want a single range", aggregated.GetCIDRs())
+	}
+	got := aggregated.Ranges[0]
+	if !reflect.DeepEqual(got.Labels, []string{"a", "b"}) {
+		t.Errorf("labels = %v, want [a b]", got.Labels)
+	}
+	if len(got.Sources) != 2 || got.Sources[0].ProviderName != "one" || got.Sources[1].ProviderName != "two" {
+		t.Errorf("sources = %+v, want one and two", got.Sources)
+	}
+}
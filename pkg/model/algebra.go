@@ -0,0 +1,114 @@
+package model
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Intersect returns the parts of this set's ranges that are also covered by other.
+// Ranges keep their own labels and sources; a range partly covered by other is split into the covered prefixes.
+func (s *IPRangeSet) Intersect(other *IPRangeSet) *IPRangeSet {
+	trie := newPrefixTrie(other)
+	return s.transform(trie.intersect)
+}
+
+// Subtract returns the parts of this set's ranges that are not covered by other.
+// Ranges keep their own labels and sources; a range partly covered by other is split into the remaining prefixes.
+func (s *IPRangeSet) Subtract(other *IPRangeSet) *IPRangeSet {
+	trie := newPrefixTrie(other)
+	return s.transform(trie.subtract)
+}
+
+// Contains reports whether any range in the set contains ip
+func (s *IPRangeSet) Contains(ip netip.Addr) bool {
+	return newPrefixTrie(s).containsAddr(ip.Unmap())
+}
+
+// Overlaps reports whether any range in this set shares an address with a range in other
+func (s *IPRangeSet) Overlaps(other *IPRangeSet) bool {
+	trie := newPrefixTrie(other)
+	for _, ipRange := range s.Ranges {
+		if prefix, err := ipRange.prefix(); err == nil && trie.overlaps(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// transform replaces every range with the prefixes returned by fn, keeping its labels and sources
+func (s *IPRangeSet) transform(fn func(netip.Prefix) []netip.Prefix) *IPRangeSet {
+	result := NewIPRangeSet()
+	for _, ipRange := range s.Ranges {
+		prefix, err := ipRange.prefix()
+		if err != nil {
+			continue
+		}
+		for _, p := range fn(prefix) {
+			result.Ranges = append(result.Ranges, IPRange{
+				CIDR:    p.String(),
+				Prefix:  p,
+				Labels:  ipRange.Labels,
+				Sources: ipRange.Sources,
+			})
+		}
+	}
+	return result
+}
+
+// ParsePrefix parses a CIDR or a bare IP address, which yields a /32 or /128 prefix.
+// IPv4-mapped IPv6 prefixes such as ::ffff:192.0.2.0/120 are returned as the IPv4 prefix they cover.
+// Host bits are preserved; call Masked on the result for the canonical form.
+func ParsePrefix(cidr string) (netip.Prefix, error) {
+	if !strings.Contains(cidr, "/") {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if addr.Zone() != "" {
+			return netip.Prefix{}, fmt.Errorf("zoned addresses are not supported")
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(trimPrefixLength(cidr))
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return unmapPrefix(prefix), nil
+}
+
+// trimPrefixLength drops leading zeros from the prefix length, e.g. 10.0.0.0/08 becomes 10.0.0.0/8.
+// net.ParseCIDR accepted them, netip.ParsePrefix does not.
+func trimPrefixLength(cidr string) string {
+	slash := strings.LastIndexByte(cidr, '/')
+	bits := cidr[slash+1:]
+	trimmed := strings.TrimLeft(bits, "0")
+	if trimmed == bits {
+		return cidr
+	}
+	if trimmed == "" {
+		trimmed = "0"
+	}
+	return cidr[:slash+1] + trimmed
+}
+
+// unmapPrefix converts an IPv4-mapped IPv6 prefix to IPv4. A prefix shorter than the ::ffff:0:0/96
+// mapping prefix also covers non-mapped addresses and is kept as IPv6.
+func unmapPrefix(prefix netip.Prefix) netip.Prefix {
+	addr := prefix.Addr()
+	if !addr.Is4In6() || prefix.Bits() < 96 {
+		return prefix
+	}
+	return netip.PrefixFrom(addr.Unmap(), prefix.Bits()-96)
+}
+
+// key returns the canonical CIDR of the range, or CIDR itself if it cannot be parsed
+func (r IPRange) key() string {
+	prefix, err := r.prefix()
+	if err != nil {
+		return r.CIDR
+	}
+	return prefix.String()
+}
@@ -0,0 +1,172 @@
+package model
+
+import (
+	"net/netip"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// sortedCIDRs returns the CIDRs of a set in a stable order for comparison
+func sortedCIDRs(s *IPRangeSet) []string {
+	cidrs := s.GetCIDRs()
+	sort.Strings(cidrs)
+	return cidrs
+}
+
+func TestSubtract(t *testing.T) {
+	tests := []struct {
+		name  string
+		set   []string
+		other []string
+		want  []string
+	}{
+		{
+			name:  "nothing to subtract",
+			set:   []string{"10.0.0.0/24"},
+			other: []string{"192.0.2.0/24"},
+			want:  []string{"10.0.0.0/24"},
+		},
+		{
+			name:  "fully covered",
+			set:   []string{"10.0.0.0/24", "10.0.1.0/24"},
+			other: []string{"10.0.0.0/16"},
+			want:  []string{},
+		},
+		{
+			name:  "splits around a hole",
+			set:   []string{"10.0.0.0/22"},
+			other: []string{"10.0.1.0/24"},
+			want:  []string{"10.0.0.0/24", "10.0.2.0/23"},
+		},
+		{
+			name:  "single address",
+			set:   []string{"192.0.2.0/30"},
+			other: []string{"192.0.2.2"},
+			want:  []string{"192.0.2.0/31", "192.0.2.3/32"},
+		},
+		{
+			name:  "families do not interact",
+			set:   []string{"0.0.0.0/0", "2001:db8::/32"},
+			other: []string{"::/0"},
+			want:  []string{"0.0.0.0/0"},
+		},
+		{
+			name:  "IPv6",
+			set:   []string{"2001:db8::/126"},
+			other: []string{"2001:db8::1", "2001:db8::2/127"},
+			want:  []string{"2001:db8::/128"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sortedCIDRs(newTestSet(t, tt.set...).Subtract(newTestSet(t, tt.other...)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Subtract() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	tests := []struct {
+		name  string
+		set   []string
+		other []string
+		want  []string
+	}{
+		{
+			name:  "disjoint",
+			set:   []string{"10.0.0.0/24"},
+			other: []string{"10.0.1.0/24"},
+			want:  []string{},
+		},
+		{
+			name:  "contained in other",
+			set:   []string{"10.0.0.0/24"},
+			other: []string{"10.0.0.0/8"},
+			want:  []string{"10.0.0.0/24"},
+		},
+		{
+			name:  "keeps the covered parts",
+			set:   []string{"10.0.0.0/22"},
+			other: []string{"10.0.1.0/24", "10.0.3.128/25", "192.0.2.0/24"},
+			want:  []string{"10.0.1.0/24", "10.0.3.128/25"},
+		},
+		{
+			name:  "families do not interact",
+			set:   []string{"0.0.0.0/0"},
+			other: []string{"::/0"},
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sortedCIDRs(newTestSet(t, tt.set...).Intersect(newTestSet(t, tt.other...)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Intersect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubtractKeepsLabels(t *testing.T) {
+	s := NewIPRangeSet()
+	if err := s.Add("10.0.0.0/23", []string{"office"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ipRange := range s.Subtract(newTestSet(t, "10.0.0.0/24")).Ranges {
+		if !reflect.DeepEqual(ipRange.Labels, []string{"office"}) {
+			t.Errorf("labels of %s = %v, want [office]", ipRange.CIDR, ipRange.Labels)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	s := newTestSet(t, "10.0.0.0/8", "192.0.2.1", "2001:db8::/32")
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.255.255.255", true},
+		{"11.0.0.0", false},
+		{"192.0.2.1", true},
+		{"192.0.2.2", false},
+		{"::ffff:10.1.2.3", true},
+		{"2001:db8:ffff::1", true},
+		{"2001:db9::", false},
+	}
+
+	for _, tt := range tests {
+		if got := s.Contains(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Contains(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		name  string
+		set   []string
+		other []string
+		want  bool
+	}{
+		{"supernet", []string{"10.0.0.0/8"}, []string{"10.1.0.0/16"}, true},
+		{"subnet", []string{"10.1.2.0/24"}, []string{"10.0.0.0/8"}, true},
+		{"adjacent", []string{"10.0.0.0/24"}, []string{"10.0.1.0/24"}, false},
+		{"other family", []string{"0.0.0.0/0"}, []string{"2001:db8::/32"}, false},
+		{"empty", []string{"10.0.0.0/8"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newTestSet(t, tt.set...).Overlaps(newTestSet(t, tt.other...)); got != tt.want {
+				t.Errorf("Overlaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package model
+
+import (
+	"container/heap"
+	"fmt"
+	"math/big"
+	"net/netip"
+)
+
+// Compress returns a set of at most maxEntries prefixes covering every address of this set.
+// The set is aggregated first; while it is still too large, the neighbouring ranges whose
+// common supernet admits the fewest extra addresses are replaced by that supernet.
+// The second return value is the exact number of addresses admitted that are not in this set.
+func (s *IPRangeSet) Compress(maxEntries int) (*IPRangeSet, *big.Int, error) {
+	if maxEntries <= 0 {
+		return nil, nil, fmt.Errorf("maxEntries must be positive, got %d", maxEntries)
+	}
+
+	aggregated := s.Aggregate()
+	if aggregated.Count() <= maxEntries {
+		return aggregated, new(big.Int), nil
+	}
+
+	var ipv4, ipv6 []prefixEntry
+	for _, ipRange := range aggregated.Ranges {
+		if ipRange.Prefix.Addr().Is4() {
+			ipv4 = append(ipv4, prefixEntry{prefix: ipRange.Prefix, labels: ipRange.Labels, sources: ipRange.Sources})
+		} else {
+			ipv6 = append(ipv6, prefixEntry{prefix: ipRange.Prefix, labels: ipRange.Labels, sources: ipRange.Sources})
+		}
+	}
+
+	minimum := 0
+	if len(ipv4) > 0 {
+		minimum++
+	}
+	if len(ipv6) > 0 {
+		minimum++
+	}
+	if maxEntries < minimum {
+		return nil, nil, fmt.Errorf("cannot fit both IPv4 and IPv6 ranges into a single entry")
+	}
+
+	c := &compressor{entries: len(ipv4) + len(ipv6), overAdmitted: new(big.Int)}
+	roots := []*mergeNode{c.build(ipv4, 0), c.build(ipv6, 1)}
+
+	for c.entries > maxEntries {
+		c.collapse(heap.Pop(&c.queue).(*mergeNode))
+	}
+
+	result := NewIPRangeSet()
+	for _, root := range roots {
+		root.walkLeaves(func(leaf *mergeNode) {
+			result.Ranges = append(result.Ranges, IPRange{
+				CIDR:    leaf.prefix.String(),
+				Prefix:  leaf.prefix,
+				Labels:  leaf.labels,
+				Sources: leaf.sources,
+			})
+		})
+	}
+	return result, c.overAdmitted, nil
+}
+
+// mergeNode is a node of the tree of candidate merges of one address family. Leaves are the
+// current entries; each inner node is the common supernet of two neighbouring entries, and
+// collapsing it replaces every entry below it with that supernet.
+type mergeNode struct {
+	prefix   netip.Prefix
+	parent   *mergeNode
+	children [2]*mergeNode
+
+	// labels and sources of a leaf
+	labels  []string
+	sources []Provenance
+
+	// leaves is the number of entries below the node, covered the addresses they cover,
+	// and cost the number of addresses collapsing the node would admit
+	leaves  int
+	covered *big.Int
+	cost    *big.Int
+
+	// family and position order merges of equal cost, IPv4 first and then by address
+	family   int
+	position int
+
+	// index is the position of an inner node in the queue, or -1
+	index int
+}
+
+// isLeaf reports whether the node is an entry rather than a candidate merge
+func (n *mergeNode) isLeaf() bool {
+	return n.children[0] == nil
+}
+
+// walkLeaves calls visit for every leaf below the node, in address order
+func (n *mergeNode) walkLeaves(visit func(*mergeNode)) {
+	if n == nil {
+		return
+	}
+	if n.isLeaf() {
+		visit(n)
+		return
+	}
+	n.children[0].walkLeaves(visit)
+	n.children[1].walkLeaves(visit)
+}
+
+// compressor collapses the cheapest candidate merge of either family until the entries fit
+type compressor struct {
+	queue        mergeQueue
+	entries      int
+	overAdmitted *big.Int
+}
+
+// build returns the tree of candidate merges over sorted, disjoint entries of one family and
+// queues its inner nodes. Each inner node sits between two neighbouring entries; as a shorter
+// supernet contains every longer one around it, the tree is the Cartesian tree of their lengths.
+func (c *compressor) build(entries []prefixEntry, family int) *mergeNode {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	leaves := make([]*mergeNode, len(entries))
+	for i, entry := range entries {
+		leaves[i] = &mergeNode{
+			prefix:  entry.prefix,
+			labels:  entry.labels,
+			sources: entry.sources,
+			leaves:  1,
+			covered: addressCount(entry.prefix),
+			index:   -1,
+		}
+	}
+
+	var stack []*mergeNode
+	for i := 0; i+1 < len(leaves); i++ {
+		node := &mergeNode{
+			prefix:   commonPrefix(leaves[i].prefix, leaves[i+1].prefix),
+			family:   family,
+			position: i,
+			index:    -1,
+		}
+		last := leaves[i]
+		for len(stack) > 0 && stack[len(stack)-1].prefix.Bits() > node.prefix.Bits() {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			top.setChild(1, last)
+			last = top
+		}
+		node.setChild(0, last)
+		stack = append(stack, node)
+	}
+
+	last := leaves[len(leaves)-1]
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		top.setChild(1, last)
+		last = top
+	}
+
+	c.measure(last)
+	return last
+}
+
+// setChild attaches child below the node
+func (n *mergeNode) setChild(side int, child *mergeNode) {
+	n.children[side] = child
+	child.parent = n
+}
+
+// measure computes the counts and cost of every inner node below node and queues them
+func (c *compressor) measure(node *mergeNode) {
+	if node.isLeaf() {
+		return
+	}
+	c.measure(node.children[0])
+	c.measure(node.children[1])
+
+	node.leaves = node.children[0].leaves + node.children[1].leaves
+	node.covered = new(big.Int).Add(node.children[0].covered, node.children[1].covered)
+	node.cost = new(big.Int).Sub(addressCount(node.prefix), node.covered)
+	heap.Push(&c.queue, node)
+}
+
+// collapse replaces the entries below node with its prefix and updates the cost of every
+// ancestor. Ancestors left fully covered are collapsed too, as aggregation would merge them.
+func (c *compressor) collapse(node *mergeNode) {
+	if node.index >= 0 {
+		heap.Remove(&c.queue, node.index)
+	}
+
+	removed := node.leaves - 1
+	admitted := node.cost
+
+	var labels []string
+	var sources []Provenance
+	var forget func(n *mergeNode)
+	forget = func(n *mergeNode) {
+		if n.isLeaf() {
+			labels = unionLabels(labels, n.labels)
+			sources = unionSources(sources, n.sources)
+			return
+		}
+		if n != node && n.index >= 0 {
+			heap.Remove(&c.queue, n.index)
+		}
+		forget(n.children[0])
+		forget(n.children[1])
+	}
+	forget(node)
+
+	node.children = [2]*mergeNode{}
+	node.labels = labels
+	node.sources = sources
+	node.leaves = 1
+	node.covered = addressCount(node.prefix)
+	node.cost = new(big.Int)
+
+	c.entries -= removed
+	c.overAdmitted.Add(c.overAdmitted, admitted)
+
+	for ancestor := node.parent; ancestor != nil; ancestor = ancestor.parent {
+		ancestor.leaves -= removed
+		ancestor.covered.Add(ancestor.covered, admitted)
+		ancestor.cost.Sub(ancestor.cost, admitted)
+		heap.Fix(&c.queue, ancestor.index)
+	}
+
+	if parent := node.parent; parent != nil && parent.cost.Sign() == 0 {
+		c.collapse(parent)
+	}
+}
+
+// mergeQueue is a min-heap of candidate merges ordered by cost
+type mergeQueue []*mergeNode
+
+func (q mergeQueue) Len() int { return len(q) }
+
+func (q mergeQueue) Less(i, j int) bool {
+	if c := q[i].cost.Cmp(q[j].cost); c != 0 {
+		return c < 0
+	}
+	if q[i].family != q[j].family {
+		return q[i].family < q[j].family
+	}
+	return q[i].position < q[j].position
+}
+
+func (q mergeQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *mergeQueue) Push(x interface{}) {
+	node := x.(*mergeNode)
+	node.index = len(*q)
+	*q = append(*q, node)
+}
+
+func (q *mergeQueue) Pop() interface{} {
+	old := *q
+	node := old[len(old)-1]
+	old[len(old)-1] = nil
+	node.index = -1
+	*q = old[:len(old)-1]
+	return node
+}
+
+// commonPrefix returns the longest prefix containing both a and b
+func commonPrefix(a, b netip.Prefix) netip.Prefix {
+	bits := a.Bits()
+	if b.Bits() < bits {
+		bits = b.Bits()
+	}
+	for ; bits > 0; bits-- {
+		candidate, _ := a.Addr().Prefix(bits)
+		if candidate.Contains(b.Addr()) {
+			return candidate
+		}
+	}
+	candidate, _ := a.Addr().Prefix(0)
+	return candidate
+}
+
+// addressCount returns the number of addresses in prefix
+func addressCount(prefix netip.Prefix) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(prefix.Addr().BitLen()-prefix.Bits()))
+}
@@ -0,0 +1,131 @@
+package model
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	tests := []struct {
+		name         string
+		cidrs        []string
+		maxEntries   int
+		want         []string
+		overAdmitted int64
+		wantErr      bool
+	}{
+		{
+			name:       "already fits after aggregation",
+			cidrs:      []string{"10.0.0.0/25", "10.0.0.128/25", "10.0.2.0/24"},
+			maxEntries: 2,
+			want:       []string{"10.0.0.0/24", "10.0.2.0/24"},
+		},
+		{
+			name:         "merges the cheapest neighbours",
+			cidrs:        []string{"10.0.0.0/24", "10.0.2.0/24", "10.0.128.0/24"},
+			maxEntries:   2,
+			want:         []string{"10.0.0.0/22", "10.0.128.0/24"},
+			overAdmitted: 512,
+		},
+		{
+			name:         "a supernet absorbs every range inside it",
+			cidrs:        []string{"10.0.0.0/24", "10.0.2.0/24", "10.0.4.0/24", "10.0.6.0/24", "10.1.0.0/24"},
+			maxEntries:   2,
+			want:         []string{"10.0.0.0/21", "10.1.0.0/24"},
+			overAdmitted: 1024,
+		},
+		{
+			name:         "merging a supernet next to its sibling aggregates them for free",
+			cidrs:        []string{"10.0.0.0/24", "10.0.1.0/25", "10.0.2.0/23", "10.0.8.0/24"},
+			maxEntries:   2,
+			want:         []string{"10.0.0.0/22", "10.0.8.0/24"},
+			overAdmitted: 128,
+		},
+		{
+			name:         "compares costs across families",
+			cidrs:        []string{"10.0.0.0/24", "10.0.2.0/24", "2001:db8::/127", "2001:db8::2/128"},
+			maxEntries:   3,
+			want:         []string{"10.0.0.0/24", "10.0.2.0/24", "2001:db8::/126"},
+			overAdmitted: 1,
+		},
+		{
+			name:       "each family keeps at least one entry",
+			cidrs:      []string{"10.0.0.0/24", "2001:db8::/64"},
+			maxEntries: 1,
+			wantErr:    true,
+		},
+		{
+			name:       "zero entries",
+			cidrs:      []string{"10.0.0.0/24"},
+			maxEntries: 0,
+			wantErr:    true,
+		},
+		{
+			name:       "negative entries",
+			maxEntries: -1,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, overAdmitted, err := newTestSet(t, tt.cidrs...).Compress(tt.maxEntries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Compress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got.GetCIDRs(), tt.want) {
+				t.Errorf("Compress() = %v, want %v", got.GetCIDRs(), tt.want)
+			}
+			if overAdmitted.Cmp(big.NewInt(tt.overAdmitted)) != 0 {
+				t.Errorf("Compress() admitted %s extra addresses, want %d", overAdmitted, tt.overAdmitted)
+			}
+		})
+	}
+}
+
+func TestCompressCoversEveryAddress(t *testing.T) {
+	s := NewIPRangeSet()
+	for i := 0; i < 2000; i++ {
+		cidr := fmt.Sprintf("%d.%d.%d.0/24", 1+i%200, (i*37)%256, (i*101)%256)
+		if err := s.Add(cidr, []string{fmt.Sprintf("range-%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	compressed, overAdmitted, err := s.Compress(100)
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	if compressed.Count() > 100 {
+		t.Errorf("Compress() returned %d entries, want at most 100", compressed.Count())
+	}
+	if remaining := s.Subtract(compressed); remaining.Count() != 0 {
+		t.Errorf("Compress() dropped %v", remaining.GetCIDRs())
+	}
+
+	// The admitted addresses are exactly those of the result that were not in the input
+	covered := new(big.Int)
+	for _, ipRange := range compressed.Ranges {
+		covered.Add(covered, addressCount(ipRange.Prefix))
+	}
+	original := new(big.Int)
+	for _, ipRange := range s.Aggregate().Ranges {
+		original.Add(original, addressCount(ipRange.Prefix))
+	}
+	if want := new(big.Int).Sub(covered, original); overAdmitted.Cmp(want) != 0 {
+		t.Errorf("Compress() admitted %s extra addresses, want %s", overAdmitted, want)
+	}
+
+	labels := 0
+	for _, ipRange := range compressed.Ranges {
+		labels += len(ipRange.Labels)
+	}
+	if labels != 2000 {
+		t.Errorf("Compress() kept %d labels, want all 2000", labels)
+	}
+}
@@ -0,0 +1,67 @@
+package model
+
+// Address family selectors
+const (
+	FamilyIPv4      = "IPv4"
+	FamilyIPv6      = "IPv6"
+	FamilyDualStack = "DualStack"
+)
+
+// SplitFamilies returns the IPv4 and IPv6 ranges of the set as separate sets
+func (s *IPRangeSet) SplitFamilies() (ipv4, ipv6 *IPRangeSet) {
+	ipv4 = NewIPRangeSet()
+	ipv6 = NewIPRangeSet()
+	for _, ipRange := range s.Ranges {
+		prefix, err := ipRange.prefix()
+		if err != nil {
+			continue
+		}
+		if prefix.Addr().Is4() {
+			ipv4.Ranges = append(ipv4.Ranges, ipRange)
+		} else {
+			ipv6.Ranges = append(ipv6.Ranges, ipRange)
+		}
+	}
+	return ipv4, ipv6
+}
+
+// IPv4 returns a new IPRangeSet with only the IPv4 ranges
+func (s *IPRangeSet) IPv4() *IPRangeSet {
+	ipv4, _ := s.SplitFamilies()
+	return ipv4
+}
+
+// IPv6 returns a new IPRangeSet with only the IPv6 ranges
+func (s *IPRangeSet) IPv6() *IPRangeSet {
+	_, ipv6 := s.SplitFamilies()
+	return ipv6
+}
+
+// FilterFamily returns the ranges of the given address family. DualStack and the
+// empty string select both families and return the set itself.
+func (s *IPRangeSet) FilterFamily(family string) *IPRangeSet {
+	switch family {
+	case FamilyIPv4:
+		return s.IPv4()
+	case FamilyIPv6:
+		return s.IPv6()
+	default:
+		return s
+	}
+}
+
+// CountByFamily returns the number of IPv4 and IPv6 ranges in the set
+func (s *IPRangeSet) CountByFamily() (ipv4, ipv6 int) {
+	for _, ipRange := range s.Ranges {
+		prefix, err := ipRange.prefix()
+		if err != nil {
+			continue
+		}
+		if prefix.Addr().Is4() {
+			ipv4++
+		} else {
+			ipv6++
+		}
+	}
+	return ipv4, ipv6
+}
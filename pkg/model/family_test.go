@@ -0,0 +1,57 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterFamily(t *testing.T) {
+	s := newTestSet(t, "10.0.0.0/8", "2001:db8::/32", "::ffff:192.0.2.0/120", "192.0.2.1", "::1")
+
+	tests := []struct {
+		family string
+		want   []string
+	}{
+		{FamilyIPv4, []string{"10.0.0.0/8", "192.0.2.0/24", "192.0.2.1/32"}},
+		{FamilyIPv6, []string{"2001:db8::/32", "::1/128"}},
+		{FamilyDualStack, s.GetCIDRs()},
+		{"", s.GetCIDRs()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.family, func(t *testing.T) {
+			if got := s.FilterFamily(tt.family).GetCIDRs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterFamily(%q) = %v, want %v", tt.family, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountByFamily(t *testing.T) {
+	s := newTestSet(t, "10.0.0.0/8", "2001:db8::/32", "192.0.2.1")
+	// A range appended by hand is counted by its parsed family
+	s.Ranges = append(s.Ranges, IPRange{CIDR: "2001:db8:1::/48"}, IPRange{CIDR: "not-a-cidr"})
+
+	ipv4, ipv6 := s.CountByFamily()
+	if ipv4 != 2 || ipv6 != 2 {
+		t.Errorf("CountByFamily() = %d, %d, want 2, 2", ipv4, ipv6)
+	}
+}
+
+func TestSplitFamiliesKeepsLabels(t *testing.T) {
+	s := NewIPRangeSet()
+	if err := s.Add("10.0.0.0/8", []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("2001:db8::/32", []string{"b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ipv4, ipv6 := s.SplitFamilies()
+	if ipv4.Count() != 1 || !reflect.DeepEqual(ipv4.Ranges[0].Labels, []string{"a"}) {
+		t.Errorf("IPv4 ranges = %+v, want 10.0.0.0/8 labelled a", ipv4.Ranges)
+	}
+	if ipv6.Count() != 1 || !reflect.DeepEqual(ipv6.Ranges[0].Labels, []string{"b"}) {
+		t.Errorf("IPv6 ranges = %+v, want 2001:db8::/32 labelled b", ipv6.Ranges)
+	}
+}
@@ -2,19 +2,39 @@ package model
 
 import (
 	"fmt"
-	"net"
+	"net/netip"
 	"strings"
 )
 
 // IPRange represents an IP range (CIDR)
 type IPRange struct {
-	CIDR   string   // CIDR notation (e.g., "192.168.1.0/24")
-	Labels []string // Labels/tags associated with this range
+	CIDR    string       // Canonical CIDR notation (e.g., "192.168.1.0/24")
+	Prefix  netip.Prefix // Parsed form of CIDR, with host bits cleared
+	Labels  []string     // Labels/tags associated with this range
+	Sources []Provenance // Where the range came from; several if providers overlap
 }
 
+// HostBitPolicy defines how Add treats CIDRs with bits set after the prefix length
+type HostBitPolicy int
+
+const (
+	// HostBitsMask clears host bits, so 192.0.2.5/24 is stored as 192.0.2.0/24
+	HostBitsMask HostBitPolicy = iota
+	// HostBitsReject rejects CIDRs with host bits set
+	HostBitsReject
+)
+
 // IPRangeSet is a set of IP ranges
 type IPRangeSet struct {
 	Ranges []IPRange
+
+	// HostBits is the policy applied by Add to CIDRs with host bits set
+	HostBits HostBitPolicy
+
+	// index maps prefixes to their position in Ranges to deduplicate on Add;
+	// indexed is the length of Ranges the index was built for
+	index   map[netip.Prefix]int
+	indexed int
 }
 
 // NewIPRangeSet creates a new empty set of IP ranges
@@ -24,24 +44,86 @@ func NewIPRangeSet() *IPRangeSet {
 	}
 }
 
-// Add adds an IP range to the set
+// Add adds an IP range to the set. The CIDR is stored in canonical form; a bare IP address
+// is added as a /32 or /128. Adding a range already in the set merges the labels instead.
 func (s *IPRangeSet) Add(cidr string, labels []string) error {
-	// Validate CIDR format
-	_, _, err := net.ParseCIDR(cidr)
+	return s.add(cidr, labels, nil)
+}
+
+// add validates and canonicalizes cidr and adds it with the given labels and sources
+func (s *IPRangeSet) add(cidr string, labels []string, sources []Provenance) error {
+	prefix, err := ParsePrefix(cidr)
 	if err != nil {
 		return fmt.Errorf("invalid CIDR format '%s': %w", cidr, err)
 	}
 
-	s.Ranges = append(s.Ranges, IPRange{
-		CIDR:   cidr,
-		Labels: labels,
-	})
+	if masked := prefix.Masked(); masked != prefix {
+		if s.HostBits == HostBitsReject {
+			return fmt.Errorf("CIDR '%s' has host bits set, expected %s", cidr, masked)
+		}
+		prefix = masked
+	}
+
+	s.addPrefix(prefix, labels, sources)
 	return nil
 }
 
 // AddIPRange adds an IPRange object to the set
 func (s *IPRangeSet) AddIPRange(ipRange IPRange) error {
-	return s.Add(ipRange.CIDR, ipRange.Labels)
+	if ipRange.Prefix.IsValid() {
+		s.addPrefix(ipRange.Prefix.Masked(), ipRange.Labels, ipRange.Sources)
+		return nil
+	}
+	return s.add(ipRange.CIDR, ipRange.Labels, ipRange.Sources)
+}
+
+// addPrefix appends a canonical prefix, or merges the labels and sources into the existing range for it
+func (s *IPRangeSet) addPrefix(prefix netip.Prefix, labels []string, sources []Provenance) {
+	if i, ok := s.lookup(prefix); ok {
+		s.Ranges[i].Labels = unionLabels(s.Ranges[i].Labels, labels)
+		s.Ranges[i].Sources = unionSources(s.Ranges[i].Sources, sources)
+		return
+	}
+
+	s.Ranges = append(s.Ranges, IPRange{
+		CIDR:    prefix.String(),
+		Prefix:  prefix,
+		Labels:  labels,
+		Sources: sources,
+	})
+	s.index[prefix] = len(s.Ranges) - 1
+	s.indexed = len(s.Ranges)
+}
+
+// lookup returns the position of prefix in Ranges. The index is rebuilt when Ranges has been
+// modified directly, so sets built by appending to Ranges still deduplicate correctly.
+func (s *IPRangeSet) lookup(prefix netip.Prefix) (int, bool) {
+	if s.index == nil || s.indexed != len(s.Ranges) {
+		s.index = make(map[netip.Prefix]int, len(s.Ranges))
+		for i, ipRange := range s.Ranges {
+			if p, err := ipRange.prefix(); err == nil {
+				if _, exists := s.index[p]; !exists {
+					s.index[p] = i
+				}
+			}
+		}
+		s.indexed = len(s.Ranges)
+	}
+
+	i, ok := s.index[prefix]
+	return i, ok
+}
+
+// prefix returns the canonical prefix of the range, parsing CIDR for ranges built by hand
+func (r IPRange) prefix() (netip.Prefix, error) {
+	if r.Prefix.IsValid() {
+		return r.Prefix.Masked(), nil
+	}
+	prefix, err := ParsePrefix(r.CIDR)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
 }
 
 // Filter returns a new IPRangeSet with only ranges that have the specified labels
@@ -84,11 +166,15 @@ func (s *IPRangeSet) Filter(includeLabels []string, excludeLabels []string) *IPR
 	return result
 }
 
-// Merge combines this IPRangeSet with another, returning a new set
+// Merge combines this IPRangeSet with another, returning a new set.
+// Ranges present in both sets appear once with the union of their labels and sources.
 func (s *IPRangeSet) Merge(other *IPRangeSet) *IPRangeSet {
 	result := NewIPRangeSet()
-	result.Ranges = append(result.Ranges, s.Ranges...)
-	result.Ranges = append(result.Ranges, other.Ranges...)
+	for _, ipRange := range append(append([]IPRange{}, s.Ranges...), other.Ranges...) {
+		if prefix, err := ipRange.prefix(); err == nil {
+			result.addPrefix(prefix, ipRange.Labels, ipRange.Sources)
+		}
+	}
 	return result
 }
 
@@ -97,15 +183,16 @@ func (s *IPRangeSet) Diff(other *IPRangeSet) (added, removed *IPRangeSet) {
 	added = NewIPRangeSet()
 	removed = NewIPRangeSet()
 
-	// Find ranges in other that are not in this set (added)
+	// Find ranges in other that are not in this set (added).
+	// Ranges are keyed by their canonical CIDR so equivalent spellings compare equal.
 	otherMap := make(map[string]IPRange)
 	for _, ipRange := range other.Ranges {
-		otherMap[ipRange.CIDR] = ipRange
+		otherMap[ipRange.key()] = ipRange
 	}
 
 	thisMap := make(map[string]IPRange)
 	for _, ipRange := range s.Ranges {
-		thisMap[ipRange.CIDR] = ipRange
+		thisMap[ipRange.key()] = ipRange
 	}
 
 	for cidr, ipRange := range otherMap {
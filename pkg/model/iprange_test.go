@@ -0,0 +1,135 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		want    string
+		wantErr bool
+	}{
+		{cidr: "192.0.2.0/24", want: "192.0.2.0/24"},
+		{cidr: "192.0.2.5/24", want: "192.0.2.5/24"},
+		{cidr: "192.0.2.1", want: "192.0.2.1/32"},
+		{cidr: "2001:db8::1", want: "2001:db8::1/128"},
+		{cidr: "2001:0db8:0000::/32", want: "2001:db8::/32"},
+		{cidr: "::ffff:192.0.2.1", want: "192.0.2.1/32"},
+		{cidr: "::ffff:192.0.2.0/120", want: "192.0.2.0/24"},
+		{cidr: "::ffff:0:0/96", want: "0.0.0.0/0"},
+		{cidr: "::ffff:0:0/95", want: "::ffff:0.0.0.0/95"},
+		{cidr: "10.0.0.0/08", want: "10.0.0.0/8"},
+		{cidr: "0.0.0.0/00", want: "0.0.0.0/0"},
+		{cidr: "2001:db8::/032", want: "2001:db8::/32"},
+		{cidr: "10.0.0.0/", wantErr: true},
+		{cidr: "10.0.0.0/0x8", wantErr: true},
+		{cidr: "fe80::1%eth0", wantErr: true},
+		{cidr: "192.0.2.0/33", wantErr: true},
+		{cidr: "192.0.2/24", wantErr: true},
+		{cidr: "example.com", wantErr: true},
+		{cidr: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			got, err := ParsePrefix(tt.cidr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePrefix() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("ParsePrefix() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddCanonicalizes(t *testing.T) {
+	s := NewIPRangeSet()
+	for _, add := range []struct {
+		cidr   string
+		labels []string
+	}{
+		{"192.0.2.5/24", []string{"a"}},
+		{"192.0.2.0/24", []string{"b"}},
+		{"::ffff:192.0.2.0/120", []string{"a", "c"}},
+		{"2001:0DB8::/32", []string{"v6"}},
+		{"2001:db8::/32", nil},
+		{"198.51.100.7", nil},
+		{"10.0.0.0/08", nil},
+		{"10.0.0.0/8", nil},
+	} {
+		if err := s.Add(add.cidr, add.labels); err != nil {
+			t.Fatalf("Add(%q) error = %v", add.cidr, err)
+		}
+	}
+
+	want := []IPRange{
+		{CIDR: "192.0.2.0/24", Labels: []string{"a", "b", "c"}},
+		{CIDR: "2001:db8::/32", Labels: []string{"v6"}},
+		{CIDR: "198.51.100.7/32"},
+		{CIDR: "10.0.0.0/8"},
+	}
+	if s.Count() != len(want) {
+		t.Fatalf("set = %v, want %d ranges", s.GetCIDRs(), len(want))
+	}
+	for i, ipRange := range s.Ranges {
+		if ipRange.CIDR != want[i].CIDR || ipRange.Prefix.String() != want[i].CIDR {
+			t.Errorf("range %d = %s (%s), want %s", i, ipRange.CIDR, ipRange.Prefix, want[i].CIDR)
+		}
+		if len(ipRange.Labels) != 0 || len(want[i].Labels) != 0 {
+			if !reflect.DeepEqual(ipRange.Labels, want[i].Labels) {
+				t.Errorf("labels of %s = %v, want %v", ipRange.CIDR, ipRange.Labels, want[i].Labels)
+			}
+		}
+	}
+}
+
+func TestAddHostBitPolicy(t *testing.T) {
+	s := NewIPRangeSet()
+	s.HostBits = HostBitsReject
+
+	if err := s.Add("192.0.2.5/24", nil); err == nil {
+		t.Error("Add() with host bits set error = nil, want an error")
+	}
+	if err := s.Add("192.0.2.0/24", nil); err != nil {
+		t.Errorf("Add() error = %v", err)
+	}
+	if err := s.Add("192.0.2.5", nil); err != nil {
+		t.Errorf("Add() of a bare address error = %v", err)
+	}
+	if got, want := s.GetCIDRs(), []string{"192.0.2.0/24", "192.0.2.5/32"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("set = %v, want %v", got, want)
+	}
+}
+
+func TestAddDeduplicatesRangesAppendedByHand(t *testing.T) {
+	s := NewIPRangeSet()
+	s.Ranges = append(s.Ranges, IPRange{CIDR: "10.0.0.0/8", Labels: []string{"a"}})
+
+	if err := s.Add("10.0.0.0/8", []string{"b"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Count() != 1 || !reflect.DeepEqual(s.Ranges[0].Labels, []string{"a", "b"}) {
+		t.Errorf("set = %+v, want a single range labelled a and b", s.Ranges)
+	}
+}
+
+func TestDiffUsesCanonicalForm(t *testing.T) {
+	current := NewIPRangeSet()
+	current.Ranges = append(current.Ranges,
+		IPRange{CIDR: "192.0.2.0/24"},
+		IPRange{CIDR: "2001:db8:0:0::/64"},
+		IPRange{CIDR: "198.51.100.0/24"},
+	)
+	desired := newTestSet(t, "::ffff:192.0.2.0/120", "2001:db8::/64", "203.0.113.0/24")
+
+	added, removed := current.Diff(desired)
+	if got := added.GetCIDRs(); !reflect.DeepEqual(got, []string{"203.0.113.0/24"}) {
+		t.Errorf("added = %v, want [203.0.113.0/24]", got)
+	}
+	if got := removed.GetCIDRs(); !reflect.DeepEqual(got, []string{"198.51.100.0/24"}) {
+		t.Errorf("removed = %v, want [198.51.100.0/24]", got)
+	}
+}
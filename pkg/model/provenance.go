@@ -0,0 +1,120 @@
+package model
+
+import (
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Provenance records where a range came from
+type Provenance struct {
+	ProviderName string    `json:"providerName,omitempty"` // Name of the ProviderConfig
+	ProviderType string    `json:"providerType,omitempty"` // Provider type (e.g., "github", "aws")
+	Category     string    `json:"category,omitempty"`     // Service or category within the provider (e.g., "actions", "EC2")
+	Region       string    `json:"region,omitempty"`       // Region or scope, if the provider publishes one
+	FirstSeen    time.Time `json:"firstSeen,omitempty"`    // When the provider first returned the range
+}
+
+// String returns a compact description of the provenance, e.g. for comments on ingress entries
+func (p Provenance) String() string {
+	var parts []string
+	if p.ProviderName != "" {
+		parts = append(parts, "provider="+p.ProviderName)
+	}
+	if p.ProviderType != "" && p.ProviderType != p.ProviderName {
+		parts = append(parts, "type="+p.ProviderType)
+	}
+	if p.Category != "" {
+		parts = append(parts, "category="+p.Category)
+	}
+	if p.Region != "" {
+		parts = append(parts, "region="+p.Region)
+	}
+	if !p.FirstSeen.IsZero() {
+		parts = append(parts, "firstSeen="+p.FirstSeen.UTC().Format(time.RFC3339))
+	}
+	return strings.Join(parts, " ")
+}
+
+// sameSource reports whether two provenance records describe the same source, ignoring when it was seen
+func (p Provenance) sameSource(other Provenance) bool {
+	return p.ProviderName == other.ProviderName &&
+		p.ProviderType == other.ProviderType &&
+		p.Category == other.Category &&
+		p.Region == other.Region
+}
+
+// Comment returns a description of every source of the range, or its labels if it has none
+func (r IPRange) Comment() string {
+	if len(r.Sources) == 0 {
+		return strings.Join(r.Labels, " ")
+	}
+	descriptions := make([]string, len(r.Sources))
+	for i, source := range r.Sources {
+		descriptions[i] = source.String()
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+// AddWithSource adds an IP range to the set and records where it came from
+func (s *IPRangeSet) AddWithSource(cidr string, labels []string, source Provenance) error {
+	return s.add(cidr, labels, []Provenance{source})
+}
+
+// Stamp returns a copy of the set attributed to the given provider. Category and region
+// reported by the provider are kept; firstSeen supplies the first-seen time of each prefix.
+func (s *IPRangeSet) Stamp(providerName, providerType string, firstSeen func(netip.Prefix) time.Time) *IPRangeSet {
+	result := NewIPRangeSet()
+	for _, ipRange := range s.Ranges {
+		prefix, err := ipRange.prefix()
+		if err != nil {
+			continue
+		}
+
+		sources := ipRange.Sources
+		if len(sources) == 0 {
+			sources = []Provenance{{}}
+		}
+
+		stamped := make([]Provenance, len(sources))
+		for i, source := range sources {
+			source.ProviderName = providerName
+			source.ProviderType = providerType
+			source.FirstSeen = firstSeen(prefix)
+			stamped[i] = source
+		}
+
+		result.Ranges = append(result.Ranges, IPRange{
+			CIDR:    prefix.String(),
+			Prefix:  prefix,
+			Labels:  ipRange.Labels,
+			Sources: stamped,
+		})
+	}
+	return result
+}
+
+// unionSources appends the sources of other to sources, skipping duplicates and keeping the earliest first-seen time
+func unionSources(sources []Provenance, other []Provenance) []Provenance {
+	if len(other) == 0 {
+		return sources
+	}
+
+	result := append(make([]Provenance, 0, len(sources)+len(other)), sources...)
+	for _, source := range other {
+		merged := false
+		for i := range result {
+			if result[i].sameSource(source) {
+				if !source.FirstSeen.IsZero() && (result[i].FirstSeen.IsZero() || source.FirstSeen.Before(result[i].FirstSeen)) {
+					result[i].FirstSeen = source.FirstSeen
+				}
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			result = append(result, source)
+		}
+	}
+	return result
+}
@@ -0,0 +1,246 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Selector matches ranges by their structured labels, using Kubernetes-style requirements
+// joined by commas with AND semantics:
+//
+//	key             the key is present
+//	!key            the key is absent
+//	key=value       some value of the key matches (also key==value)
+//	key!=value      no value of the key matches
+//	key in (a,b)    some value of the key matches one of the values
+//	key notin (a,b) no value of the key matches any of the values
+//	key=~regex      some value of the key matches the regular expression
+//	key!~regex      no value of the key matches the regular expression
+//
+// Values may be glob patterns ("eu-*"); regular expressions must match the whole value.
+//
+// The labels of a range are taken from each of its sources (provider, type, category with
+// its alias service, and region) together with its flat labels: "key=value" labels are split
+// and any other label is a value of the key "label". A range matches if any of its sources does.
+type Selector struct {
+	requirements []requirement
+}
+
+// requirement is a single term of a selector
+type requirement struct {
+	key      string
+	operator string
+	patterns []string
+	regex    *regexp.Regexp
+}
+
+var (
+	selectorKeyPattern  = `[A-Za-z0-9_./-]+`
+	setRequirementRE    = regexp.MustCompile(`^(` + selectorKeyPattern + `)\s+(in|notin)\s*\((.*)\)$`)
+	valueRequirementRE  = regexp.MustCompile(`^(` + selectorKeyPattern + `)\s*(==|=~|!=|!~|=)\s*(.*)$`)
+	existsRequirementRE = regexp.MustCompile(`^(!?)\s*(` + selectorKeyPattern + `)$`)
+)
+
+// ParseSelector parses a selector expression
+func ParseSelector(expression string) (*Selector, error) {
+	selector := &Selector{}
+	for _, term := range splitSelectorTerms(expression) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf("empty requirement in selector '%s'", expression)
+		}
+
+		req, err := parseRequirement(term)
+		if err != nil {
+			return nil, fmt.Errorf("invalid requirement '%s': %w", term, err)
+		}
+		selector.requirements = append(selector.requirements, req)
+	}
+	return selector, nil
+}
+
+// parseRequirement parses a single selector term
+func parseRequirement(term string) (requirement, error) {
+	if match := setRequirementRE.FindStringSubmatch(term); match != nil {
+		req := requirement{key: match[1], operator: match[2]}
+		for _, value := range strings.Split(match[3], ",") {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				return requirement{}, fmt.Errorf("empty value in set")
+			}
+			req.patterns = append(req.patterns, value)
+		}
+		return req, validateGlobs(req.patterns)
+	}
+
+	if match := valueRequirementRE.FindStringSubmatch(term); match != nil {
+		req := requirement{key: match[1], operator: match[2]}
+		value := strings.TrimSpace(match[3])
+		switch req.operator {
+		case "=~", "!~":
+			regex, err := regexp.Compile(`^(?:` + value + `)$`)
+			if err != nil {
+				return requirement{}, err
+			}
+			req.regex = regex
+		case "==":
+			req.operator = "="
+			fallthrough
+		default:
+			req.patterns = []string{value}
+		}
+		return req, validateGlobs(req.patterns)
+	}
+
+	if match := existsRequirementRE.FindStringSubmatch(term); match != nil {
+		if match[1] == "!" {
+			return requirement{key: match[2], operator: "!"}, nil
+		}
+		return requirement{key: match[2], operator: "exists"}, nil
+	}
+
+	return requirement{}, fmt.Errorf("unrecognized syntax")
+}
+
+// validateGlobs checks that every pattern is a valid glob
+func validateGlobs(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// splitSelectorTerms splits an expression on the commas that are not inside parentheses or brackets
+func splitSelectorTerms(expression string) []string {
+	var terms []string
+	depth := 0
+	start := 0
+	for i, char := range expression {
+		switch char {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, expression[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, expression[start:])
+}
+
+// Matches reports whether the range satisfies every requirement of the selector
+func (sel *Selector) Matches(ipRange IPRange) bool {
+	for _, labels := range rangeLabelSets(ipRange) {
+		if sel.matchesLabels(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesLabels reports whether a single label set satisfies every requirement
+func (sel *Selector) matchesLabels(labels map[string][]string) bool {
+	for _, req := range sel.requirements {
+		if !req.matches(labels[req.key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches evaluates the requirement against the values of its key
+func (req requirement) matches(values []string) bool {
+	switch req.operator {
+	case "exists":
+		return len(values) > 0
+	case "!":
+		return len(values) == 0
+	case "=", "in":
+		return req.anyMatch(values)
+	case "!=", "notin":
+		return !req.anyMatch(values)
+	case "=~":
+		return req.anyRegexMatch(values)
+	case "!~":
+		return !req.anyRegexMatch(values)
+	}
+	return false
+}
+
+// anyMatch reports whether any value matches any of the requirement's patterns
+func (req requirement) anyMatch(values []string) bool {
+	for _, value := range values {
+		for _, pattern := range req.patterns {
+			if matched, _ := path.Match(pattern, value); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// anyRegexMatch reports whether any value matches the requirement's regular expression
+func (req requirement) anyRegexMatch(values []string) bool {
+	for _, value := range values {
+		if req.regex.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeLabelSets returns the structured labels of a range, one set per source
+func rangeLabelSets(ipRange IPRange) []map[string][]string {
+	flat := make(map[string][]string)
+	for _, label := range ipRange.Labels {
+		if key, value, ok := strings.Cut(label, "="); ok {
+			flat[key] = append(flat[key], value)
+		} else {
+			flat["label"] = append(flat["label"], label)
+		}
+	}
+
+	if len(ipRange.Sources) == 0 {
+		return []map[string][]string{flat}
+	}
+
+	sets := make([]map[string][]string, 0, len(ipRange.Sources))
+	for _, source := range ipRange.Sources {
+		labels := make(map[string][]string, len(flat)+5)
+		for key, values := range flat {
+			labels[key] = values
+		}
+		addLabel(labels, "provider", source.ProviderName)
+		addLabel(labels, "type", source.ProviderType)
+		addLabel(labels, "category", source.Category)
+		addLabel(labels, "service", source.Category)
+		addLabel(labels, "region", source.Region)
+		sets = append(sets, labels)
+	}
+	return sets
+}
+
+// addLabel appends a non-empty value to a label
+func addLabel(labels map[string][]string, key, value string) {
+	if value != "" {
+		labels[key] = append(append([]string{}, labels[key]...), value)
+	}
+}
+
+// Select returns a new IPRangeSet with only the ranges matching the selector
+func (s *IPRangeSet) Select(sel *Selector) *IPRangeSet {
+	result := NewIPRangeSet()
+	for _, ipRange := range s.Ranges {
+		if sel.Matches(ipRange) {
+			result.Ranges = append(result.Ranges, ipRange)
+		}
+	}
+	return result
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantErr    bool
+	}{
+		{expression: "region=eu-*"},
+		{expression: "region==us-east-1, service!=EC2"},
+		{expression: "region in (us-east-1, eu-west-1), !label"},
+		{expression: "service notin (a,b),category"},
+		{expression: "region=~eu-(west|central)-[0-9]+"},
+		{expression: "region!~us-.*"},
+		{expression: "", wantErr: true},
+		{expression: "region=eu,", wantErr: true},
+		{expression: "region in (a,,b)", wantErr: true},
+		{expression: "region=~(", wantErr: true},
+		{expression: "region=[", wantErr: true},
+		{expression: "region >= 3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := ParseSelector(tt.expression)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseSelector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	ec2 := IPRange{
+		CIDR:   "3.5.140.0/22",
+		Labels: []string{"AMAZON", "network-border-group=ap-northeast-2"},
+		Sources: []Provenance{
+			{ProviderName: "aws-ranges", ProviderType: "aws", Category: "EC2", Region: "ap-northeast-2"},
+		},
+	}
+	shared := IPRange{
+		CIDR: "52.94.0.0/22",
+		Sources: []Provenance{
+			{ProviderName: "aws-ranges", ProviderType: "aws", Category: "EC2", Region: "us-east-1"},
+			{ProviderName: "aws-ranges", ProviderType: "aws", Category: "S3", Region: "eu-west-1"},
+		},
+	}
+	plain := IPRange{CIDR: "192.0.2.0/24", Labels: []string{"office", "site=tlv"}}
+
+	tests := []struct {
+		expression string
+		ipRange    IPRange
+		want       bool
+	}{
+		{"provider=aws-ranges", ec2, true},
+		{"type=aws,service=EC2", ec2, true},
+		{"category=ec2", ec2, false},
+		{"region=ap-*", ec2, true},
+		{"region in (us-east-1, eu-west-1)", ec2, false},
+		{"region notin (us-east-1, eu-west-1)", ec2, true},
+		{"region=~ap-(north|south)east-[0-9]", ec2, true},
+		{"region=~ap", ec2, false},
+		{"region!~ap-.*", ec2, false},
+		{"network-border-group=ap-northeast-2", ec2, true},
+		{"label=AMAZON", ec2, true},
+		{"!label", ec2, false},
+
+		// A range matches if a single source satisfies every requirement
+		{"service=S3,region=eu-west-1", shared, true},
+		{"service=S3,region=us-east-1", shared, false},
+		{"service!=S3", shared, true},
+
+		// Ranges without sources only have their flat labels
+		{"label=office,site=tlv", plain, true},
+		{"provider", plain, false},
+		{"!provider", plain, true},
+		{"site!=tlv", plain, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression+" "+tt.ipRange.CIDR, func(t *testing.T) {
+			selector, err := ParseSelector(tt.expression)
+			if err != nil {
+				t.Fatalf("ParseSelector() error = %v", err)
+			}
+			if got := selector.Matches(tt.ipRange); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	s := NewIPRangeSet()
+	for _, add := range []struct {
+		cidr   string
+		region string
+	}{
+		{"10.0.0.0/24", "eu-west-1"},
+		{"10.0.1.0/24", "us-east-1"},
+		{"10.0.2.0/24", "eu-central-1"},
+	} {
+		if err := s.AddWithSource(add.cidr, nil, Provenance{ProviderName: "p", Region: add.region}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	selector, err := ParseSelector("region=eu-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Select(selector).GetCIDRs()
+	if len(got) != 2 || got[0] != "10.0.0.0/24" || got[1] != "10.0.2.0/24" {
+		t.Errorf("Select() = %v, want [10.0.0.0/24 10.0.2.0/24]", got)
+	}
+}
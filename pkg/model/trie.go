@@ -0,0 +1,148 @@
+package model
+
+import (
+	"net/netip"
+)
+
+// trieNode is a node of a binary prefix trie. A node at depth n represents the prefix formed
+// by the first n bits of the path leading to it.
+type trieNode struct {
+	children [2]*trieNode
+	terminal bool
+}
+
+// prefixTrie indexes prefixes of both address families for containment and overlap queries
+type prefixTrie struct {
+	ipv4 *trieNode
+	ipv6 *trieNode
+}
+
+// newPrefixTrie builds a trie of every valid range in the set
+func newPrefixTrie(s *IPRangeSet) *prefixTrie {
+	t := &prefixTrie{ipv4: &trieNode{}, ipv6: &trieNode{}}
+	for _, ipRange := range s.Ranges {
+		if prefix, err := ipRange.prefix(); err == nil {
+			t.insert(prefix)
+		}
+	}
+	return t
+}
+
+// root returns the root node for the address family of addr
+func (t *prefixTrie) root(addr netip.Addr) *trieNode {
+	if addr.Is4() {
+		return t.ipv4
+	}
+	return t.ipv6
+}
+
+// insert adds a masked prefix to the trie
+func (t *prefixTrie) insert(prefix netip.Prefix) {
+	node := t.root(prefix.Addr())
+	bytes := prefix.Addr().AsSlice()
+	for i := 0; i < prefix.Bits(); i++ {
+		if node.terminal {
+			// Already covered by a shorter prefix
+			return
+		}
+		bit := addrBit(bytes, i)
+		if node.children[bit] == nil {
+			node.children[bit] = &trieNode{}
+		}
+		node = node.children[bit]
+	}
+	node.terminal = true
+	node.children = [2]*trieNode{}
+}
+
+// lookup walks towards prefix and returns the node representing it. covered is true when a
+// prefix on the way contains it; the node is nil when nothing in the trie lies inside it.
+func (t *prefixTrie) lookup(prefix netip.Prefix) (node *trieNode, covered bool) {
+	node = t.root(prefix.Addr())
+	bytes := prefix.Addr().AsSlice()
+	for i := 0; i < prefix.Bits(); i++ {
+		if node.terminal {
+			return node, true
+		}
+		node = node.children[addrBit(bytes, i)]
+		if node == nil {
+			return nil, false
+		}
+	}
+	if !node.terminal && node.children[0] == nil && node.children[1] == nil {
+		// Only the root of a family without prefixes is empty
+		return nil, false
+	}
+	return node, node.terminal
+}
+
+// containsAddr reports whether any prefix in the trie contains addr
+func (t *prefixTrie) containsAddr(addr netip.Addr) bool {
+	_, covered := t.lookup(netip.PrefixFrom(addr, addr.BitLen()))
+	return covered
+}
+
+// overlaps reports whether any prefix in the trie shares an address with prefix
+func (t *prefixTrie) overlaps(prefix netip.Prefix) bool {
+	node, _ := t.lookup(prefix)
+	return node != nil
+}
+
+// intersect returns the parts of prefix covered by the trie
+func (t *prefixTrie) intersect(prefix netip.Prefix) []netip.Prefix {
+	node, covered := t.lookup(prefix)
+	if covered {
+		return []netip.Prefix{prefix}
+	}
+	var result []netip.Prefix
+	walkSplit(prefix, node, func(p netip.Prefix, n *trieNode) {
+		if n != nil && n.terminal {
+			result = append(result, p)
+		}
+	})
+	return result
+}
+
+// subtract returns the parts of prefix not covered by the trie
+func (t *prefixTrie) subtract(prefix netip.Prefix) []netip.Prefix {
+	node, covered := t.lookup(prefix)
+	if covered {
+		return nil
+	}
+	var result []netip.Prefix
+	walkSplit(prefix, node, func(p netip.Prefix, n *trieNode) {
+		if n == nil {
+			result = append(result, p)
+		}
+	})
+	return result
+}
+
+// walkSplit descends the trie below prefix, splitting it into halves, and calls visit for every
+// piece that is either outside the trie (nil node) or fully covered by it (terminal node)
+func walkSplit(prefix netip.Prefix, node *trieNode, visit func(netip.Prefix, *trieNode)) {
+	if node == nil || node.terminal || prefix.Bits() == prefix.Addr().BitLen() {
+		visit(prefix, node)
+		return
+	}
+	lower, upper := splitPrefix(prefix)
+	walkSplit(lower, node.children[0], visit)
+	walkSplit(upper, node.children[1], visit)
+}
+
+// splitPrefix returns the two halves of prefix
+func splitPrefix(prefix netip.Prefix) (netip.Prefix, netip.Prefix) {
+	bits := prefix.Bits() + 1
+	lower := netip.PrefixFrom(prefix.Addr(), bits)
+
+	upperAddr := prefix.Addr().AsSlice()
+	index := prefix.Bits()
+	upperAddr[index/8] |= 0x80 >> (index % 8)
+	addr, _ := netip.AddrFromSlice(upperAddr)
+	return lower, netip.PrefixFrom(addr, bits)
+}
+
+// addrBit returns bit i of an address in byte form, counting from the most significant bit
+func addrBit(bytes []byte, i int) int {
+	return int(bytes[i/8]>>(7-i%8)) & 1
+}
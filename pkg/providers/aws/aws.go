@@ -0,0 +1,259 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/providers"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func init() {
+	providers.Register("aws", func() providers.Provider {
+		return &AWSProvider{}
+	})
+}
+
+// AWSIPRanges represents the structure of AWS's ip-ranges.json document
+type AWSIPRanges struct {
+	SyncToken    string          `json:"syncToken"`
+	CreateDate   string          `json:"createDate"`
+	Prefixes     []AWSIPPrefix   `json:"prefixes"`
+	IPv6Prefixes []AWSIPv6Prefix `json:"ipv6_prefixes"`
+}
+
+// AWSIPPrefix represents an IPv4 prefix entry in ip-ranges.json
+type AWSIPPrefix struct {
+	IPPrefix           string `json:"ip_prefix"`
+	Region             string `json:"region"`
+	Service            string `json:"service"`
+	NetworkBorderGroup string `json:"network_border_group"`
+}
+
+// AWSIPv6Prefix represents an IPv6 prefix entry in ip-ranges.json
+type AWSIPv6Prefix struct {
+	IPv6Prefix         string `json:"ipv6_prefix"`
+	Region             string `json:"region"`
+	Service            string `json:"service"`
+	NetworkBorderGroup string `json:"network_border_group"`
+}
+
+// AWSProvider implements the Provider interface for AWS IP ranges
+type AWSProvider struct {
+	name       string
+	hostBits   model.HostBitPolicy
+	services   []string
+	regions    []string
+	cacheTTL   time.Duration
+	lastFetch  time.Time
+	cachedData *model.IPRangeSet
+	syncToken  string
+	createDate string
+	cacheMutex sync.RWMutex
+	httpClient *http.Client
+	url        string
+}
+
+var log = ctrl.Log.WithName("providers.aws")
+
+// Name returns the provider name
+func (p *AWSProvider) Name() string {
+	return p.name
+}
+
+// Type returns the provider type
+func (p *AWSProvider) Type() string {
+	return "aws"
+}
+
+// Init initializes the AWS provider with options
+func (p *AWSProvider) Init(ctx context.Context, options map[string]interface{}) error {
+	// Set default values
+	p.url = "https://ip-ranges.amazonaws.com/ip-ranges.json"
+	p.services = []string{"AMAZON"}
+	p.cacheTTL = 15 * time.Minute
+	p.httpClient = &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	// Process options
+	if name, ok := options["name"].(string); ok {
+		p.name = name
+	} else {
+		p.name = "aws"
+	}
+
+	if services, ok := options["services"].([]string); ok && len(services) > 0 {
+		p.services = services
+	}
+
+	if regions, ok := options["regions"].([]string); ok {
+		p.regions = regions
+	}
+
+	hostBits, err := providers.ParseHostBitPolicy(options)
+	if err != nil {
+		return err
+	}
+	p.hostBits = hostBits
+
+	if cacheTTL, ok := options["cacheTTL"].(string); ok {
+		duration, err := time.ParseDuration(cacheTTL)
+		if err != nil {
+			return fmt.Errorf("invalid cacheTTL format: %w", err)
+		}
+		p.cacheTTL = duration
+	}
+
+	if url, ok := options["url"].(string); ok && url != "" {
+		p.url = url
+	}
+
+	// ip-ranges.json is public, credentials are accepted for forward compatibility only
+	if _, ok := options["accessKey"].(string); ok {
+		log.V(1).Info("AWS credentials are not required to fetch public IP ranges", "provider", p.name)
+	}
+
+	log.Info("Initialized AWS provider",
+		"name", p.name,
+		"services", p.services,
+		"regions", p.regions,
+		"cacheTTL", p.cacheTTL.String(),
+		"url", p.url)
+
+	return nil
+}
+
+// FetchIPRanges fetches IP ranges from AWS
+func (p *AWSProvider) FetchIPRanges(ctx context.Context) (*model.IPRangeSet, error) {
+	// Check if we have a valid cache
+	p.cacheMutex.RLock()
+	if p.cachedData != nil && time.Since(p.lastFetch) < p.cacheTTL {
+		defer p.cacheMutex.RUnlock()
+		log.V(1).Info("Using cached AWS IP ranges", "provider", p.name, "age", time.Since(p.lastFetch).String())
+		return p.cachedData, nil
+	}
+	p.cacheMutex.RUnlock()
+
+	// Lock for writing
+	p.cacheMutex.Lock()
+	defer p.cacheMutex.Unlock()
+
+	// Double-check cache under write lock
+	if p.cachedData != nil && time.Since(p.lastFetch) < p.cacheTTL {
+		log.V(1).Info("Using cached AWS IP ranges (verified under lock)", "provider", p.name)
+		return p.cachedData, nil
+	}
+
+	log.Info("Fetching AWS IP ranges", "provider", p.name, "url", p.url)
+
+	// Create the request
+	req, err := http.NewRequestWithContext(ctx, "GET", p.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	// Make the request
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching AWS IP ranges: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("AWS returned non-OK status: %d, body: %s", resp.StatusCode, body)
+	}
+
+	// Read and parse the response
+	var document AWSIPRanges
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, fmt.Errorf("error decoding AWS IP ranges: %w", err)
+	}
+
+	// Skip rebuilding the set if the document has not changed since the last fetch
+	if p.cachedData != nil && document.SyncToken == p.syncToken && document.CreateDate == p.createDate {
+		log.V(1).Info("AWS IP ranges unchanged", "provider", p.name, "syncToken", document.SyncToken, "createDate", document.CreateDate)
+		p.lastFetch = time.Now()
+		return p.cachedData, nil
+	}
+
+	// Convert to our model
+	ipRangeSet := model.NewIPRangeSet()
+	ipRangeSet.HostBits = p.hostBits
+
+	// Process IPv4 prefixes
+	ipv4Count := 0
+	for _, prefix := range document.Prefixes {
+		if !p.matches(prefix.Service, prefix.Region) {
+			continue
+		}
+		source := model.Provenance{Category: prefix.Service, Region: prefix.Region}
+		if err := ipRangeSet.AddWithSource(prefix.IPPrefix, rangeLabels(prefix.Service, prefix.Region, prefix.NetworkBorderGroup), source); err != nil {
+			log.Error(err, "Error adding IPv4 range", "cidr", prefix.IPPrefix)
+			continue
+		}
+		ipv4Count++
+	}
+
+	// Process IPv6 prefixes
+	ipv6Count := 0
+	for _, prefix := range document.IPv6Prefixes {
+		if !p.matches(prefix.Service, prefix.Region) {
+			continue
+		}
+		source := model.Provenance{Category: prefix.Service, Region: prefix.Region}
+		if err := ipRangeSet.AddWithSource(prefix.IPv6Prefix, rangeLabels(prefix.Service, prefix.Region, prefix.NetworkBorderGroup), source); err != nil {
+			log.Error(err, "Error adding IPv6 range", "cidr", prefix.IPv6Prefix)
+			continue
+		}
+		ipv6Count++
+	}
+
+	log.Info("Successfully fetched AWS IP ranges",
+		"provider", p.name,
+		"count", ipRangeSet.Count(),
+		"ipv4", ipv4Count,
+		"ipv6", ipv6Count,
+		"syncToken", document.SyncToken,
+		"createDate", document.CreateDate)
+
+	// Update cache
+	p.cachedData = ipRangeSet
+	p.syncToken = document.SyncToken
+	p.createDate = document.CreateDate
+	p.lastFetch = time.Now()
+
+	return ipRangeSet, nil
+}
+
+// matches reports whether a prefix belongs to one of the configured services and regions
+func (p *AWSProvider) matches(service, region string) bool {
+	if !providers.ContainsFold(p.services, service) {
+		return false
+	}
+	if len(p.regions) > 0 && !providers.ContainsFold(p.regions, region) {
+		return false
+	}
+	return true
+}
+
+// rangeLabels builds the labels for a prefix from its service, region and network border group
+func rangeLabels(service, region, networkBorderGroup string) []string {
+	labels := []string{service}
+	if region != "" {
+		labels = append(labels, region)
+	}
+	if networkBorderGroup != "" && networkBorderGroup != region {
+		labels = append(labels, networkBorderGroup)
+	}
+	return labels
+}
@@ -0,0 +1,149 @@
+package aws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+const document = `{
+	"syncToken": "1700000000",
+	"createDate": "2023-11-14-22-13-20",
+	"prefixes": [
+		{"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "AMAZON", "network_border_group": "ap-northeast-2"},
+		{"ip_prefix": "15.230.56.104/31", "region": "us-east-1", "service": "AMAZON", "network_border_group": "us-east-1-bos-1"},
+		{"ip_prefix": "52.94.76.0/22", "region": "us-west-2", "service": "EC2", "network_border_group": "us-west-2"},
+		{"ip_prefix": "13.34.37.64/27", "region": "ap-southeast-4", "service": "S3", "network_border_group": "ap-southeast-4"}
+	],
+	"ipv6_prefixes": [
+		{"ipv6_prefix": "2600:1f14::/35", "region": "us-west-2", "service": "EC2", "network_border_group": "us-west-2"},
+		{"ipv6_prefix": "2a05:d07a:a000::/40", "region": "eu-south-1", "service": "AMAZON", "network_border_group": "eu-south-1"}
+	]
+}`
+
+func newTestServer(t *testing.T, requests *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(document))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchIPRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		services []string
+		regions  []string
+		want     map[string][]string
+	}{
+		{
+			name: "default services",
+			want: map[string][]string{
+				"3.5.140.0/22":        {"AMAZON", "ap-northeast-2"},
+				"15.230.56.104/31":    {"AMAZON", "us-east-1", "us-east-1-bos-1"},
+				"2a05:d07a:a000::/40": {"AMAZON", "eu-south-1"},
+			},
+		},
+		{
+			name:     "services and regions match case-insensitively",
+			services: []string{"ec2", "s3"},
+			regions:  []string{"US-WEST-2"},
+			want: map[string][]string{
+				"52.94.76.0/22":  {"EC2", "us-west-2"},
+				"2600:1f14::/35": {"EC2", "us-west-2"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			server := newTestServer(t, &requests)
+
+			options := map[string]interface{}{"url": server.URL}
+			if tt.services != nil {
+				options["services"] = tt.services
+			}
+			if tt.regions != nil {
+				options["regions"] = tt.regions
+			}
+			provider := &AWSProvider{}
+			if err := provider.Init(context.Background(), options); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			ipRanges, err := provider.FetchIPRanges(context.Background())
+			if err != nil {
+				t.Fatalf("FetchIPRanges() error = %v", err)
+			}
+
+			got := make(map[string][]string)
+			for _, ipRange := range ipRanges.Ranges {
+				got[ipRange.CIDR] = ipRange.Labels
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FetchIPRanges() = %v, want %v", got, tt.want)
+			}
+			for _, ipRange := range ipRanges.Ranges {
+				if len(ipRange.Sources) != 1 || ipRange.Sources[0].Region == "" || ipRange.Sources[0].Category == "" {
+					t.Errorf("sources of %s = %+v, want the service and region", ipRange.CIDR, ipRange.Sources)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchIPRangesCache(t *testing.T) {
+	var requests int32
+	server := newTestServer(t, &requests)
+
+	provider := &AWSProvider{}
+	if err := provider.Init(context.Background(), map[string]interface{}{"url": server.URL}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	first, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if _, err := provider.FetchIPRanges(context.Background()); err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("fetched %d times within the cache TTL, want 1", requests)
+	}
+
+	// Once the TTL expires an unchanged syncToken reuses the cached set
+	provider.cacheTTL = 0
+	second, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("fetched %d times after the cache TTL, want 2", requests)
+	}
+	if second != first {
+		t.Error("FetchIPRanges() rebuilt the set for an unchanged document")
+	}
+}
+
+func TestFetchIPRangesError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	provider := &AWSProvider{}
+	if err := provider.Init(context.Background(), map[string]interface{}{"url": server.URL}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if _, err := provider.FetchIPRanges(context.Background()); err == nil {
+		t.Error("FetchIPRanges() error = nil, want an error for a non-OK status")
+	}
+}
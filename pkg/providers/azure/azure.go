@@ -0,0 +1,266 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/providers"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func init() {
+	providers.Register("azure", func() providers.Provider {
+		return &AzureProvider{}
+	})
+}
+
+// AzureServiceTags represents the structure of the Azure Service Tags JSON file
+type AzureServiceTags struct {
+	ChangeNumber int64             `json:"changeNumber"`
+	Cloud        string            `json:"cloud"`
+	Values       []AzureServiceTag `json:"values"`
+}
+
+// AzureServiceTag represents a single service tag entry
+type AzureServiceTag struct {
+	Name       string                    `json:"name"`
+	ID         string                    `json:"id"`
+	Properties AzureServiceTagProperties `json:"properties"`
+}
+
+// AzureServiceTagProperties holds the address prefixes and metadata of a service tag
+type AzureServiceTagProperties struct {
+	ChangeNumber    int64    `json:"changeNumber"`
+	Region          string   `json:"region"`
+	SystemService   string   `json:"systemService"`
+	AddressPrefixes []string `json:"addressPrefixes"`
+}
+
+// AzureProvider implements the Provider interface for Azure Service Tags
+type AzureProvider struct {
+	name         string
+	hostBits     model.HostBitPolicy
+	url          string
+	file         string
+	serviceTags  []string
+	regions      []string
+	cacheTTL     time.Duration
+	lastFetch    time.Time
+	cachedData   *model.IPRangeSet
+	changeNumber int64
+	cacheMutex   sync.RWMutex
+	httpClient   *http.Client
+}
+
+var log = ctrl.Log.WithName("providers.azure")
+
+// Name returns the provider name
+func (p *AzureProvider) Name() string {
+	return p.name
+}
+
+// Type returns the provider type
+func (p *AzureProvider) Type() string {
+	return "azure"
+}
+
+// Init initializes the Azure provider with options
+func (p *AzureProvider) Init(ctx context.Context, options map[string]interface{}) error {
+	// Set default values
+	p.cacheTTL = 1 * time.Hour
+	p.httpClient = &http.Client{
+		Timeout: 60 * time.Second,
+	}
+
+	// Process options
+	if name, ok := options["name"].(string); ok {
+		p.name = name
+	} else {
+		p.name = "azure"
+	}
+
+	if url, ok := options["url"].(string); ok {
+		p.url = url
+	}
+
+	if file, ok := options["file"].(string); ok {
+		p.file = file
+	}
+
+	// Microsoft rotates the download URL weekly, so there is no sensible default
+	if p.url == "" && p.file == "" {
+		return fmt.Errorf("either url or file is required")
+	}
+
+	if serviceTags, ok := options["serviceTags"].([]string); ok {
+		p.serviceTags = serviceTags
+	}
+
+	if regions, ok := options["regions"].([]string); ok {
+		p.regions = regions
+	}
+
+	hostBits, err := providers.ParseHostBitPolicy(options)
+	if err != nil {
+		return err
+	}
+	p.hostBits = hostBits
+
+	if cacheTTL, ok := options["cacheTTL"].(string); ok {
+		duration, err := time.ParseDuration(cacheTTL)
+		if err != nil {
+			return fmt.Errorf("invalid cacheTTL format: %w", err)
+		}
+		p.cacheTTL = duration
+	}
+
+	log.Info("Initialized Azure provider",
+		"name", p.name,
+		"url", p.url,
+		"file", p.file,
+		"serviceTags", p.serviceTags,
+		"regions", p.regions,
+		"cacheTTL", p.cacheTTL.String())
+
+	return nil
+}
+
+// FetchIPRanges fetches IP ranges from the Azure Service Tags file
+func (p *AzureProvider) FetchIPRanges(ctx context.Context) (*model.IPRangeSet, error) {
+	// Check if we have a valid cache
+	p.cacheMutex.RLock()
+	if p.cachedData != nil && time.Since(p.lastFetch) < p.cacheTTL {
+		defer p.cacheMutex.RUnlock()
+		log.V(1).Info("Using cached Azure IP ranges", "provider", p.name, "age", time.Since(p.lastFetch).String())
+		return p.cachedData, nil
+	}
+	p.cacheMutex.RUnlock()
+
+	// Lock for writing
+	p.cacheMutex.Lock()
+	defer p.cacheMutex.Unlock()
+
+	// Double-check cache under write lock
+	if p.cachedData != nil && time.Since(p.lastFetch) < p.cacheTTL {
+		log.V(1).Info("Using cached Azure IP ranges (verified under lock)", "provider", p.name)
+		return p.cachedData, nil
+	}
+
+	document, err := p.readDocument(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip rebuilding the set if the document has not changed since the last fetch
+	if p.cachedData != nil && document.ChangeNumber != 0 && document.ChangeNumber == p.changeNumber {
+		log.V(1).Info("Azure IP ranges unchanged", "provider", p.name, "changeNumber", document.ChangeNumber)
+		p.lastFetch = time.Now()
+		return p.cachedData, nil
+	}
+
+	// Convert to our model
+	ipRangeSet := model.NewIPRangeSet()
+	ipRangeSet.HostBits = p.hostBits
+	tagCount := 0
+	for _, tag := range document.Values {
+		if !p.matches(tag) {
+			continue
+		}
+		tagCount++
+
+		labels := rangeLabels(tag)
+		source := model.Provenance{Category: tag.Name, Region: tag.Properties.Region}
+		for _, cidr := range tag.Properties.AddressPrefixes {
+			if err := ipRangeSet.AddWithSource(cidr, labels, source); err != nil {
+				log.Error(err, "Error adding Azure IP range", "cidr", cidr, "serviceTag", tag.Name)
+			}
+		}
+	}
+
+	log.Info("Successfully fetched Azure IP ranges",
+		"provider", p.name,
+		"count", ipRangeSet.Count(),
+		"serviceTags", tagCount,
+		"changeNumber", document.ChangeNumber)
+
+	// Update cache
+	p.cachedData = ipRangeSet
+	p.changeNumber = document.ChangeNumber
+	p.lastFetch = time.Now()
+
+	return ipRangeSet, nil
+}
+
+// readDocument reads the Service Tags document from the mounted file or the configured URL
+func (p *AzureProvider) readDocument(ctx context.Context) (*AzureServiceTags, error) {
+	var document AzureServiceTags
+
+	if p.file != "" {
+		log.Info("Reading Azure Service Tags file", "provider", p.name, "file", p.file)
+
+		data, err := os.ReadFile(p.file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading Azure Service Tags file: %w", err)
+		}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("error decoding Azure Service Tags: %w", err)
+		}
+		return &document, nil
+	}
+
+	log.Info("Fetching Azure Service Tags", "provider", p.name, "url", p.url)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching Azure Service Tags: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Azure returned non-OK status: %d, body: %s", resp.StatusCode, body)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, fmt.Errorf("error decoding Azure Service Tags: %w", err)
+	}
+
+	return &document, nil
+}
+
+// matches reports whether a service tag is selected by the configured tags and regions
+func (p *AzureProvider) matches(tag AzureServiceTag) bool {
+	if len(p.serviceTags) > 0 && !providers.ContainsFold(p.serviceTags, tag.Name) {
+		return false
+	}
+	if len(p.regions) > 0 && !providers.ContainsFold(p.regions, tag.Properties.Region) {
+		return false
+	}
+	return true
+}
+
+// rangeLabels builds the labels for a service tag from its name, system service and region
+func rangeLabels(tag AzureServiceTag) []string {
+	labels := []string{tag.Name}
+	if tag.Properties.SystemService != "" && tag.Properties.SystemService != tag.Name {
+		labels = append(labels, tag.Properties.SystemService)
+	}
+	if tag.Properties.Region != "" {
+		labels = append(labels, tag.Properties.Region)
+	}
+	return labels
+}
@@ -0,0 +1,239 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+const document = `{
+	"changeNumber": 321,
+	"cloud": "Public",
+	"values": [
+		{
+			"name": "AzureFrontDoor.Backend",
+			"id": "AzureFrontDoor.Backend",
+			"properties": {"changeNumber": 12, "region": "", "systemService": "AzureFrontDoor", "addressPrefixes": ["13.73.248.8/29", "2603:1030:21::/48"]}
+		},
+		{
+			"name": "Storage.WestEurope",
+			"id": "Storage.WestEurope",
+			"properties": {"changeNumber": 40, "region": "westeurope", "systemService": "AzureStorage", "addressPrefixes": ["13.69.40.16/28", "not-a-cidr"]}
+		},
+		{
+			"name": "Storage.NorthEurope",
+			"id": "Storage.NorthEurope",
+			"properties": {"changeNumber": 38, "region": "northeurope", "systemService": "AzureStorage", "addressPrefixes": ["13.70.99.0/24"]}
+		},
+		{
+			"name": "AzureCloud",
+			"id": "AzureCloud",
+			"properties": {"changeNumber": 90, "region": "", "systemService": "", "addressPrefixes": ["20.33.0.0/16"]}
+		}
+	]
+}`
+
+func newTestServer(t *testing.T, requests *int32, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchIPRanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceTags []string
+		regions     []string
+		want        map[string][]string
+	}{
+		{
+			name: "every service tag",
+			want: map[string][]string{
+				"13.73.248.8/29":    {"AzureFrontDoor.Backend", "AzureFrontDoor"},
+				"2603:1030:21::/48": {"AzureFrontDoor.Backend", "AzureFrontDoor"},
+				"13.69.40.16/28":    {"Storage.WestEurope", "AzureStorage", "westeurope"},
+				"13.70.99.0/24":     {"Storage.NorthEurope", "AzureStorage", "northeurope"},
+				"20.33.0.0/16":      {"AzureCloud"},
+			},
+		},
+		{
+			name:        "service tags match case-insensitively",
+			serviceTags: []string{"azurefrontdoor.backend"},
+			want: map[string][]string{
+				"13.73.248.8/29":    {"AzureFrontDoor.Backend", "AzureFrontDoor"},
+				"2603:1030:21::/48": {"AzureFrontDoor.Backend", "AzureFrontDoor"},
+			},
+		},
+		{
+			name:    "regions",
+			regions: []string{"WestEurope"},
+			want: map[string][]string{
+				"13.69.40.16/28": {"Storage.WestEurope", "AzureStorage", "westeurope"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			server := newTestServer(t, &requests, document)
+
+			options := map[string]interface{}{"url": server.URL}
+			if tt.serviceTags != nil {
+				options["serviceTags"] = tt.serviceTags
+			}
+			if tt.regions != nil {
+				options["regions"] = tt.regions
+			}
+			provider := &AzureProvider{}
+			if err := provider.Init(context.Background(), options); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			ipRanges, err := provider.FetchIPRanges(context.Background())
+			if err != nil {
+				t.Fatalf("FetchIPRanges() error = %v", err)
+			}
+
+			got := make(map[string][]string)
+			for _, ipRange := range ipRanges.Ranges {
+				got[ipRange.CIDR] = ipRange.Labels
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FetchIPRanges() = %v, want %v", got, tt.want)
+			}
+			for _, ipRange := range ipRanges.Ranges {
+				if len(ipRange.Sources) != 1 || ipRange.Sources[0].Category == "" {
+					t.Errorf("sources of %s = %+v, want the service tag", ipRange.CIDR, ipRange.Sources)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchIPRangesFromFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ServiceTags_Public.json")
+	if err := os.WriteFile(file, []byte(document), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &AzureProvider{}
+	if err := provider.Init(context.Background(), map[string]interface{}{"file": file, "regions": []string{"northeurope"}}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	ipRanges, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if got, want := ipRanges.GetCIDRs(), []string{"13.70.99.0/24"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FetchIPRanges() = %v, want %v", got, want)
+	}
+}
+
+func TestFetchIPRangesCache(t *testing.T) {
+	var requests int32
+	server := newTestServer(t, &requests, document)
+
+	provider := &AzureProvider{}
+	if err := provider.Init(context.Background(), map[string]interface{}{"url": server.URL}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	first, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if _, err := provider.FetchIPRanges(context.Background()); err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("fetched %d times within the cache TTL, want 1", requests)
+	}
+
+	// Once the TTL expires an unchanged changeNumber reuses the cached set
+	provider.cacheTTL = 0
+	second, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("fetched %d times after the cache TTL, want 2", requests)
+	}
+	if second != first {
+		t.Error("FetchIPRanges() rebuilt the set for an unchanged document")
+	}
+}
+
+func TestFetchIPRangesError(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		file    string
+	}{
+		{
+			name: "non-OK status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			},
+		},
+		{
+			name: "invalid JSON",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{"values": [`))
+			},
+		},
+		{
+			name: "missing file",
+			file: filepath.Join(t.TempDir(), "missing.json"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := map[string]interface{}{"file": tt.file}
+			if tt.handler != nil {
+				server := httptest.NewServer(tt.handler)
+				defer server.Close()
+				options = map[string]interface{}{"url": server.URL}
+			}
+
+			provider := &AzureProvider{}
+			if err := provider.Init(context.Background(), options); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+			if _, err := provider.FetchIPRanges(context.Background()); err == nil {
+				t.Error("FetchIPRanges() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+	}{
+		{name: "neither url nor file", options: map[string]interface{}{}},
+		{name: "invalid cacheTTL", options: map[string]interface{}{"url": "https://example.com/ServiceTags_Public.json", "cacheTTL": "weekly"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &AzureProvider{}
+			if err := provider.Init(context.Background(), tt.options); err == nil {
+				t.Error("Init() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/providers"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func init() {
+	providers.Register("dns", func() providers.Provider {
+		return &DNSProvider{}
+	})
+}
+
+// DNSProvider implements the Provider interface for IP ranges published through DNS
+type DNSProvider struct {
+	name       string
+	hostBits   model.HostBitPolicy
+	spfDomains []string
+	hostnames  []string
+	maxDepth   int
+	cacheTTL   time.Duration
+	lastFetch  time.Time
+	cachedData *model.IPRangeSet
+	cacheMutex sync.RWMutex
+	resolver   dnsResolver
+}
+
+// dnsResolver is the subset of net.Resolver used by the provider
+type dnsResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+var log = ctrl.Log.WithName("providers.dns")
+
+// Name returns the provider name
+func (p *DNSProvider) Name() string {
+	return p.name
+}
+
+// Type returns the provider type
+func (p *DNSProvider) Type() string {
+	return "dns"
+}
+
+// Init initializes the DNS provider with options
+func (p *DNSProvider) Init(ctx context.Context, options map[string]interface{}) error {
+	// Set default values
+	p.maxDepth = 10
+	p.cacheTTL = 15 * time.Minute
+	p.resolver = net.DefaultResolver
+
+	// Process options
+	if name, ok := options["name"].(string); ok {
+		p.name = name
+	} else {
+		p.name = "dns"
+	}
+
+	if spfDomains, ok := options["spfDomains"].([]string); ok {
+		p.spfDomains = spfDomains
+	}
+
+	if hostnames, ok := options["hostnames"].([]string); ok {
+		p.hostnames = hostnames
+	}
+
+	if len(p.spfDomains) == 0 && len(p.hostnames) == 0 {
+		return fmt.Errorf("at least one SPF domain or hostname is required")
+	}
+
+	if maxDepth, ok := options["maxDepth"].(int); ok && maxDepth > 0 {
+		p.maxDepth = maxDepth
+	}
+
+	// Send every query to the configured resolver instead of the system one
+	if resolverAddress, ok := options["resolver"].(string); ok && resolverAddress != "" {
+		if _, _, err := net.SplitHostPort(resolverAddress); err != nil {
+			return fmt.Errorf("invalid resolver address '%s': %w", resolverAddress, err)
+		}
+		p.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				dialer := net.Dialer{Timeout: 5 * time.Second}
+				return dialer.DialContext(ctx, network, resolverAddress)
+			},
+		}
+	}
+
+	hostBits, err := providers.ParseHostBitPolicy(options)
+	if err != nil {
+		return err
+	}
+	p.hostBits = hostBits
+
+	if cacheTTL, ok := options["cacheTTL"].(string); ok {
+		duration, err := time.ParseDuration(cacheTTL)
+		if err != nil {
+			return fmt.Errorf("invalid cacheTTL format: %w", err)
+		}
+		p.cacheTTL = duration
+	}
+
+	log.Info("Initialized DNS provider",
+		"name", p.name,
+		"spfDomains", p.spfDomains,
+		"hostnames", p.hostnames,
+		"maxDepth", p.maxDepth,
+		"cacheTTL", p.cacheTTL.String())
+
+	return nil
+}
+
+// FetchIPRanges resolves the configured SPF domains and hostnames
+func (p *DNSProvider) FetchIPRanges(ctx context.Context) (*model.IPRangeSet, error) {
+	// Check if we have a valid cache
+	p.cacheMutex.RLock()
+	if p.cachedData != nil && time.Since(p.lastFetch) < p.cacheTTL {
+		defer p.cacheMutex.RUnlock()
+		log.V(1).Info("Using cached DNS IP ranges", "provider", p.name, "age", time.Since(p.lastFetch).String())
+		return p.cachedData, nil
+	}
+	p.cacheMutex.RUnlock()
+
+	// Lock for writing
+	p.cacheMutex.Lock()
+	defer p.cacheMutex.Unlock()
+
+	// Double-check cache under write lock
+	if p.cachedData != nil && time.Since(p.lastFetch) < p.cacheTTL {
+		log.V(1).Info("Using cached DNS IP ranges (verified under lock)", "provider", p.name)
+		return p.cachedData, nil
+	}
+
+	ipRangeSet := model.NewIPRangeSet()
+	ipRangeSet.HostBits = p.hostBits
+
+	// Expand SPF records
+	for _, domain := range p.spfDomains {
+		if err := p.expandSPF(ctx, ipRangeSet, domain, domain, 0, make(map[string]bool), make(map[string]bool)); err != nil {
+			return nil, fmt.Errorf("error expanding SPF record for %s: %w", domain, err)
+		}
+	}
+
+	// Resolve hostnames
+	for _, hostname := range p.hostnames {
+		addrs, err := p.resolver.LookupIPAddr(ctx, hostname)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving %s: %w", hostname, err)
+		}
+		for _, addr := range addrs {
+			if err := ipRangeSet.AddWithSource(hostCIDR(addr.IP), []string{hostname}, model.Provenance{Category: hostname}); err != nil {
+				log.Error(err, "Error adding resolved address", "hostname", hostname)
+			}
+		}
+	}
+
+	log.Info("Successfully resolved DNS IP ranges", "provider", p.name, "count", ipRangeSet.Count())
+
+	// Update cache
+	p.cachedData = ipRangeSet
+	p.lastFetch = time.Now()
+
+	return ipRangeSet, nil
+}
+
+// expandSPF adds the ip4/ip6 mechanisms of the SPF record at domain and recursively follows
+// include mechanisms and redirect modifiers. Ranges are labelled with the domain whose
+// record declared them and with the root domain the expansion started from. path holds the
+// domains being expanded above this one, so only an include back into it is a loop; domains
+// in expanded were already reached through another include and are skipped.
+func (p *DNSProvider) expandSPF(ctx context.Context, ipRangeSet *model.IPRangeSet, root, domain string, depth int, path, expanded map[string]bool) error {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if path[domain] {
+		log.Info("Skipping SPF include loop", "provider", p.name, "domain", domain, "root", root)
+		return nil
+	}
+	if expanded[domain] {
+		log.V(1).Info("Skipping already expanded SPF include", "provider", p.name, "domain", domain, "root", root)
+		return nil
+	}
+	if depth > p.maxDepth {
+		return fmt.Errorf("SPF include depth limit of %d exceeded at %s", p.maxDepth, domain)
+	}
+	path[domain] = true
+	defer delete(path, domain)
+	expanded[domain] = true
+
+	record, err := p.lookupSPF(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	labels := []string{domain}
+	if domain != root {
+		labels = append(labels, root)
+	}
+
+	var redirect string
+	for _, term := range strings.Fields(record)[1:] {
+		// Strip the qualifier; only pass mechanisms describe allowed senders
+		qualifier := term[0]
+		if qualifier == '+' || qualifier == '-' || qualifier == '~' || qualifier == '?' {
+			term = term[1:]
+		}
+		if qualifier == '-' || qualifier == '~' || qualifier == '?' {
+			continue
+		}
+
+		name, value, _ := strings.Cut(term, ":")
+		if strings.HasPrefix(strings.ToLower(term), "redirect=") {
+			redirect = term[len("redirect="):]
+			continue
+		}
+
+		switch strings.ToLower(name) {
+		case "ip4", "ip6":
+			cidr := value
+			if !strings.Contains(cidr, "/") {
+				cidr = hostCIDR(net.ParseIP(cidr))
+			}
+			if err := ipRangeSet.AddWithSource(cidr, labels, model.Provenance{Category: domain}); err != nil {
+				log.Error(err, "Error adding SPF range", "domain", domain, "term", term)
+			}
+		case "include":
+			if err := p.expandSPF(ctx, ipRangeSet, root, value, depth+1, path, expanded); err != nil {
+				return err
+			}
+		}
+	}
+
+	// A redirect only applies when the record has no "all" mechanism
+	if redirect != "" && !hasAll(record) {
+		return p.expandSPF(ctx, ipRangeSet, root, redirect, depth+1, path, expanded)
+	}
+
+	return nil
+}
+
+// lookupSPF returns the SPF record published in the TXT records of domain
+func (p *DNSProvider) lookupSPF(ctx context.Context, domain string) (string, error) {
+	records, err := p.resolver.LookupTXT(ctx, domain)
+	if err != nil {
+		return "", fmt.Errorf("error looking up TXT records for %s: %w", domain, err)
+	}
+
+	for _, record := range records {
+		if record == "v=spf1" || strings.HasPrefix(strings.ToLower(record), "v=spf1 ") {
+			return record, nil
+		}
+	}
+
+	return "", fmt.Errorf("no SPF record found for %s", domain)
+}
+
+// hasAll reports whether an SPF record contains an "all" mechanism
+func hasAll(record string) bool {
+	for _, term := range strings.Fields(record) {
+		if strings.TrimLeft(strings.ToLower(term), "+-~?") == "all" {
+			return true
+		}
+	}
+	return false
+}
+
+// hostCIDR returns the single-address CIDR for ip, /32 for IPv4 and /128 for IPv6
+func hostCIDR(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
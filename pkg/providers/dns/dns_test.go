@@ -0,0 +1,327 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+)
+
+// fakeResolver answers lookups from fixed records and counts TXT lookups per name
+type fakeResolver struct {
+	txt     map[string][]string
+	hosts   map[string][]net.IPAddr
+	lookups map[string]int
+}
+
+func (r *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if r.lookups == nil {
+		r.lookups = make(map[string]int)
+	}
+	r.lookups[name]++
+	records, ok := r.txt[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func (r *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs, ok := r.hosts[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+func newTestProvider(t *testing.T, options map[string]interface{}, resolver dnsResolver) *DNSProvider {
+	t.Helper()
+	provider := &DNSProvider{}
+	if err := provider.Init(context.Background(), options); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	provider.resolver = resolver
+	return provider
+}
+
+func TestExpandSPF(t *testing.T) {
+	tests := []struct {
+		name     string
+		txt      map[string][]string
+		maxDepth int
+		want     map[string][]string
+		wantErr  bool
+	}{
+		{
+			name: "mechanisms and qualifiers",
+			txt: map[string][]string{
+				"example.com": {
+					"google-site-verification=abc",
+					"v=spf1 ip4:192.0.2.0/24 +ip4:198.51.100.7 ip6:2001:db8::/32 -ip4:203.0.113.0/24 ~ip6:2001:db8:1::/48 mx a -all",
+				},
+			},
+			want: map[string][]string{
+				"192.0.2.0/24":    {"example.com"},
+				"198.51.100.7/32": {"example.com"},
+				"2001:db8::/32":   {"example.com"},
+			},
+		},
+		{
+			name: "includes are labelled with the root domain",
+			txt: map[string][]string{
+				"example.com":       {"v=spf1 include:_spf.example.net. ip4:192.0.2.0/24 ~all"},
+				"_spf.example.net":  {"v=spf1 include:_spf2.example.net ip4:198.51.100.0/24 ~all"},
+				"_spf2.example.net": {"v=spf1 ip6:2001:db8::/32 ~all"},
+			},
+			want: map[string][]string{
+				"192.0.2.0/24":    {"example.com"},
+				"198.51.100.0/24": {"_spf.example.net", "example.com"},
+				"2001:db8::/32":   {"_spf2.example.net", "example.com"},
+			},
+		},
+		{
+			name: "include loop is expanded once",
+			txt: map[string][]string{
+				"example.com":   {"v=spf1 include:a.example.com ip4:192.0.2.0/24 ~all"},
+				"a.example.com": {"v=spf1 include:b.example.com ip4:198.51.100.0/24 ~all"},
+				"b.example.com": {"v=spf1 include:A.example.com include:example.com ip4:203.0.113.0/24 ~all"},
+			},
+			want: map[string][]string{
+				"192.0.2.0/24":    {"example.com"},
+				"198.51.100.0/24": {"a.example.com", "example.com"},
+				"203.0.113.0/24":  {"b.example.com", "example.com"},
+			},
+		},
+		{
+			name: "diamond include is expanded once",
+			txt: map[string][]string{
+				"example.com":   {"v=spf1 include:a.example.com include:b.example.com ~all"},
+				"a.example.com": {"v=spf1 include:c.example.com ip4:192.0.2.0/24 ~all"},
+				"b.example.com": {"v=spf1 include:c.example.com ip4:198.51.100.0/24 ~all"},
+				"c.example.com": {"v=spf1 ip4:203.0.113.0/24 ~all"},
+			},
+			want: map[string][]string{
+				"192.0.2.0/24":    {"a.example.com", "example.com"},
+				"198.51.100.0/24": {"b.example.com", "example.com"},
+				"203.0.113.0/24":  {"c.example.com", "example.com"},
+			},
+		},
+		{
+			name: "redirect without all is followed",
+			txt: map[string][]string{
+				"example.com":      {"v=spf1 ip4:192.0.2.0/24 redirect=_spf.example.com"},
+				"_spf.example.com": {"v=spf1 ip4:198.51.100.0/24 -all"},
+			},
+			want: map[string][]string{
+				"192.0.2.0/24":    {"example.com"},
+				"198.51.100.0/24": {"_spf.example.com", "example.com"},
+			},
+		},
+		{
+			name: "redirect is ignored when the record has all",
+			txt: map[string][]string{
+				"example.com":      {"v=spf1 ip4:192.0.2.0/24 redirect=_spf.example.com -all"},
+				"_spf.example.com": {"v=spf1 ip4:198.51.100.0/24 -all"},
+			},
+			want: map[string][]string{
+				"192.0.2.0/24": {"example.com"},
+			},
+		},
+		{
+			name:     "include chain within the depth limit",
+			txt:      includeChain(3),
+			maxDepth: 3,
+			want: map[string][]string{
+				"192.0.2.3/32": {"d3.example.com", "d0.example.com"},
+			},
+		},
+		{
+			name:     "include chain beyond the depth limit",
+			txt:      includeChain(4),
+			maxDepth: 3,
+			wantErr:  true,
+		},
+		{
+			name: "include without an SPF record",
+			txt: map[string][]string{
+				"example.com":   {"v=spf1 include:a.example.com -all"},
+				"a.example.com": {"v=spf10 ip4:192.0.2.0/24"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := "example.com"
+			if _, ok := tt.txt[root]; !ok {
+				root = "d0.example.com"
+			}
+			options := map[string]interface{}{"spfDomains": []string{root}}
+			if tt.maxDepth > 0 {
+				options["maxDepth"] = tt.maxDepth
+			}
+			provider := newTestProvider(t, options, &fakeResolver{txt: tt.txt})
+
+			ipRanges, err := provider.FetchIPRanges(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FetchIPRanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			got := make(map[string][]string)
+			for _, ipRange := range ipRanges.Ranges {
+				got[ipRange.CIDR] = ipRange.Labels
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FetchIPRanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// includeChain returns records where d0.example.com includes d1.example.com and so on
+// down to d<length>.example.com, which alone lists an address
+func includeChain(length int) map[string][]string {
+	txt := make(map[string][]string)
+	for i := 0; i < length; i++ {
+		txt[fmt.Sprintf("d%d.example.com", i)] = []string{fmt.Sprintf("v=spf1 include:d%d.example.com -all", i+1)}
+	}
+	txt[fmt.Sprintf("d%d.example.com", length)] = []string{fmt.Sprintf("v=spf1 ip4:192.0.2.%d -all", length)}
+	return txt
+}
+
+func TestFetchIPRangesHostnames(t *testing.T) {
+	resolver := &fakeResolver{
+		hosts: map[string][]net.IPAddr{
+			"smtp.example.com": {{IP: net.ParseIP("192.0.2.1")}, {IP: net.ParseIP("2001:db8::25")}},
+		},
+	}
+	provider := newTestProvider(t, map[string]interface{}{"hostnames": []string{"smtp.example.com"}}, resolver)
+
+	ipRanges, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if got, want := ipRanges.GetCIDRs(), []string{"192.0.2.1/32", "2001:db8::25/128"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FetchIPRanges() = %v, want %v", got, want)
+	}
+
+	provider = newTestProvider(t, map[string]interface{}{"hostnames": []string{"missing.example.com"}}, resolver)
+	if _, err := provider.FetchIPRanges(context.Background()); err == nil {
+		t.Error("FetchIPRanges() error = nil, want an error for an unresolvable hostname")
+	}
+}
+
+func TestExpandSPFReportsOnlyLoops(t *testing.T) {
+	tests := []struct {
+		name     string
+		txt      map[string][]string
+		wantLoop bool
+	}{
+		{
+			name: "diamond",
+			txt: map[string][]string{
+				"example.com":   {"v=spf1 include:a.example.com include:b.example.com ~all"},
+				"a.example.com": {"v=spf1 include:c.example.com ~all"},
+				"b.example.com": {"v=spf1 include:c.example.com ~all"},
+				"c.example.com": {"v=spf1 ip4:203.0.113.0/24 ~all"},
+			},
+		},
+		{
+			name: "loop",
+			txt: map[string][]string{
+				"example.com":   {"v=spf1 include:a.example.com ~all"},
+				"a.example.com": {"v=spf1 include:c.example.com ~all"},
+				"c.example.com": {"v=spf1 include:a.example.com ip4:203.0.113.0/24 ~all"},
+			},
+			wantLoop: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var messages []string
+			defer func(previous logr.Logger) { log = previous }(log)
+			log = funcr.New(func(prefix, args string) {
+				messages = append(messages, args)
+			}, funcr.Options{})
+
+			resolver := &fakeResolver{txt: tt.txt}
+			provider := newTestProvider(t, map[string]interface{}{"spfDomains": []string{"example.com"}}, resolver)
+			if _, err := provider.FetchIPRanges(context.Background()); err != nil {
+				t.Fatalf("FetchIPRanges() error = %v", err)
+			}
+
+			loopReported := strings.Contains(strings.Join(messages, "\n"), "Skipping SPF include loop")
+			if loopReported != tt.wantLoop {
+				t.Errorf("loop reported = %v, want %v: %v", loopReported, tt.wantLoop, messages)
+			}
+			if resolver.lookups["c.example.com"] != 1 {
+				t.Errorf("looked up c.example.com %d times, want 1", resolver.lookups["c.example.com"])
+			}
+		})
+	}
+}
+
+func TestInitResolver(t *testing.T) {
+	// A UDP listener standing in for the resolver records the queries it receives
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var mutex sync.Mutex
+	var queries []string
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			mutex.Lock()
+			queries = append(queries, string(buf[:n]))
+			mutex.Unlock()
+		}
+	}()
+
+	provider := &DNSProvider{}
+	err = provider.Init(context.Background(), map[string]interface{}{
+		"spfDomains": []string{"example.com"},
+		"resolver":   conn.LocalAddr().String(),
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	// The listener never answers, so the lookup fails once the query has been sent
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if _, err := provider.FetchIPRanges(ctx); err == nil {
+		t.Fatal("FetchIPRanges() error = nil, want an error from the silent resolver")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	// Names are encoded as length-prefixed labels in the question
+	if len(queries) == 0 || !strings.Contains(queries[0], "\x07example\x03com") {
+		t.Errorf("resolver received %q, want a query for example.com", queries)
+	}
+
+	for _, address := range []string{"127.0.0.1", "dns.example.com"} {
+		if err := (&DNSProvider{}).Init(context.Background(), map[string]interface{}{"spfDomains": []string{"example.com"}, "resolver": address}); err == nil {
+			t.Errorf("Init() with resolver %q error = nil, want an error for a missing port", address)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/providers"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func init() {
+	providers.Register("gcp", func() providers.Provider {
+		return &GCPProvider{}
+	})
+}
+
+// GCPIPRanges represents the structure of Google's cloud.json, goog.json and googlebot.json documents
+type GCPIPRanges struct {
+	SyncToken    string        `json:"syncToken"`
+	CreationTime string        `json:"creationTime"`
+	Prefixes     []GCPIPPrefix `json:"prefixes"`
+}
+
+// GCPIPPrefix represents a single prefix entry, which holds either an IPv4 or an IPv6 prefix
+type GCPIPPrefix struct {
+	IPv4Prefix string `json:"ipv4Prefix,omitempty"`
+	IPv6Prefix string `json:"ipv6Prefix,omitempty"`
+	Service    string `json:"service,omitempty"`
+	Scope      string `json:"scope,omitempty"`
+}
+
+// GCPProvider implements the Provider interface for Google Cloud and Googlebot IP ranges
+type GCPProvider struct {
+	name       string
+	hostBits   model.HostBitPolicy
+	urls       []string
+	scopes     []string
+	services   []string
+	cacheTTL   time.Duration
+	lastFetch  time.Time
+	cachedData *model.IPRangeSet
+	syncTokens map[string]string
+	cacheMutex sync.RWMutex
+	httpClient *http.Client
+}
+
+var log = ctrl.Log.WithName("providers.gcp")
+
+// Name returns the provider name
+func (p *GCPProvider) Name() string {
+	return p.name
+}
+
+// Type returns the provider type
+func (p *GCPProvider) Type() string {
+	return "gcp"
+}
+
+// Init initializes the GCP provider with options
+func (p *GCPProvider) Init(ctx context.Context, options map[string]interface{}) error {
+	// Set default values
+	p.urls = []string{"https://www.gstatic.com/ipranges/cloud.json"}
+	p.cacheTTL = 15 * time.Minute
+	p.syncTokens = make(map[string]string)
+	p.httpClient = &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	// Process options
+	if name, ok := options["name"].(string); ok {
+		p.name = name
+	} else {
+		p.name = "gcp"
+	}
+
+	if urls, ok := options["urls"].([]string); ok && len(urls) > 0 {
+		p.urls = urls
+	}
+
+	if scopes, ok := options["scopes"].([]string); ok {
+		p.scopes = scopes
+	}
+
+	if services, ok := options["services"].([]string); ok {
+		p.services = services
+	}
+
+	hostBits, err := providers.ParseHostBitPolicy(options)
+	if err != nil {
+		return err
+	}
+	p.hostBits = hostBits
+
+	if cacheTTL, ok := options["cacheTTL"].(string); ok {
+		duration, err := time.ParseDuration(cacheTTL)
+		if err != nil {
+			return fmt.Errorf("invalid cacheTTL format: %w", err)
+		}
+		p.cacheTTL = duration
+	}
+
+	log.Info("Initialized GCP provider",
+		"name", p.name,
+		"urls", p.urls,
+		"scopes", p.scopes,
+		"services", p.services,
+		"cacheTTL", p.cacheTTL.String())
+
+	return nil
+}
+
+// FetchIPRanges fetches IP ranges from all configured Google documents
+func (p *GCPProvider) FetchIPRanges(ctx context.Context) (*model.IPRangeSet, error) {
+	// Check if we have a valid cache
+	p.cacheMutex.RLock()
+	if p.cachedData != nil && time.Since(p.lastFetch) < p.cacheTTL {
+		defer p.cacheMutex.RUnlock()
+		log.V(1).Info("Using cached GCP IP ranges", "provider", p.name, "age", time.Since(p.lastFetch).String())
+		return p.cachedData, nil
+	}
+	p.cacheMutex.RUnlock()
+
+	// Lock for writing
+	p.cacheMutex.Lock()
+	defer p.cacheMutex.Unlock()
+
+	// Double-check cache under write lock
+	if p.cachedData != nil && time.Since(p.lastFetch) < p.cacheTTL {
+		log.V(1).Info("Using cached GCP IP ranges (verified under lock)", "provider", p.name)
+		return p.cachedData, nil
+	}
+
+	// Fetch every document before touching the cache so a partial failure keeps the previous data
+	documents := make(map[string]*GCPIPRanges, len(p.urls))
+	changed := p.cachedData == nil
+	for _, url := range p.urls {
+		document, err := p.fetchDocument(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		documents[url] = document
+		if document.SyncToken == "" || document.SyncToken != p.syncTokens[url] {
+			changed = true
+		}
+	}
+
+	if !changed {
+		log.V(1).Info("GCP IP ranges unchanged", "provider", p.name)
+		p.lastFetch = time.Now()
+		return p.cachedData, nil
+	}
+
+	// Convert to our model
+	ipRangeSet := model.NewIPRangeSet()
+	ipRangeSet.HostBits = p.hostBits
+	for _, url := range p.urls {
+		document := documents[url]
+		source := documentName(url)
+
+		for _, prefix := range document.Prefixes {
+			if !p.matches(prefix.Service, prefix.Scope) {
+				continue
+			}
+
+			cidr := prefix.IPv4Prefix
+			if cidr == "" {
+				cidr = prefix.IPv6Prefix
+			}
+			provenance := model.Provenance{Category: prefix.Service, Region: prefix.Scope}
+			if err := ipRangeSet.AddWithSource(cidr, rangeLabels(source, prefix.Service, prefix.Scope), provenance); err != nil {
+				log.Error(err, "Error adding GCP IP range", "cidr", cidr, "url", url)
+			}
+		}
+	}
+
+	log.Info("Successfully fetched GCP IP ranges",
+		"provider", p.name,
+		"count", ipRangeSet.Count(),
+		"documents", len(p.urls))
+
+	// Update cache
+	for url, document := range documents {
+		p.syncTokens[url] = document.SyncToken
+	}
+	p.cachedData = ipRangeSet
+	p.lastFetch = time.Now()
+
+	return ipRangeSet, nil
+}
+
+// fetchDocument downloads and decodes a single Google IP ranges document
+func (p *GCPProvider) fetchDocument(ctx context.Context, url string) (*GCPIPRanges, error) {
+	log.Info("Fetching GCP IP ranges", "provider", p.name, "url", url)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching GCP IP ranges from %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s returned non-OK status: %d, body: %s", url, resp.StatusCode, body)
+	}
+
+	var document GCPIPRanges
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, fmt.Errorf("error decoding GCP IP ranges from %s: %w", url, err)
+	}
+
+	return &document, nil
+}
+
+// matches reports whether a prefix belongs to one of the configured services and scopes
+func (p *GCPProvider) matches(service, scope string) bool {
+	if len(p.services) > 0 && !providers.ContainsFold(p.services, service) {
+		return false
+	}
+	if len(p.scopes) > 0 && !providers.ContainsFold(p.scopes, scope) {
+		return false
+	}
+	return true
+}
+
+// documentName derives a label from the document URL, e.g. "cloud" for .../cloud.json
+func documentName(url string) string {
+	return strings.TrimSuffix(path.Base(url), path.Ext(url))
+}
+
+// rangeLabels builds the labels for a prefix from its source document, service and scope
+func rangeLabels(source, service, scope string) []string {
+	labels := []string{source}
+	if service != "" {
+		labels = append(labels, service)
+	}
+	if scope != "" {
+		labels = append(labels, scope)
+	}
+	return labels
+}
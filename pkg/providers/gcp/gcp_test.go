@@ -0,0 +1,201 @@
+package gcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+const cloudDocument = `{
+	"syncToken": "1700000000",
+	"creationTime": "2023-11-14T22:13:20.000000",
+	"prefixes": [
+		{"ipv4Prefix": "34.1.208.0/20", "service": "Google Cloud", "scope": "africa-south1"},
+		{"ipv4Prefix": "34.80.0.0/15", "service": "Google Cloud", "scope": "asia-east1"},
+		{"ipv6Prefix": "2600:1900:8000::/44", "service": "Google Cloud", "scope": "us-central1"},
+		{"ipv4Prefix": "not-a-cidr", "service": "Google Cloud", "scope": "us-central1"}
+	]
+}`
+
+const googlebotDocument = `{
+	"creationTime": "2023-11-14T22:13:20.000000",
+	"prefixes": [
+		{"ipv4Prefix": "66.249.64.0/27"},
+		{"ipv6Prefix": "2001:4860:4801:10::/64"}
+	]
+}`
+
+func newTestServer(t *testing.T, requests *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/cloud.json":
+			_, _ = w.Write([]byte(cloudDocument))
+		case "/googlebot.json":
+			_, _ = w.Write([]byte(googlebotDocument))
+		case "/broken.json":
+			_, _ = w.Write([]byte(`{"prefixes": [`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchIPRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		paths    []string
+		services []string
+		scopes   []string
+		want     map[string][]string
+	}{
+		{
+			name:  "cloud document",
+			paths: []string{"/cloud.json"},
+			want: map[string][]string{
+				"34.1.208.0/20":       {"cloud", "Google Cloud", "africa-south1"},
+				"34.80.0.0/15":        {"cloud", "Google Cloud", "asia-east1"},
+				"2600:1900:8000::/44": {"cloud", "Google Cloud", "us-central1"},
+			},
+		},
+		{
+			name:   "scopes match case-insensitively",
+			paths:  []string{"/cloud.json"},
+			scopes: []string{"ASIA-EAST1", "us-central1"},
+			want: map[string][]string{
+				"34.80.0.0/15":        {"cloud", "Google Cloud", "asia-east1"},
+				"2600:1900:8000::/44": {"cloud", "Google Cloud", "us-central1"},
+			},
+		},
+		{
+			name:     "unknown service",
+			paths:    []string{"/cloud.json"},
+			services: []string{"Google Workspace"},
+			want:     map[string][]string{},
+		},
+		{
+			name:   "documents are labelled with their name",
+			paths:  []string{"/cloud.json", "/googlebot.json"},
+			scopes: []string{"africa-south1", ""},
+			want: map[string][]string{
+				"34.1.208.0/20":          {"cloud", "Google Cloud", "africa-south1"},
+				"66.249.64.0/27":         {"googlebot"},
+				"2001:4860:4801:10::/64": {"googlebot"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			server := newTestServer(t, &requests)
+
+			urls := make([]string, len(tt.paths))
+			for i, path := range tt.paths {
+				urls[i] = server.URL + path
+			}
+			options := map[string]interface{}{"urls": urls}
+			if tt.services != nil {
+				options["services"] = tt.services
+			}
+			if tt.scopes != nil {
+				options["scopes"] = tt.scopes
+			}
+			provider := &GCPProvider{}
+			if err := provider.Init(context.Background(), options); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			ipRanges, err := provider.FetchIPRanges(context.Background())
+			if err != nil {
+				t.Fatalf("FetchIPRanges() error = %v", err)
+			}
+
+			got := make(map[string][]string)
+			for _, ipRange := range ipRanges.Ranges {
+				got[ipRange.CIDR] = ipRange.Labels
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FetchIPRanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchIPRangesCache(t *testing.T) {
+	var requests int32
+	server := newTestServer(t, &requests)
+
+	provider := &GCPProvider{}
+	if err := provider.Init(context.Background(), map[string]interface{}{"urls": []string{server.URL + "/cloud.json"}}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	first, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if _, err := provider.FetchIPRanges(context.Background()); err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("fetched %d times within the cache TTL, want 1", requests)
+	}
+
+	// Once the TTL expires an unchanged syncToken reuses the cached set
+	provider.cacheTTL = 0
+	second, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if second != first {
+		t.Error("FetchIPRanges() rebuilt the set for an unchanged document")
+	}
+}
+
+func TestFetchIPRangesError(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+	}{
+		{name: "non-OK status", paths: []string{"/missing.json"}},
+		{name: "invalid JSON", paths: []string{"/broken.json"}},
+		{name: "one of several documents fails", paths: []string{"/cloud.json", "/missing.json"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			server := newTestServer(t, &requests)
+
+			urls := make([]string, len(tt.paths))
+			for i, path := range tt.paths {
+				urls[i] = server.URL + path
+			}
+			provider := &GCPProvider{}
+			if err := provider.Init(context.Background(), map[string]interface{}{"urls": urls}); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+			if _, err := provider.FetchIPRanges(context.Background()); err == nil {
+				t.Error("FetchIPRanges() error = nil, want an error")
+			}
+			if provider.cachedData != nil {
+				t.Error("FetchIPRanges() cached a partial result")
+			}
+		})
+	}
+}
+
+func TestInitInvalidCacheTTL(t *testing.T) {
+	provider := &GCPProvider{}
+	if err := provider.Init(context.Background(), map[string]interface{}{"cacheTTL": "soon"}); err == nil {
+		t.Error("Init() error = nil, want an error for an invalid cacheTTL")
+	}
+}
@@ -0,0 +1,158 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// appTokenRefreshMargin is how long before expiry an installation token is refreshed
+const appTokenRefreshMargin = 5 * time.Minute
+
+// appTokenSource mints GitHub App installation tokens and caches them until shortly before expiry
+type appTokenSource struct {
+	appID      string
+	privateKey *rsa.PrivateKey
+	tokenURL   string
+	httpClient *http.Client
+	token      string
+	expiresAt  time.Time
+	mutex      sync.Mutex
+}
+
+// installationToken represents the response of the installation access token endpoint
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// newAppTokenSource creates a token source from the app ID and a PEM-encoded private key
+func newAppTokenSource(appID string, privateKeyPEM string, tokenURL string, httpClient *http.Client) (*appTokenSource, error) {
+	privateKey, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &appTokenSource{
+		appID:      appID,
+		privateKey: privateKey,
+		tokenURL:   tokenURL,
+		httpClient: httpClient,
+	}, nil
+}
+
+// Token returns a valid installation token, exchanging a fresh JWT when the cached one is about to expire
+func (s *appTokenSource) Token(ctx context.Context) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.token != "" && time.Until(s.expiresAt) > appTokenRefreshMargin {
+		return s.token, nil
+	}
+
+	jwt, err := s.signJWT(time.Now())
+	if err != nil {
+		return "", fmt.Errorf("error signing GitHub App JWT: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.tokenURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting GitHub App installation token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("GitHub App token exchange returned non-OK status: %d, body: %s", resp.StatusCode, body)
+	}
+
+	var token installationToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("error decoding GitHub App installation token: %w", err)
+	}
+	if token.Token == "" {
+		return "", fmt.Errorf("GitHub App token exchange returned an empty token")
+	}
+
+	s.token = token.Token
+	s.expiresAt = token.ExpiresAt
+	log.V(1).Info("Obtained GitHub App installation token", "appID", s.appID, "expiresAt", s.expiresAt)
+
+	return s.token, nil
+}
+
+// Invalidate drops the cached token so the next call to Token exchanges a new one
+func (s *appTokenSource) Invalidate() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.token = ""
+}
+
+// signJWT creates an RS256 JWT identifying the app, backdated to allow for clock drift
+func (s *appTokenSource) signJWT(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-60 * time.Second).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": s.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey parses a PEM-encoded RSA private key in PKCS#1 or PKCS#8 form
+func parsePrivateKey(privateKeyPEM string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("GitHub App private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing GitHub App private key: %w", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("GitHub App private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,159 @@
+package github
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testPrivateKey(t *testing.T) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+func TestAppAuthentication(t *testing.T) {
+	var exchanges int32
+	var revoked atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/installations/42/access_tokens":
+			if r.Method != http.MethodPost {
+				t.Errorf("token exchange method = %s, want POST", r.Method)
+			}
+			jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if parts := strings.Split(jwt, "."); len(parts) != 3 {
+				t.Errorf("token exchange Authorization = %q, want a Bearer JWT", r.Header.Get("Authorization"))
+			}
+			n := atomic.AddInt32(&exchanges, 1)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token": "ghs_%d", "expires_at": %q}`, n, time.Now().Add(time.Hour).Format(time.RFC3339))
+		case "/meta":
+			if revoked.Load() {
+				revoked.Store(false)
+				http.Error(w, "Bad credentials", http.StatusUnauthorized)
+				return
+			}
+			if got := r.Header.Get("Authorization"); !strings.HasPrefix(got, "Bearer ghs_") {
+				t.Errorf("meta Authorization = %q, want an installation token", got)
+			}
+			_, _ = w.Write([]byte(`{"hooks": ["192.30.252.0/22"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := &GitHubProvider{}
+	err := provider.Init(context.Background(), map[string]interface{}{
+		"apiURL":            server.URL + "/meta",
+		"cacheTTL":          "0s",
+		"appID":             "1234",
+		"appPrivateKey":     testPrivateKey(t),
+		"appInstallationID": int64(42),
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	// The installation token is cached across fetches
+	for i := 0; i < 2; i++ {
+		if _, err := provider.FetchIPRanges(context.Background()); err != nil {
+			t.Fatalf("FetchIPRanges() error = %v", err)
+		}
+	}
+	if got := atomic.LoadInt32(&exchanges); got != 1 {
+		t.Errorf("exchanged %d tokens, want 1", got)
+	}
+
+	// A revoked token is exchanged again on the next fetch
+	revoked.Store(true)
+	if _, err := provider.FetchIPRanges(context.Background()); err == nil {
+		t.Error("FetchIPRanges() error = nil, want an error for a revoked token")
+	}
+	if _, err := provider.FetchIPRanges(context.Background()); err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&exchanges); got != 2 {
+		t.Errorf("exchanged %d tokens, want 2", got)
+	}
+}
+
+func TestAppAuthenticationOptions(t *testing.T) {
+	privateKey := testPrivateKey(t)
+
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			options: map[string]interface{}{"appID": "1", "appPrivateKey": privateKey, "appInstallationID": int64(1)},
+		},
+		{
+			name:    "missing installation",
+			options: map[string]interface{}{"appID": "1", "appPrivateKey": privateKey},
+			wantErr: true,
+		},
+		{
+			name:    "key is not PEM",
+			options: map[string]interface{}{"appID": "1", "appPrivateKey": "secret", "appInstallationID": int64(1)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&GitHubProvider{}).Init(context.Background(), tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAppTokenURLOverride(t *testing.T) {
+	var exchanged atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/custom/token" {
+			exchanged.Store(true)
+			fmt.Fprintf(w, `{"token": "ghs_custom", "expires_at": %q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	provider := &GitHubProvider{}
+	err := provider.Init(context.Background(), map[string]interface{}{
+		"apiURL":            "https://ghes.example.com/api/v3/meta",
+		"appID":             "1",
+		"appPrivateKey":     testPrivateKey(t),
+		"appInstallationID": int64(1),
+		"appTokenURL":       server.URL + "/custom/token",
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	token, err := provider.app.Token(context.Background())
+	if err != nil || token != "ghs_custom" {
+		t.Errorf("Token() = %q, %v, want ghs_custom", token, err)
+	}
+	if !exchanged.Load() {
+		t.Error("Token() did not use the appTokenURL override")
+	}
+}
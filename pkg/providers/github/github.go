@@ -6,6 +6,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,21 +24,14 @@ func init() {
 	})
 }
 
-// GitHubIPMetadata represents the structure of GitHub's IP metadata response
-type GitHubIPMetadata struct {
-	Hooks      []string `json:"hooks"`
-	Web        []string `json:"web"`
-	API        []string `json:"api"`
-	Git        []string `json:"git"`
-	Pages      []string `json:"pages"`
-	Importer   []string `json:"importer"`
-	Actions    []string `json:"actions"`
-	Dependabot []string `json:"dependabot"`
-}
+// GitHubIPMetadata represents GitHub's IP metadata response. Keys are decoded dynamically
+// so new categories (e.g. "actions_macos", "copilot") are picked up without code changes.
+type GitHubIPMetadata map[string]json.RawMessage
 
 // GitHubProvider implements the Provider interface for GitHub IP ranges
 type GitHubProvider struct {
 	name        string
+	hostBits    model.HostBitPolicy
 	enterprise  bool
 	apiToken    string
 	cacheTTL    time.Duration
@@ -43,6 +40,13 @@ type GitHubProvider struct {
 	cacheMutex  sync.RWMutex
 	httpClient  *http.Client
 	githubAPIURL string
+	apiVersion  string
+	host        string
+	warnings    []string
+	etag        string
+	lastModified string
+	rateLimitedUntil time.Time
+	app         *appTokenSource
 }
 
 var log = ctrl.Log.WithName("providers.github")
@@ -61,7 +65,7 @@ func (p *GitHubProvider) Type() string {
 func (p *GitHubProvider) Init(ctx context.Context, options map[string]interface{}) error {
 	// Set default values
 	p.githubAPIURL = "https://api.github.com/meta"
-	p.enterprise = true
+	p.apiVersion = "2022-11-28"
 	p.cacheTTL = 15 * time.Minute
 	p.httpClient = &http.Client{
 		Timeout: 30 * time.Second,
@@ -74,20 +78,29 @@ func (p *GitHubProvider) Init(ctx context.Context, options map[string]interface{
 		p.name = "github"
 	}
 
-	if enterprise, ok := options["enterprise"].(bool); ok {
-		p.enterprise = enterprise
+	// Resolve the /meta endpoint from the base URL of github.com, a GHE.com tenant or a GHES instance
+	if baseURL, ok := options["baseURL"].(string); ok && baseURL != "" {
+		apiURL, err := metaURL(baseURL)
+		if err != nil {
+			return err
+		}
+		p.githubAPIURL = apiURL
 	}
 
-	if !p.enterprise {
-		log.Info("Using public GitHub IP ranges", "provider", p.name)
-	} else {
-		log.Info("Using GitHub Enterprise IP ranges", "provider", p.name)
+	if apiVersion, ok := options["apiVersion"].(string); ok && apiVersion != "" {
+		p.apiVersion = apiVersion
 	}
 
 	if apiToken, ok := options["apiToken"].(string); ok {
 		p.apiToken = apiToken
 	}
 
+	hostBits, err := providers.ParseHostBitPolicy(options)
+	if err != nil {
+		return err
+	}
+	p.hostBits = hostBits
+
 	if cacheTTL, ok := options["cacheTTL"].(string); ok {
 		duration, err := time.ParseDuration(cacheTTL)
 		if err != nil {
@@ -100,15 +113,57 @@ func (p *GitHubProvider) Init(ctx context.Context, options map[string]interface{
 		p.githubAPIURL = apiURL
 	}
 
+	// GitHub App authentication takes precedence over a static token
+	if appID, ok := options["appID"].(string); ok && appID != "" {
+		privateKey, _ := options["appPrivateKey"].(string)
+		installationID, _ := options["appInstallationID"].(int64)
+		if installationID == 0 {
+			return fmt.Errorf("appInstallationID is required for GitHub App authentication")
+		}
+
+		tokenURL := fmt.Sprintf("%s/app/installations/%d/access_tokens", strings.TrimSuffix(p.githubAPIURL, "/meta"), installationID)
+		if override, ok := options["appTokenURL"].(string); ok && override != "" {
+			tokenURL = override
+		}
+
+		app, err := newAppTokenSource(appID, privateKey, tokenURL, p.httpClient)
+		if err != nil {
+			return err
+		}
+		p.app = app
+		log.Info("Using GitHub App authentication", "provider", p.name, "appID", appID, "installationID", installationID)
+	}
+
+	// Label ranges with the web host so ranges of several GitHub deployments can be told apart
+	parsedURL, err := url.Parse(p.githubAPIURL)
+	if err != nil {
+		return fmt.Errorf("invalid GitHub API URL: %w", err)
+	}
+	p.host = webHost(parsedURL.Hostname())
+	p.enterprise = p.host != "github.com"
+
 	log.Info("Initialized GitHub provider", 
 		"name", p.name, 
 		"enterprise", p.enterprise, 
+		"host", p.host,
 		"cacheTTL", p.cacheTTL.String(),
 		"apiURL", p.githubAPIURL)
 
 	return nil
 }
 
+// Warnings returns the non-fatal issues found during the last fetch
+func (p *GitHubProvider) Warnings() []string {
+	p.cacheMutex.RLock()
+	defer p.cacheMutex.RUnlock()
+
+	warnings := append([]string{}, p.warnings...)
+	if time.Now().Before(p.rateLimitedUntil) {
+		warnings = append(warnings, fmt.Sprintf("GitHub API rate limit reached, serving cached IP ranges until %s", p.rateLimitedUntil.UTC().Format(time.RFC3339)))
+	}
+	return warnings
+}
+
 // FetchIPRanges fetches IP ranges from GitHub
 func (p *GitHubProvider) FetchIPRanges(ctx context.Context) (*model.IPRangeSet, error) {
 	// Check if we have a valid cache
@@ -130,6 +185,15 @@ func (p *GitHubProvider) FetchIPRanges(ctx context.Context) (*model.IPRangeSet,
 		return p.cachedData, nil
 	}
 
+	// Do not call the API again before the rate limit resets
+	if time.Now().Before(p.rateLimitedUntil) {
+		if p.cachedData != nil {
+			log.V(1).Info("GitHub API rate limited, using cached IP ranges", "provider", p.name, "until", p.rateLimitedUntil)
+			return p.cachedData, nil
+		}
+		return nil, &providers.RateLimitError{Provider: p.name, RetryAt: p.rateLimitedUntil}
+	}
+
 	log.Info("Fetching GitHub IP ranges", "provider", p.name, "url", p.githubAPIURL)
 
 	// Create the request
@@ -138,11 +202,28 @@ func (p *GitHubProvider) FetchIPRanges(ctx context.Context) (*model.IPRangeSet,
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
-	req.Header.Set("Accept", "application/json")
-	if p.apiToken != "" {
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", p.apiVersion)
+	if p.app != nil {
+		token, err := p.app.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if p.apiToken != "" {
 		req.Header.Set("Authorization", "token "+p.apiToken)
 	}
 
+	// Conditional requests answered with 304 do not count against the rate limit
+	if p.cachedData != nil {
+		if p.etag != "" {
+			req.Header.Set("If-None-Match", p.etag)
+		}
+		if p.lastModified != "" {
+			req.Header.Set("If-Modified-Since", p.lastModified)
+		}
+	}
+
 	// Make the request
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
@@ -150,6 +231,29 @@ func (p *GitHubProvider) FetchIPRanges(ctx context.Context) (*model.IPRangeSet,
 	}
 	defer resp.Body.Close()
 
+	if retryAt, limited := rateLimitReset(resp); limited {
+		p.rateLimitedUntil = retryAt
+		log.Info("GitHub API rate limit reached", "provider", p.name, "status", resp.StatusCode, "until", retryAt)
+
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+			if p.cachedData != nil {
+				return p.cachedData, nil
+			}
+			return nil, &providers.RateLimitError{Provider: p.name, RetryAt: retryAt}
+		}
+	}
+
+	if resp.StatusCode == http.StatusNotModified && p.cachedData != nil {
+		log.V(1).Info("GitHub IP ranges not modified", "provider", p.name, "etag", p.etag)
+		p.lastFetch = time.Now()
+		return p.cachedData, nil
+	}
+
+	// A revoked installation token is refreshed on the next fetch
+	if resp.StatusCode == http.StatusUnauthorized && p.app != nil {
+		p.app.Invalidate()
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("GitHub API returned non-OK status: %d, body: %s", resp.StatusCode, body)
@@ -161,75 +265,150 @@ func (p *GitHubProvider) FetchIPRanges(ctx context.Context) (*model.IPRangeSet,
 		return nil, fmt.Errorf("error decoding GitHub IP metadata: %w", err)
 	}
 
-	// Convert to our model
+	// Convert to our model, labelling each range with the key it was listed under
 	ipRangeSet := model.NewIPRangeSet()
+	ipRangeSet.HostBits = p.hostBits
+	categories := make(map[string]int)
+	var ignoredKeys []string
+	addMetadataRanges(ipRangeSet, "", metadata, p.host, categories, &ignoredKeys)
+
+	sort.Strings(ignoredKeys)
+	p.warnings = nil
+	if len(ignoredKeys) > 0 {
+		p.warnings = append(p.warnings, fmt.Sprintf("ignored non-CIDR keys in GitHub metadata: %s", strings.Join(ignoredKeys, ", ")))
+	}
 
-	// Process hooks
-	for _, cidr := range metadata.Hooks {
-		if err := ipRangeSet.Add(cidr, []string{"hooks"}); err != nil {
-			log.Error(err, "Error adding hooks IP range", "cidr", cidr)
-		}
+	log.Info("Successfully fetched GitHub IP ranges", 
+		"provider", p.name, 
+		"count", ipRangeSet.Count(),
+		"categories", categories,
+		"ignoredKeys", ignoredKeys)
+
+	// Update cache
+	p.cachedData = ipRangeSet
+	p.etag = resp.Header.Get("ETag")
+	p.lastModified = resp.Header.Get("Last-Modified")
+	p.lastFetch = time.Now()
+
+	return ipRangeSet, nil
+}
+
+// addMetadataRanges adds every array-of-CIDR key in metadata to the set, labelled with the key.
+// Nested objects such as "domains" are walked with dotted labels (e.g. "domains.actions").
+// Every range is also labelled with the GitHub host. Keys that do not hold CIDRs are recorded in ignoredKeys.
+func addMetadataRanges(ipRangeSet *model.IPRangeSet, prefix string, metadata map[string]json.RawMessage, host string, categories map[string]int, ignoredKeys *[]string) {
+	// Walk keys in order so ranges are always produced in the same order
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	// Process web
-	for _, cidr := range metadata.Web {
-		if err := ipRangeSet.Add(cidr, []string{"web"}); err != nil {
-			log.Error(err, "Error adding web IP range", "cidr", cidr)
+	for _, key := range keys {
+		raw := metadata[key]
+		label := key
+		if prefix != "" {
+			label = prefix + "." + key
 		}
-	}
 
-	// Process API
-	for _, cidr := range metadata.API {
-		if err := ipRangeSet.Add(cidr, []string{"api"}); err != nil {
-			log.Error(err, "Error adding api IP range", "cidr", cidr)
+		var values []string
+		if err := json.Unmarshal(raw, &values); err != nil {
+			var nested map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &nested); err == nil {
+				addMetadataRanges(ipRangeSet, label, nested, host, categories, ignoredKeys)
+				continue
+			}
+			*ignoredKeys = append(*ignoredKeys, label)
+			continue
 		}
-	}
 
-	// Process Git
-	for _, cidr := range metadata.Git {
-		if err := ipRangeSet.Add(cidr, []string{"git"}); err != nil {
-			log.Error(err, "Error adding git IP range", "cidr", cidr)
+		added := 0
+		for _, cidr := range values {
+			if err := ipRangeSet.AddWithSource(cidr, []string{label, host}, model.Provenance{Category: label}); err != nil {
+				continue
+			}
+			added++
 		}
-	}
 
-	// Process Pages
-	for _, cidr := range metadata.Pages {
-		if err := ipRangeSet.Add(cidr, []string{"pages"}); err != nil {
-			log.Error(err, "Error adding pages IP range", "cidr", cidr)
+		if added > 0 {
+			categories[label] = added
+		}
+		if added < len(values) {
+			if added > 0 {
+				log.Info("Skipped invalid CIDRs in GitHub metadata", "key", label, "skipped", len(values)-added)
+			}
+			*ignoredKeys = append(*ignoredKeys, label)
 		}
 	}
+}
 
-	// Process Importer
-	for _, cidr := range metadata.Importer {
-		if err := ipRangeSet.Add(cidr, []string{"importer"}); err != nil {
-			log.Error(err, "Error adding importer IP range", "cidr", cidr)
+// rateLimitReset reports whether the response indicates the rate limit is exhausted and when
+// requests may resume, based on Retry-After (secondary limits) or X-RateLimit-Reset (primary limit)
+func rateLimitReset(resp *http.Response) (time.Time, bool) {
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" &&
+		(resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Now().Add(time.Duration(seconds) * time.Second), true
+		}
+		if date, err := http.ParseTime(retryAfter); err == nil {
+			return date, true
 		}
 	}
 
-	// Process Actions
-	for _, cidr := range metadata.Actions {
-		if err := ipRangeSet.Add(cidr, []string{"actions"}); err != nil {
-			log.Error(err, "Error adding actions IP range", "cidr", cidr)
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return time.Unix(reset, 0), true
 		}
+		return time.Now().Add(time.Minute), true
 	}
 
-	// Process Dependabot
-	for _, cidr := range metadata.Dependabot {
-		if err := ipRangeSet.Add(cidr, []string{"dependabot"}); err != nil {
-			log.Error(err, "Error adding dependabot IP range", "cidr", cidr)
-		}
+	// GitHub recommends waiting at least a minute on a 429 without further hints
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return time.Now().Add(time.Minute), true
 	}
 
-	log.Info("Successfully fetched GitHub IP ranges", 
-		"provider", p.name, 
-		"count", ipRangeSet.Count(),
-		"web", len(metadata.Web),
-		"api", len(metadata.API),
-		"git", len(metadata.Git))
+	return time.Time{}, false
+}
 
-	// Update cache
-	p.cachedData = ipRangeSet
-	p.lastFetch = time.Now()
+// metaURL returns the /meta endpoint for a GitHub base URL:
+//   - https://github.com            -> https://api.github.com/meta
+//   - https://octocorp.ghe.com      -> https://api.octocorp.ghe.com/meta
+//   - https://ghes.example.com      -> https://ghes.example.com/api/v3/meta
+func metaURL(baseURL string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("invalid GitHub base URL '%s'", baseURL)
+	}
+	if parsed.Scheme == "" {
+		parsed.Scheme = "https"
+	}
 
-	return ipRangeSet, nil
+	host := strings.ToLower(parsed.Host)
+	switch {
+	case host == "github.com" || host == "api.github.com":
+		return "https://api.github.com/meta", nil
+	case strings.HasSuffix(host, ".ghe.com"):
+		if !strings.HasPrefix(host, "api.") {
+			parsed.Host = "api." + parsed.Host
+		}
+		parsed.Path = "/meta"
+	case strings.HasSuffix(parsed.Path, "/api/v3"):
+		parsed.Path += "/meta"
+	default:
+		parsed.Path += "/api/v3/meta"
+	}
+
+	return parsed.String(), nil
+}
+
+// webHost returns the user-facing host for an API host, e.g. "github.com" for "api.github.com"
+func webHost(apiHost string) string {
+	host := strings.ToLower(apiHost)
+	if host == "api.github.com" {
+		return "github.com"
+	}
+	if strings.HasSuffix(host, ".ghe.com") {
+		return strings.TrimPrefix(host, "api.")
+	}
+	return host
 }
@@ -0,0 +1,179 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/providers"
+)
+
+const metadata = `{
+	"verifiable_password_authentication": false,
+	"hooks": ["192.30.252.0/22", "2620:112:3000::/44"],
+	"actions_macos": ["13.105.49.0/24"],
+	"domains": {"actions": ["*.actions.githubusercontent.com"], "website": ["github.com"]},
+	"web": ["140.82.112.0/20", "not-a-cidr"]
+}`
+
+func TestMetaURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+		wantErr bool
+	}{
+		{baseURL: "https://github.com", want: "https://api.github.com/meta"},
+		{baseURL: "https://api.github.com/", want: "https://api.github.com/meta"},
+		{baseURL: "https://octocorp.ghe.com", want: "https://api.octocorp.ghe.com/meta"},
+		{baseURL: "https://api.octocorp.ghe.com", want: "https://api.octocorp.ghe.com/meta"},
+		{baseURL: "https://ghes.example.com", want: "https://ghes.example.com/api/v3/meta"},
+		{baseURL: "https://ghes.example.com/api/v3/", want: "https://ghes.example.com/api/v3/meta"},
+		{baseURL: "ghes.example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.baseURL, func(t *testing.T) {
+			got, err := metaURL(tt.baseURL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("metaURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("metaURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchIPRanges(t *testing.T) {
+	var requests, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path != "/api/v3/meta" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "token secret" {
+			t.Errorf("Authorization header = %q, want %q", got, "token secret")
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(metadata))
+	}))
+	defer server.Close()
+
+	provider := &GitHubProvider{}
+	err := provider.Init(context.Background(), map[string]interface{}{
+		"baseURL":  server.URL,
+		"apiToken": "secret",
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	first, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	host = host[:strings.LastIndex(host, ":")]
+	want := map[string][]string{
+		"13.105.49.0/24":     {"actions_macos", host},
+		"192.30.252.0/22":    {"hooks", host},
+		"2620:112:3000::/44": {"hooks", host},
+		"140.82.112.0/20":    {"web", host},
+	}
+	got := make(map[string][]string)
+	for _, ipRange := range first.Ranges {
+		got[ipRange.CIDR] = ipRange.Labels
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FetchIPRanges() = %v, want %v", got, want)
+	}
+
+	wantWarnings := []string{"ignored non-CIDR keys in GitHub metadata: domains.actions, domains.website, verifiable_password_authentication, web"}
+	if warnings := provider.Warnings(); !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Errorf("Warnings() = %v, want %v", warnings, wantWarnings)
+	}
+
+	// Once the TTL expires the ETag is sent back and a 304 reuses the cached set
+	provider.cacheTTL = 0
+	second, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if atomic.LoadInt32(&requests) != 2 || atomic.LoadInt32(&notModified) != 1 {
+		t.Errorf("made %d requests with %d not modified, want 2 with 1 not modified", requests, notModified)
+	}
+	if second != first {
+		t.Error("FetchIPRanges() rebuilt the set for a 304 response")
+	}
+}
+
+func TestFetchIPRangesRateLimited(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	var limited atomic.Bool
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if limited.Load() {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			http.Error(w, "API rate limit exceeded", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(metadata))
+	}))
+	defer server.Close()
+
+	newProvider := func() *GitHubProvider {
+		provider := &GitHubProvider{}
+		if err := provider.Init(context.Background(), map[string]interface{}{"apiURL": server.URL + "/meta", "cacheTTL": "0s"}); err != nil {
+			t.Fatalf("Init() error = %v", err)
+		}
+		return provider
+	}
+
+	// Without cached ranges the rate limit is surfaced so the controller can requeue
+	limited.Store(true)
+	provider := newProvider()
+	_, err := provider.FetchIPRanges(context.Background())
+	rateLimitErr, ok := providers.IsRateLimited(err)
+	if !ok {
+		t.Fatalf("FetchIPRanges() error = %v, want a RateLimitError", err)
+	}
+	if !rateLimitErr.RetryAt.Equal(reset) {
+		t.Errorf("RetryAt = %s, want %s", rateLimitErr.RetryAt, reset)
+	}
+
+	// With cached ranges they are served until the limit resets, without calling the API again
+	limited.Store(false)
+	provider = newProvider()
+	cached, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	limited.Store(true)
+	for i := 0; i < 2; i++ {
+		got, err := provider.FetchIPRanges(context.Background())
+		if err != nil || got != cached {
+			t.Errorf("FetchIPRanges() = %p, %v, want the cached set", got, err)
+		}
+	}
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("made %d requests, want 3", got)
+	}
+	if warnings := provider.Warnings(); len(warnings) == 0 || !strings.Contains(warnings[len(warnings)-1], "rate limit") {
+		t.Errorf("Warnings() = %v, want a rate limit warning", warnings)
+	}
+}
@@ -0,0 +1,261 @@
+package httpjson
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/providers"
+	"k8s.io/client-go/util/jsonpath"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func init() {
+	providers.Register("http", func() providers.Provider {
+		return &HTTPProvider{}
+	})
+}
+
+// rangeMapping maps the results of a JSONPath expression to a label
+type rangeMapping struct {
+	expression string
+	label      string
+	path       *jsonpath.JSONPath
+}
+
+// HTTPProvider implements the Provider interface for arbitrary HTTP/JSON endpoints
+type HTTPProvider struct {
+	name       string
+	hostBits   model.HostBitPolicy
+	url        string
+	headers    map[string]string
+	mappings   []rangeMapping
+	cacheTTL   time.Duration
+	lastFetch  time.Time
+	cachedData *model.IPRangeSet
+	cacheMutex sync.RWMutex
+	httpClient *http.Client
+}
+
+var log = ctrl.Log.WithName("providers.http")
+
+// Name returns the provider name
+func (p *HTTPProvider) Name() string {
+	return p.name
+}
+
+// Type returns the provider type
+func (p *HTTPProvider) Type() string {
+	return "http"
+}
+
+// Init initializes the HTTP provider with options
+func (p *HTTPProvider) Init(ctx context.Context, options map[string]interface{}) error {
+	// Set default values
+	p.cacheTTL = 15 * time.Minute
+	p.headers = make(map[string]string)
+	p.httpClient = &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	// Process options
+	if name, ok := options["name"].(string); ok {
+		p.name = name
+	} else {
+		p.name = "http"
+	}
+
+	if url, ok := options["url"].(string); ok && url != "" {
+		p.url = url
+	} else {
+		return fmt.Errorf("url is required")
+	}
+
+	if headers, ok := options["headers"].(map[string]string); ok {
+		for k, v := range headers {
+			p.headers[k] = v
+		}
+	}
+
+	// The auth header is resolved from a secret by the controller and overrides static headers
+	if authHeader, ok := options["authHeader"].(string); ok && authHeader != "" {
+		if authValue, ok := options["authValue"].(string); ok {
+			p.headers[authHeader] = authValue
+		}
+	}
+
+	// Each mapping is a path and a label; mappings are applied in the order given
+	mappings, ok := options["mappings"].([]map[string]string)
+	if !ok || len(mappings) == 0 {
+		return fmt.Errorf("at least one mapping is required")
+	}
+
+	p.mappings = nil
+	for _, mapping := range mappings {
+		expression := mapping["path"]
+		if expression == "" {
+			return fmt.Errorf("mapping path is required")
+		}
+		path := jsonpath.New(expression).AllowMissingKeys(true)
+		if err := path.Parse(toTemplate(expression)); err != nil {
+			return fmt.Errorf("invalid JSONPath expression '%s': %w", expression, err)
+		}
+		p.mappings = append(p.mappings, rangeMapping{
+			expression: expression,
+			label:      mapping["label"],
+			path:       path,
+		})
+	}
+
+	hostBits, err := providers.ParseHostBitPolicy(options)
+	if err != nil {
+		return err
+	}
+	p.hostBits = hostBits
+
+	if cacheTTL, ok := options["cacheTTL"].(string); ok {
+		duration, err := time.ParseDuration(cacheTTL)
+		if err != nil {
+			return fmt.Errorf("invalid cacheTTL format: %w", err)
+		}
+		p.cacheTTL = duration
+	}
+
+	log.Info("Initialized HTTP provider",
+		"name", p.name,
+		"url", p.url,
+		"mappings", len(p.mappings),
+		"cacheTTL", p.cacheTTL.String())
+
+	return nil
+}
+
+// FetchIPRanges fetches the JSON document and extracts IP ranges with the configured mappings
+func (p *HTTPProvider) FetchIPRanges(ctx context.Context) (*model.IPRangeSet, error) {
+	// Check if we have a valid cache
+	p.cacheMutex.RLock()
+	if p.cachedData != nil && time.Since(p.lastFetch) < p.cacheTTL {
+		defer p.cacheMutex.RUnlock()
+		log.V(1).Info("Using cached HTTP IP ranges", "provider", p.name, "age", time.Since(p.lastFetch).String())
+		return p.cachedData, nil
+	}
+	p.cacheMutex.RUnlock()
+
+	// Lock for writing
+	p.cacheMutex.Lock()
+	defer p.cacheMutex.Unlock()
+
+	// Double-check cache under write lock
+	if p.cachedData != nil && time.Since(p.lastFetch) < p.cacheTTL {
+		log.V(1).Info("Using cached HTTP IP ranges (verified under lock)", "provider", p.name)
+		return p.cachedData, nil
+	}
+
+	log.Info("Fetching HTTP IP ranges", "provider", p.name, "url", p.url)
+
+	// Create the request
+	req, err := http.NewRequestWithContext(ctx, "GET", p.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+
+	// Make the request
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching IP ranges: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("endpoint returned non-OK status: %d, body: %s", resp.StatusCode, body)
+	}
+
+	// Read and parse the response
+	var document interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, fmt.Errorf("error decoding JSON document: %w", err)
+	}
+
+	// Convert to our model
+	ipRangeSet := model.NewIPRangeSet()
+	ipRangeSet.HostBits = p.hostBits
+	for _, mapping := range p.mappings {
+		results, err := mapping.path.FindResults(document)
+		if err != nil {
+			return nil, fmt.Errorf("error evaluating JSONPath expression '%s': %w", mapping.expression, err)
+		}
+
+		count := 0
+		for _, cidr := range collectStrings(results) {
+			if err := ipRangeSet.Add(cidr, []string{mapping.label}); err != nil {
+				log.Error(err, "Error adding IP range", "cidr", cidr, "expression", mapping.expression)
+				continue
+			}
+			count++
+		}
+
+		log.V(1).Info("Extracted IP ranges", "provider", p.name, "expression", mapping.expression, "label", mapping.label, "count", count)
+	}
+
+	log.Info("Successfully fetched HTTP IP ranges", "provider", p.name, "count", ipRangeSet.Count())
+
+	// Update cache
+	p.cachedData = ipRangeSet
+	p.lastFetch = time.Now()
+
+	return ipRangeSet, nil
+}
+
+// toTemplate wraps a bare JSONPath expression such as "$.hooks[*]" in the braces expected by the parser
+func toTemplate(expression string) string {
+	expression = strings.TrimSpace(expression)
+	if strings.HasPrefix(expression, "{") {
+		return expression
+	}
+	return "{" + expression + "}"
+}
+
+// collectStrings flattens JSONPath results into the string values they contain
+func collectStrings(results [][]reflect.Value) []string {
+	var values []string
+
+	var collect func(value reflect.Value)
+	collect = func(value reflect.Value) {
+		for value.Kind() == reflect.Interface || value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return
+			}
+			value = value.Elem()
+		}
+
+		switch value.Kind() {
+		case reflect.String:
+			values = append(values, value.String())
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < value.Len(); i++ {
+				collect(value.Index(i))
+			}
+		}
+	}
+
+	for _, result := range results {
+		for _, value := range result {
+			collect(value)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,131 @@
+package httpjson
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+const document = `{
+	"hooks": ["192.0.2.0/24", "2001:db8::/32"],
+	"web": {"ranges": [{"cidr": "198.51.100.0/24"}, {"cidr": "not-a-cidr"}]}
+}`
+
+func TestFetchIPRanges(t *testing.T) {
+	var gotHeaders http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(document))
+	}))
+	defer server.Close()
+
+	provider := &HTTPProvider{}
+	err := provider.Init(context.Background(), map[string]interface{}{
+		"name":       "test",
+		"url":        server.URL,
+		"headers":    map[string]string{"X-Static": "static"},
+		"authHeader": "Authorization",
+		"authValue":  "Bearer secret",
+		"mappings": []map[string]string{
+			{"path": "$.hooks[*]", "label": "hooks"},
+			// The same path with another label must not replace the first mapping
+			{"path": "$.hooks[*]", "label": "webhooks"},
+			{"path": "{.web.ranges[*].cidr}", "label": "web"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	ipRanges, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+
+	if got := gotHeaders.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization header = %q, want %q", got, "Bearer secret")
+	}
+	if got := gotHeaders.Get("X-Static"); got != "static" {
+		t.Errorf("X-Static header = %q, want %q", got, "static")
+	}
+
+	want := map[string][]string{
+		"192.0.2.0/24":    {"hooks", "webhooks"},
+		"2001:db8::/32":   {"hooks", "webhooks"},
+		"198.51.100.0/24": {"web"},
+	}
+	got := make(map[string][]string)
+	for _, ipRange := range ipRanges.Ranges {
+		labels := append([]string(nil), ipRange.Labels...)
+		sort.Strings(labels)
+		got[ipRange.CIDR] = labels
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FetchIPRanges() = %v, want %v", got, want)
+	}
+}
+
+func TestFetchIPRangesError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	provider := &HTTPProvider{}
+	err := provider.Init(context.Background(), map[string]interface{}{
+		"url":      server.URL,
+		"mappings": []map[string]string{{"path": "$.hooks[*]", "label": "hooks"}},
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	if _, err := provider.FetchIPRanges(context.Background()); err == nil {
+		t.Fatal("FetchIPRanges() error = nil, want an error for a non-OK status")
+	}
+}
+
+func TestInitValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+	}{
+		{
+			name:    "missing url",
+			options: map[string]interface{}{"mappings": []map[string]string{{"path": "$.a", "label": "a"}}},
+		},
+		{
+			name:    "missing mappings",
+			options: map[string]interface{}{"url": "http://example.com"},
+		},
+		{
+			name:    "empty path",
+			options: map[string]interface{}{"url": "http://example.com", "mappings": []map[string]string{{"label": "a"}}},
+		},
+		{
+			name:    "invalid path",
+			options: map[string]interface{}{"url": "http://example.com", "mappings": []map[string]string{{"path": "$.a[", "label": "a"}}},
+		},
+		{
+			name: "invalid cacheTTL",
+			options: map[string]interface{}{
+				"url":      "http://example.com",
+				"mappings": []map[string]string{{"path": "$.a", "label": "a"}},
+				"cacheTTL": "soon",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &HTTPProvider{}
+			if err := provider.Init(context.Background(), tt.options); err == nil {
+				t.Error("Init() error = nil, want an error")
+			}
+		})
+	}
+}
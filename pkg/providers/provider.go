@@ -2,6 +2,10 @@ package providers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 	
 	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -22,6 +26,56 @@ type Provider interface {
 	FetchIPRanges(ctx context.Context) (*model.IPRangeSet, error)
 }
 
+// StatusReporter is implemented by providers that report non-fatal issues about their last fetch
+type StatusReporter interface {
+	// Warnings returns the issues found during the last fetch, e.g. ignored metadata keys
+	Warnings() []string
+}
+
+// RateLimitError is returned by providers when the upstream API rejects requests until RetryAt
+type RateLimitError struct {
+	Provider string
+	RetryAt  time.Time
+}
+
+// Error implements the error interface
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("provider %s is rate limited until %s", e.Provider, e.RetryAt.UTC().Format(time.RFC3339))
+}
+
+// IsRateLimited returns the RateLimitError wrapped in err, if any
+func IsRateLimited(err error) (*RateLimitError, bool) {
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return rateLimitErr, true
+	}
+	return nil, false
+}
+
+// ContainsFold reports whether values contains value, ignoring case. Providers use it to match
+// configured service, region and scope filters against published names.
+func ContainsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseHostBitPolicy returns the policy for CIDRs with host bits set named by the "hostBits"
+// option: "Mask" (the default) stores them with the host bits cleared, "Reject" drops them
+func ParseHostBitPolicy(options map[string]interface{}) (model.HostBitPolicy, error) {
+	value, _ := options["hostBits"].(string)
+	switch value {
+	case "", "Mask":
+		return model.HostBitsMask, nil
+	case "Reject":
+		return model.HostBitsReject, nil
+	}
+	return model.HostBitsMask, fmt.Errorf("invalid hostBits '%s', expected Mask or Reject", value)
+}
+
 var log = ctrl.Log.WithName("providers")
 
 // Registry is a registry of available providers
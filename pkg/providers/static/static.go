@@ -0,0 +1,151 @@
+package static
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/providers"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func init() {
+	providers.Register("static", func() providers.Provider {
+		return &StaticProvider{}
+	})
+}
+
+// source is an external list of CIDRs, such as a ConfigMap or Secret key
+type source struct {
+	description string
+	loader      func(ctx context.Context) (string, error)
+	labels      []string
+}
+
+// StaticProvider implements the Provider interface for inline CIDRs and CIDRs stored in ConfigMaps and Secrets
+type StaticProvider struct {
+	name     string
+	hostBits model.HostBitPolicy
+	ranges   []model.IPRange
+	sources  []source
+}
+
+var log = ctrl.Log.WithName("providers.static")
+
+// Name returns the provider name
+func (p *StaticProvider) Name() string {
+	return p.name
+}
+
+// Type returns the provider type
+func (p *StaticProvider) Type() string {
+	return "static"
+}
+
+// Init initializes the static provider with options
+func (p *StaticProvider) Init(ctx context.Context, options map[string]interface{}) error {
+	// Process options
+	if name, ok := options["name"].(string); ok {
+		p.name = name
+	} else {
+		p.name = "static"
+	}
+
+	hostBits, err := providers.ParseHostBitPolicy(options)
+	if err != nil {
+		return err
+	}
+	p.hostBits = hostBits
+
+	// Validate inline ranges up front so a typo is reported when the provider is created
+	p.ranges = nil
+	if ranges, ok := options["ranges"].([]model.IPRange); ok {
+		validated := model.NewIPRangeSet()
+		validated.HostBits = p.hostBits
+		for _, ipRange := range ranges {
+			if err := validated.AddIPRange(ipRange); err != nil {
+				return fmt.Errorf("invalid inline range: %w", err)
+			}
+		}
+		p.ranges = validated.Ranges
+	}
+
+	p.sources = nil
+	if sources, ok := options["sources"].([]map[string]interface{}); ok {
+		for i, s := range sources {
+			loader, ok := s["loader"].(func(ctx context.Context) (string, error))
+			if !ok {
+				return fmt.Errorf("source %d has no loader", i)
+			}
+			description, _ := s["description"].(string)
+			labels, _ := s["labels"].([]string)
+			p.sources = append(p.sources, source{
+				description: description,
+				loader:      loader,
+				labels:      labels,
+			})
+		}
+	}
+
+	if len(p.ranges) == 0 && len(p.sources) == 0 {
+		return fmt.Errorf("at least one inline range or source is required")
+	}
+
+	log.Info("Initialized static provider",
+		"name", p.name,
+		"ranges", len(p.ranges),
+		"sources", len(p.sources))
+
+	return nil
+}
+
+// FetchIPRanges returns the inline ranges merged with the current content of every source.
+// Sources are read on every call so ConfigMap and Secret edits take effect on the next reconcile.
+func (p *StaticProvider) FetchIPRanges(ctx context.Context) (*model.IPRangeSet, error) {
+	ipRangeSet := model.NewIPRangeSet()
+	ipRangeSet.HostBits = p.hostBits
+	ipRangeSet.Ranges = append(ipRangeSet.Ranges, p.ranges...)
+
+	for _, s := range p.sources {
+		content, err := s.loader(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", s.description, err)
+		}
+
+		if err := parseContent(ipRangeSet, content, s.labels); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", s.description, err)
+		}
+	}
+
+	log.V(1).Info("Built static IP ranges", "provider", p.name, "count", ipRangeSet.Count())
+
+	return ipRangeSet, nil
+}
+
+// parseContent adds the CIDRs of a source to the set. Each line holds a CIDR optionally
+// followed by whitespace-separated labels; "#" starts a comment.
+func parseContent(ipRangeSet *model.IPRangeSet, content string, labels []string) error {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		rangeLabels := append(append([]string{}, labels...), fields[1:]...)
+		if err := ipRangeSet.Add(fields[0], rangeLabels); err != nil {
+			log.Error(err, "Error adding static IP range", "line", lineNumber)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,159 @@
+package static
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+)
+
+func TestParseContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		labels  []string
+		want    map[string][]string
+	}{
+		{
+			name:    "CIDRs with labels and comments",
+			content: "# office egress\n192.0.2.0/24 office berlin\n\n   198.51.100.7/32   # VPN gateway\n2001:db8::/32\tipv6 # trailing\n",
+			want: map[string][]string{
+				"192.0.2.0/24":    {"office", "berlin"},
+				"198.51.100.7/32": nil,
+				"2001:db8::/32":   {"ipv6"},
+			},
+		},
+		{
+			name:    "source labels come first",
+			content: "192.0.2.0/24 berlin\n198.51.100.0/24\n",
+			labels:  []string{"configmap"},
+			want: map[string][]string{
+				"192.0.2.0/24":    {"configmap", "berlin"},
+				"198.51.100.0/24": {"configmap"},
+			},
+		},
+		{
+			name:    "bad lines are skipped",
+			content: "office 192.0.2.0/24\n10.0.0.0/33\n198.51.100.0/24\n#192.0.2.128/25\n",
+			want: map[string][]string{
+				"198.51.100.0/24": nil,
+			},
+		},
+		{
+			name:    "only comments",
+			content: "# nothing yet\n\n",
+			want:    map[string][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipRanges := model.NewIPRangeSet()
+			if err := parseContent(ipRanges, tt.content, tt.labels); err != nil {
+				t.Fatalf("parseContent() error = %v", err)
+			}
+
+			got := make(map[string][]string)
+			for _, ipRange := range ipRanges.Ranges {
+				if len(ipRange.Labels) == 0 {
+					got[ipRange.CIDR] = nil
+				} else {
+					got[ipRange.CIDR] = ipRange.Labels
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseContent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchIPRanges(t *testing.T) {
+	content := "198.51.100.0/24 vpn\n"
+	provider := &StaticProvider{}
+	err := provider.Init(context.Background(), map[string]interface{}{
+		"ranges": []model.IPRange{{CIDR: "192.0.2.0/24", Labels: []string{"office"}}},
+		"sources": []map[string]interface{}{
+			{
+				"description": "ConfigMap default/vpn-ranges",
+				"loader": func(ctx context.Context) (string, error) {
+					return content, nil
+				},
+				"labels": []string{"configmap"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	ipRanges, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if got, want := ipRanges.GetCIDRs(), []string{"192.0.2.0/24", "198.51.100.0/24"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FetchIPRanges() = %v, want %v", got, want)
+	}
+
+	// Sources are read again on every fetch
+	content = "203.0.113.0/24\n"
+	ipRanges, err = provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if got, want := ipRanges.GetCIDRs(), []string{"192.0.2.0/24", "203.0.113.0/24"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FetchIPRanges() after an edit = %v, want %v", got, want)
+	}
+}
+
+func TestFetchIPRangesLoaderError(t *testing.T) {
+	provider := &StaticProvider{}
+	err := provider.Init(context.Background(), map[string]interface{}{
+		"sources": []map[string]interface{}{
+			{
+				"description": "Secret default/vpn-ranges",
+				"loader": func(ctx context.Context) (string, error) {
+					return "", errors.New("secret not found")
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	if _, err := provider.FetchIPRanges(context.Background()); err == nil {
+		t.Error("FetchIPRanges() error = nil, want an error for a failing source")
+	}
+}
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+	}{
+		{
+			name:    "nothing configured",
+			options: map[string]interface{}{},
+		},
+		{
+			name:    "invalid inline range",
+			options: map[string]interface{}{"ranges": []model.IPRange{{CIDR: "192.0.2.0/33"}}},
+		},
+		{
+			name:    "source without a loader",
+			options: map[string]interface{}{"sources": []map[string]interface{}{{"description": "ConfigMap default/empty"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &StaticProvider{}
+			if err := provider.Init(context.Background(), tt.options); err == nil {
+				t.Error("Init() error = nil, want an error")
+			}
+		})
+	}
+}
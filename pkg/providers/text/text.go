@@ -0,0 +1,300 @@
+package text
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/model"
+	"github.com/galbakal/k8s-ingress-meta-sync/pkg/providers"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func init() {
+	providers.Register("text", func() providers.Provider {
+		return &TextProvider{}
+	})
+}
+
+// ContentLoader loads the raw list content, e.g. from a ConfigMap key
+type ContentLoader func(ctx context.Context) (string, error)
+
+// TextProvider implements the Provider interface for plain-text and CSV lists
+type TextProvider struct {
+	name          string
+	hostBits      model.HostBitPolicy
+	url           string
+	loader        ContentLoader
+	format        string
+	commentPrefix string
+	cidrColumn    int
+	labelColumns  []int
+	labels        []string
+	cacheTTL      time.Duration
+	lastFetch     time.Time
+	cachedData    *model.IPRangeSet
+	cacheMutex    sync.RWMutex
+	httpClient    *http.Client
+}
+
+var log = ctrl.Log.WithName("providers.text")
+
+// Name returns the provider name
+func (p *TextProvider) Name() string {
+	return p.name
+}
+
+// Type returns the provider type
+func (p *TextProvider) Type() string {
+	return "text"
+}
+
+// Init initializes the text provider with options
+func (p *TextProvider) Init(ctx context.Context, options map[string]interface{}) error {
+	// Set default values
+	p.format = "lines"
+	p.commentPrefix = "#"
+	p.cacheTTL = 15 * time.Minute
+	p.httpClient = &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	// Process options
+	if name, ok := options["name"].(string); ok {
+		p.name = name
+	} else {
+		p.name = "text"
+	}
+
+	if url, ok := options["url"].(string); ok {
+		p.url = url
+	}
+
+	if loader, ok := options["contentLoader"].(func(ctx context.Context) (string, error)); ok {
+		p.loader = loader
+	}
+
+	if p.url == "" && p.loader == nil {
+		return fmt.Errorf("either url or a ConfigMap reference is required")
+	}
+
+	if format, ok := options["format"].(string); ok && format != "" {
+		p.format = format
+	}
+
+	switch p.format {
+	case "lines", "csv":
+	case "geofeed":
+		// RFC 8805: ip_prefix,alpha2code,region,city,postal_code
+		p.labelColumns = []int{1, 2, 3}
+	default:
+		return fmt.Errorf("unsupported format: %s", p.format)
+	}
+
+	if commentPrefix, ok := options["commentPrefix"].(string); ok && commentPrefix != "" {
+		p.commentPrefix = commentPrefix
+	}
+
+	if cidrColumn, ok := options["cidrColumn"].(int); ok {
+		p.cidrColumn = cidrColumn
+	}
+
+	if labelColumns, ok := options["labelColumns"].([]int); ok && len(labelColumns) > 0 {
+		p.labelColumns = labelColumns
+	}
+
+	if labels, ok := options["labels"].([]string); ok {
+		p.labels = labels
+	}
+
+	hostBits, err := providers.ParseHostBitPolicy(options)
+	if err != nil {
+		return err
+	}
+	p.hostBits = hostBits
+
+	if cacheTTL, ok := options["cacheTTL"].(string); ok {
+		duration, err := time.ParseDuration(cacheTTL)
+		if err != nil {
+			return fmt.Errorf("invalid cacheTTL format: %w", err)
+		}
+		p.cacheTTL = duration
+	}
+
+	log.Info("Initialized text provider",
+		"name", p.name,
+		"url", p.url,
+		"format", p.format,
+		"cacheTTL", p.cacheTTL.String())
+
+	return nil
+}
+
+// FetchIPRanges fetches and parses the list. Content from a loader, such as a ConfigMap key, is
+// read on every call so edits take effect on the next reconcile; lists fetched by URL are cached.
+func (p *TextProvider) FetchIPRanges(ctx context.Context) (*model.IPRangeSet, error) {
+	if p.loader != nil {
+		ipRangeSet, err := p.fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		log.V(1).Info("Loaded text IP ranges", "provider", p.name, "count", ipRangeSet.Count())
+		return ipRangeSet, nil
+	}
+
+	// Check if we have a valid cache
+	p.cacheMutex.RLock()
+	if p.cachedData != nil && time.Since(p.lastFetch) < p.cacheTTL {
+		defer p.cacheMutex.RUnlock()
+		log.V(1).Info("Using cached text IP ranges", "provider", p.name, "age", time.Since(p.lastFetch).String())
+		return p.cachedData, nil
+	}
+	p.cacheMutex.RUnlock()
+
+	// Lock for writing
+	p.cacheMutex.Lock()
+	defer p.cacheMutex.Unlock()
+
+	// Double-check cache under write lock
+	if p.cachedData != nil && time.Since(p.lastFetch) < p.cacheTTL {
+		log.V(1).Info("Using cached text IP ranges (verified under lock)", "provider", p.name)
+		return p.cachedData, nil
+	}
+
+	ipRangeSet, err := p.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Successfully fetched text IP ranges", "provider", p.name, "count", ipRangeSet.Count())
+
+	// Update cache
+	p.cachedData = ipRangeSet
+	p.lastFetch = time.Now()
+
+	return ipRangeSet, nil
+}
+
+// fetch reads and parses the current list content
+func (p *TextProvider) fetch(ctx context.Context) (*model.IPRangeSet, error) {
+	content, err := p.readContent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.parse(content)
+}
+
+// readContent reads the raw list from the content loader or the configured URL
+func (p *TextProvider) readContent(ctx context.Context) (string, error) {
+	if p.loader != nil {
+		content, err := p.loader(ctx)
+		if err != nil {
+			return "", fmt.Errorf("error loading list content: %w", err)
+		}
+		return content, nil
+	}
+
+	log.Info("Fetching text IP ranges", "provider", p.name, "url", p.url)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.url, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error fetching text IP ranges: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("endpoint returned non-OK status: %d, body: %s", resp.StatusCode, body)
+	}
+
+	return string(body), nil
+}
+
+// parse turns the list content into an IPRangeSet according to the configured format
+func (p *TextProvider) parse(content string) (*model.IPRangeSet, error) {
+	ipRangeSet := model.NewIPRangeSet()
+	ipRangeSet.HostBits = p.hostBits
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		// Strip comments and surrounding whitespace
+		line := scanner.Text()
+		if idx := strings.Index(line, p.commentPrefix); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if p.format == "lines" {
+			if err := ipRangeSet.Add(line, p.labels); err != nil {
+				log.Error(err, "Error adding IP range", "line", lineNumber)
+			}
+			continue
+		}
+
+		fields, err := parseCSVLine(line)
+		if err != nil {
+			log.Error(err, "Error parsing CSV line", "line", lineNumber)
+			continue
+		}
+
+		if p.cidrColumn >= len(fields) {
+			log.Error(fmt.Errorf("column %d out of range", p.cidrColumn), "Error reading CIDR column", "line", lineNumber)
+			continue
+		}
+
+		labels := append([]string{}, p.labels...)
+		for _, column := range p.labelColumns {
+			if column < len(fields) && fields[column] != "" {
+				labels = append(labels, fields[column])
+			}
+		}
+
+		if err := ipRangeSet.Add(fields[p.cidrColumn], labels); err != nil {
+			log.Error(err, "Error adding IP range", "line", lineNumber)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading list content: %w", err)
+	}
+
+	return ipRangeSet, nil
+}
+
+// parseCSVLine splits a single CSV record, honouring quoted fields
+func parseCSVLine(line string) ([]string, error) {
+	reader := csv.NewReader(strings.NewReader(line))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	fields, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields, nil
+}
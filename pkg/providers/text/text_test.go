@@ -0,0 +1,227 @@
+package text
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		content string
+		want    map[string][]string
+	}{
+		{
+			name:    "lines with comments and blank lines",
+			options: map[string]interface{}{"labels": []string{"office"}},
+			content: "# office egress\n192.0.2.0/24\n\n  198.51.100.7/32  # VPN\n2001:db8::/32\n",
+			want: map[string][]string{
+				"192.0.2.0/24":    {"office"},
+				"198.51.100.7/32": {"office"},
+				"2001:db8::/32":   {"office"},
+			},
+		},
+		{
+			name:    "bad lines are skipped",
+			content: "192.0.2.0/24\nnot-a-cidr\n10.0.0.0/33\n198.51.100.0/24\n",
+			want: map[string][]string{
+				"192.0.2.0/24":    nil,
+				"198.51.100.0/24": nil,
+			},
+		},
+		{
+			name:    "custom comment prefix",
+			options: map[string]interface{}{"commentPrefix": ";"},
+			content: "; header\n192.0.2.0/24 ; office\n",
+			want: map[string][]string{
+				"192.0.2.0/24": nil,
+			},
+		},
+		{
+			name: "CSV columns",
+			options: map[string]interface{}{
+				"format":       "csv",
+				"cidrColumn":   1,
+				"labelColumns": []int{0, 2, 5},
+			},
+			content: "office,192.0.2.0/24,\"Berlin, DE\"\nvpn, 198.51.100.0/24 ,\nshort\n\"unterminated,192.0.2.1/32\n",
+			want: map[string][]string{
+				"192.0.2.0/24":    {"office", "Berlin, DE"},
+				"198.51.100.0/24": {"vpn"},
+			},
+		},
+		{
+			name:    "geofeed",
+			options: map[string]interface{}{"format": "geofeed", "labels": []string{"geofeed"}},
+			content: "# ip_prefix,alpha2code,region,city,postal_code\n192.0.2.0/24,US,US-CA,San Francisco,94107\n2001:db8::/32,DE,,,\nbogus,FR,FR-75,Paris,\n",
+			want: map[string][]string{
+				"192.0.2.0/24":  {"geofeed", "US", "US-CA", "San Francisco"},
+				"2001:db8::/32": {"geofeed", "DE"},
+			},
+		},
+		{
+			name:    "geofeed with explicit label columns",
+			options: map[string]interface{}{"format": "geofeed", "labelColumns": []int{4}},
+			content: "192.0.2.0/24,US,US-CA,San Francisco,94107\n",
+			want: map[string][]string{
+				"192.0.2.0/24": {"94107"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := map[string]interface{}{
+				"contentLoader": func(ctx context.Context) (string, error) {
+					return tt.content, nil
+				},
+			}
+			for key, value := range tt.options {
+				options[key] = value
+			}
+			provider := &TextProvider{}
+			if err := provider.Init(context.Background(), options); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			ipRanges, err := provider.FetchIPRanges(context.Background())
+			if err != nil {
+				t.Fatalf("FetchIPRanges() error = %v", err)
+			}
+
+			got := make(map[string][]string)
+			for _, ipRange := range ipRanges.Ranges {
+				if len(ipRange.Labels) == 0 {
+					got[ipRange.CIDR] = nil
+				} else {
+					got[ipRange.CIDR] = ipRange.Labels
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FetchIPRanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchIPRangesFromURL(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte("192.0.2.0/24\n198.51.100.0/24\n"))
+	}))
+	defer server.Close()
+
+	provider := &TextProvider{}
+	if err := provider.Init(context.Background(), map[string]interface{}{"url": server.URL}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		ipRanges, err := provider.FetchIPRanges(context.Background())
+		if err != nil {
+			t.Fatalf("FetchIPRanges() error = %v", err)
+		}
+		if got, want := ipRanges.GetCIDRs(), []string{"192.0.2.0/24", "198.51.100.0/24"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("FetchIPRanges() = %v, want %v", got, want)
+		}
+	}
+	if atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("fetched %d times within the cache TTL, want 1", requests)
+	}
+}
+
+func TestFetchIPRangesError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+	}{
+		{
+			name:    "non-OK status",
+			options: map[string]interface{}{"url": server.URL},
+		},
+		{
+			name: "loader error",
+			options: map[string]interface{}{
+				"contentLoader": func(ctx context.Context) (string, error) {
+					return "", errors.New("configmap not found")
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &TextProvider{}
+			if err := provider.Init(context.Background(), tt.options); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+			if _, err := provider.FetchIPRanges(context.Background()); err == nil {
+				t.Error("FetchIPRanges() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+	}{
+		{name: "neither url nor ConfigMap", options: map[string]interface{}{}},
+		{name: "unsupported format", options: map[string]interface{}{"url": "https://example.com/ranges.txt", "format": "xml"}},
+		{name: "invalid cacheTTL", options: map[string]interface{}{"url": "https://example.com/ranges.txt", "cacheTTL": "often"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &TextProvider{}
+			if err := provider.Init(context.Background(), tt.options); err == nil {
+				t.Error("Init() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestFetchIPRangesReadsLoaderEveryTime(t *testing.T) {
+	content := "192.0.2.0/24\n"
+	provider := &TextProvider{}
+	err := provider.Init(context.Background(), map[string]interface{}{
+		"contentLoader": func(ctx context.Context) (string, error) {
+			return content, nil
+		},
+		"cacheTTL": "1h",
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	first, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if got := first.GetCIDRs(); !reflect.DeepEqual(got, []string{"192.0.2.0/24"}) {
+		t.Errorf("FetchIPRanges() = %v, want [192.0.2.0/24]", got)
+	}
+
+	// An edit of the ConfigMap is visible on the next fetch, whatever the cache TTL
+	content = "198.51.100.0/24\n"
+	second, err := provider.FetchIPRanges(context.Background())
+	if err != nil {
+		t.Fatalf("FetchIPRanges() error = %v", err)
+	}
+	if got := second.GetCIDRs(); !reflect.DeepEqual(got, []string{"198.51.100.0/24"}) {
+		t.Errorf("FetchIPRanges() after an edit = %v, want [198.51.100.0/24]", got)
+	}
+}